@@ -0,0 +1,388 @@
+package curlcmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// dataMode 请求体数据的处理方式，对应curl的不同--data选项
+type dataMode int
+
+const (
+	dataASCII     dataMode = iota // -d、--data：@file读取文件并去掉换行
+	dataRaw                       // --data-raw：原样发送，@没有特殊含义
+	dataBinary                    // --data-binary：@file原样读取文件
+	dataURLEncode                 // --data-urlencode：对内容做URL编码
+	dataJSON                      // --json：同--data-binary，多段之间不加&
+)
+
+// DataPart 一段请求体数据
+type DataPart struct {
+	Mode  dataMode
+	Name  string // --data-urlencode的字段名，编码结果为name=编码后的内容
+	Value string // 数据内容，File为true时为文件名
+	File  bool   // 内容来自上传的文件
+}
+
+// FormPart multipart表单的一个字段
+type FormPart struct {
+	Name        string
+	Value       string // 字段值，File或Content为true时为文件名
+	File        bool   // name=@file，作为文件上传
+	Content     bool   // name=<file，读取文件内容作为字段值
+	Filename    string // 上传时使用的文件名，默认取文件名
+	ContentType string // ;type=指定的类型
+}
+
+// HasBody 命令是否指定了请求体数据（-G时数据放在URL中）
+func (cmd *Command) HasBody() bool {
+	return len(cmd.Data) > 0 || len(cmd.Form) > 0 || cmd.UploadFile != ""
+}
+
+// dataOption 返回--data类选项的处理函数
+func dataOption(mode dataMode) func(cmd *Command, v string) error {
+	return func(cmd *Command, v string) error {
+		part := DataPart{Mode: mode, Value: v}
+		switch mode {
+		case dataASCII, dataBinary, dataJSON:
+			if strings.HasPrefix(v, "@") {
+				part.Value, part.File = v[1:], true
+			}
+		case dataURLEncode:
+			// 支持content、=content、name=content、@file、name@file五种写法
+			if i := strings.IndexAny(v, "=@"); i >= 0 {
+				part.Name, part.Value, part.File = v[:i], v[i+1:], v[i] == '@'
+			}
+		}
+		if part.File && (part.Value == "" || part.Value == "-") {
+			return fmt.Errorf("不支持从标准输入读取数据")
+		}
+		cmd.Data = append(cmd.Data, part)
+		return nil
+	}
+}
+
+// formParams -F中值后面可以跟的参数
+var formParams = []string{"type", "filename", "headers", "encoder"}
+
+// parseFormPart 解析-F的参数：name=value、name=@file、name=<file，
+// 后面可以跟;type=、;filename=，值和文件名可以用双引号包含分号
+func parseFormPart(v string) (FormPart, error) {
+	name, rest, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return FormPart{}, fmt.Errorf("-F格式错误，应为name=value: %s", v)
+	}
+	part := FormPart{Name: name}
+	if strings.HasPrefix(rest, "@") {
+		part.File, rest = true, rest[1:]
+	} else if strings.HasPrefix(rest, "<") {
+		part.Content, rest = true, rest[1:]
+	}
+
+	value, params := splitFormValue(rest)
+	part.Value = value
+	for _, param := range params {
+		key, val, _ := strings.Cut(param, "=")
+		val, _ = splitFormValue(val)
+		switch key {
+		case "type":
+			part.ContentType = val
+		case "filename":
+			part.Filename = val
+		}
+	}
+	if (part.File || part.Content) && (part.Value == "" || part.Value == "-") {
+		return FormPart{}, fmt.Errorf("不支持从标准输入读取表单字段 %s", name)
+	}
+	return part, nil
+}
+
+// splitFormValue 拆出开头的值（可带双引号，引号内可用\转义）和后面以;分隔的参数。
+// 不带引号时，只有;后面紧跟已知参数名时才视为分隔符
+func splitFormValue(s string) (string, []string) {
+	var value string
+	if strings.HasPrefix(s, `"`) {
+		var b strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			b.WriteByte(s[i])
+		}
+		if i < len(s) {
+			i++ // 右引号
+		}
+		value, s = b.String(), s[i:]
+	} else {
+		end := len(s)
+		for i := 0; i < len(s); i++ {
+			if s[i] == ';' && isFormParam(s[i+1:]) {
+				end = i
+				break
+			}
+		}
+		value, s = s[:end], s[end:]
+	}
+
+	var params []string
+	for s != "" {
+		s = strings.TrimPrefix(s, ";")
+		end := len(s)
+		for i := 0; i < len(s); i++ {
+			if s[i] == ';' && isFormParam(s[i+1:]) {
+				end = i
+				break
+			}
+		}
+		params = append(params, strings.TrimSpace(s[:end]))
+		s = s[end:]
+	}
+	return value, params
+}
+
+func isFormParam(s string) bool {
+	s = strings.TrimLeft(s, " ")
+	for _, name := range formParams {
+		if strings.HasPrefix(s, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// Files 随代理请求上传的文件，命令中的@file、<file从这里读取，而不是服务器磁盘。
+// 键为文件名，查找时先按命令中写的路径完全匹配，再按不含目录的文件名匹配
+type Files map[string][]byte
+
+// lookup 查找命令引用的文件
+func (f Files) lookup(name string) ([]byte, error) {
+	if data, ok := f[name]; ok {
+		return data, nil
+	}
+	if data, ok := f[baseName(name)]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("命令引用的文件 %s 未随请求上传", name)
+}
+
+// baseName 去掉Windows和Unix路径中的目录
+func baseName(name string) string {
+	return path.Base(strings.ReplaceAll(name, `\`, "/"))
+}
+
+// DecodeFiles 解码JSON请求中以base64传递的文件，值可以是data:URL
+func DecodeFiles(encoded map[string]string) (Files, error) {
+	files := make(Files, len(encoded))
+	for name, value := range encoded {
+		if strings.HasPrefix(value, "data:") {
+			if i := strings.Index(value, ";base64,"); i >= 0 {
+				value = value[i+len(";base64,"):]
+			}
+		}
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("文件 %s 不是有效的base64: %v", name, err)
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+// FilesFromMultipart 读取multipart请求中上传的全部文件，以上传时的文件名为键
+func FilesFromMultipart(form *multipart.Form) (Files, error) {
+	files := make(Files)
+	for _, headers := range form.File {
+		for _, header := range headers {
+			file, err := header.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+			files[header.Filename] = data
+		}
+	}
+	return files, nil
+}
+
+// NewRequest 生成HTTP请求，请求体、Cookie文件中引用的文件从files读取
+func (cmd *Command) NewRequest(files Files) (*http.Request, error) {
+	header := cmd.Header.Clone()
+	rawURL := cmd.URL
+	var body []byte
+	var err error
+	switch {
+	case cmd.Get && len(cmd.Data) > 0:
+		// -G：数据作为查询参数，不发送请求体
+		var query []byte
+		if query, err = cmd.dataBody(files, make(http.Header)); err == nil {
+			rawURL = appendQuery(rawURL, string(query))
+		}
+	case cmd.UploadFile != "":
+		if body, err = files.lookup(cmd.UploadFile); err == nil && strings.HasSuffix(rawURL, "/") {
+			// 与curl一样，URL以/结尾时把文件名拼接到URL后
+			rawURL += url.PathEscape(baseName(cmd.UploadFile))
+		}
+	case len(cmd.Form) > 0:
+		body, err = cmd.formBody(files, header)
+	case len(cmd.Data) > 0:
+		body, err = cmd.dataBody(files, header)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.addCookies(header, rawURL, files); err != nil {
+		return nil, err
+	}
+	if cmd.Compressed && header.Get("Accept-Encoding") == "" {
+		header.Set("Accept-Encoding", "gzip, deflate")
+	}
+
+	var reader io.Reader
+	if cmd.HasBody() && !cmd.Get {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(cmd.Method, rawURL, reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return req, nil
+}
+
+// appendQuery 把查询参数拼接到URL，保留URL中已有的参数和#片段
+func appendQuery(rawURL, query string) string {
+	fragment := ""
+	if i := strings.Index(rawURL, "#"); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + query + fragment
+}
+
+// dataBody 按curl的规则拼接各段数据：段之间以&分隔，--json之间不加分隔符。
+// 与curl一样，没有指定Content-Type时一律按application/x-www-form-urlencoded发送
+func (cmd *Command) dataBody(files Files, header http.Header) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, part := range cmd.Data {
+		if i > 0 && !(part.Mode == dataJSON && cmd.Data[i-1].Mode == dataJSON) {
+			buf.WriteByte('&')
+		}
+		data := []byte(part.Value)
+		if part.File {
+			content, err := files.lookup(part.Value)
+			if err != nil {
+				return nil, err
+			}
+			data = content
+			if part.Mode == dataASCII {
+				data = bytes.ReplaceAll(bytes.ReplaceAll(data, []byte("\r"), nil), []byte("\n"), nil)
+			}
+		}
+		if part.Mode == dataURLEncode {
+			if part.Name != "" {
+				buf.WriteString(part.Name)
+				buf.WriteByte('=')
+			}
+			data = []byte(strings.ReplaceAll(url.QueryEscape(string(data)), "+", "%20"))
+		}
+		buf.Write(data)
+	}
+
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return buf.Bytes(), nil
+}
+
+// formBody 生成multipart/form-data请求体。命令中的Content-Type带boundary时沿用该boundary，
+// 否则使用随机生成的boundary
+func (cmd *Command) formBody(files Files, header http.Header) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil &&
+		strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		if err := writer.SetBoundary(params["boundary"]); err != nil {
+			return nil, fmt.Errorf("Content-Type中的boundary无效: %v", err)
+		}
+	}
+
+	for _, part := range cmd.Form {
+		h := make(textproto.MIMEHeader)
+		var data []byte
+		switch {
+		case part.File:
+			content, err := files.lookup(part.Value)
+			if err != nil {
+				return nil, err
+			}
+			data = content
+			filename := part.Filename
+			if filename == "" {
+				filename = baseName(part.Value)
+			}
+			contentType := part.ContentType
+			if contentType == "" {
+				contentType = mime.TypeByExtension(filepath.Ext(filename))
+			}
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				escapeQuotes(part.Name), escapeQuotes(filename)))
+			h.Set("Content-Type", contentType)
+		case part.Content:
+			content, err := files.lookup(part.Value)
+			if err != nil {
+				return nil, err
+			}
+			data = content
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(part.Name)))
+		default:
+			data = []byte(part.Value)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(part.Name)))
+		}
+		if part.ContentType != "" && !part.File {
+			h.Set("Content-Type", part.ContentType)
+		}
+
+		w, err := writer.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	header.Set("Content-Type", writer.FormDataContentType())
+	return buf.Bytes(), nil
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,192 @@
+package curlcmd
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newRequest 解析命令并生成请求，返回请求、请求体和解析出的命令
+func newRequest(t *testing.T, line string, files Files) (*http.Request, string, *Command) {
+	t.Helper()
+	cmd, err := Parse(line)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", line, err)
+	}
+	req, err := cmd.NewRequest(files)
+	if err != nil {
+		t.Fatalf("NewRequest(%q): %v", line, err)
+	}
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	return req, string(body), cmd
+}
+
+func TestDataBody(t *testing.T) {
+	files := Files{
+		"body.txt": []byte("a=1\r\nb=2\n"),
+		"f.txt":    []byte("a b&c\n"),
+	}
+	tests := []struct {
+		name        string
+		line        string
+		body        string
+		contentType string
+	}{
+		{"repeated -d joined with &", "curl -d a=1 -d b=2 http://a/", "a=1&b=2", "application/x-www-form-urlencoded"},
+		{"json object sent as form like curl", `curl -d '{"a":1}' http://a/`, `{"a":1}`, "application/x-www-form-urlencoded"},
+		{"explicit content type kept", `curl -H 'Content-Type: text/plain' -d x http://a/`, "x", "text/plain"},
+		{"--json concatenated", `curl --json '{"a":' --json '1}' http://a/`, `{"a":1}`, "application/json"},
+		{"-d and --json separated by &", "curl -d a --json b http://a/", "a&b", "application/json"},
+		{"-d @file strips newlines", "curl -d @body.txt http://a/", "a=1b=2", "application/x-www-form-urlencoded"},
+		{"--data-binary @file kept as is", "curl --data-binary @body.txt http://a/", "a=1\r\nb=2\n", "application/x-www-form-urlencoded"},
+		{"file found by base name", `curl -d '@C:\data\body.txt' http://a/`, "a=1b=2", "application/x-www-form-urlencoded"},
+		{"--data-urlencode name@file", "curl --data-urlencode name@f.txt http://a/", "name=a%20b%26c%0A", "application/x-www-form-urlencoded"},
+		{"--data-urlencode forms", "curl --data-urlencode 'a=b c' --data-urlencode '=x&y' --data-urlencode z http://a/", "a=b%20c&x%26y&z", "application/x-www-form-urlencoded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, body, _ := newRequest(t, tt.line, files)
+			if body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+			if got := req.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestDataJSONWarning(t *testing.T) {
+	tests := []struct {
+		line string
+		warn bool
+	}{
+		{`curl -d '{"a":1}' http://a/`, true},
+		{`curl --data-raw '[1,2]' http://a/`, true},
+		{`curl -H 'Content-Type: application/json' -d '{"a":1}' http://a/`, false},
+		{`curl --json '{"a":1}' http://a/`, false},
+		{`curl -d '{not json}' http://a/`, false},
+		{`curl -G -d '{"a":1}' http://a/`, false},
+		{"curl -d a=1 http://a/", false},
+	}
+	for _, tt := range tests {
+		cmd, err := Parse(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		warned := len(cmd.Warnings) == 1 && strings.Contains(cmd.Warnings[0], "--json")
+		if warned != tt.warn || (!tt.warn && len(cmd.Warnings) != 0) {
+			t.Errorf("Parse(%q) warnings = %v, want JSON warning %v", tt.line, cmd.Warnings, tt.warn)
+		}
+	}
+}
+
+func TestDataGetQuery(t *testing.T) {
+	req, body, _ := newRequest(t, "curl -G -d a=1 --data-urlencode 'q=x y' 'http://a/p?k=v#frag'", nil)
+	if req.Method != http.MethodGet || body != "" {
+		t.Errorf("Method=%s body=%q", req.Method, body)
+	}
+	if got := req.URL.String(); got != "http://a/p?k=v&a=1&q=x%20y#frag" {
+		t.Errorf("URL = %s", got)
+	}
+}
+
+func TestFormBody(t *testing.T) {
+	files := Files{
+		"x.bin":    []byte("\x00\x01"),
+		"a.json":   []byte(`{"k":1}`),
+		"note.txt": []byte("note"),
+	}
+	line := `curl -F 'file=@/home/me/x.bin;type=image/custom;filename="y;.png"' -F f2=@a.json ` +
+		`-F 'c=<note.txt' -F 'name=v;type=text/plain' --form-string 's=@literal' ` +
+		`-H 'Content-Type: multipart/form-data; boundary=XYZ' http://a/`
+	req, body, _ := newRequest(t, line, files)
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] != "XYZ" {
+		t.Fatalf("Content-Type = %s", req.Header.Get("Content-Type"))
+	}
+
+	type field struct{ name, filename, contentType, value string }
+	var got []field
+	reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(part)
+		got = append(got, field{part.FormName(), part.FileName(), part.Header.Get("Content-Type"), string(data)})
+	}
+	want := []field{
+		{"file", "y;.png", "image/custom", "\x00\x01"},
+		{"f2", "a.json", "application/json", `{"k":1}`},
+		{"c", "", "", "note"},
+		{"name", "", "text/plain", "v"},
+		{"s", "", "", "@literal"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parts = %q\nwant %q", got, want)
+	}
+}
+
+func TestParseFormPart(t *testing.T) {
+	tests := []struct {
+		in   string
+		want FormPart
+	}{
+		{"a=b", FormPart{Name: "a", Value: "b"}},
+		{"a=x;y", FormPart{Name: "a", Value: "x;y"}},
+		{"a=b;type=text/plain", FormPart{Name: "a", Value: "b", ContentType: "text/plain"}},
+		{"f=@x.png;type=image/png;filename=y.png", FormPart{Name: "f", Value: "x.png", File: true, ContentType: "image/png", Filename: "y.png"}},
+		{`f=@"a;b.txt";filename="q\"r"`, FormPart{Name: "f", Value: "a;b.txt", File: true, Filename: `q"r`}},
+		{"f=@x; type=text/csv", FormPart{Name: "f", Value: "x", File: true, ContentType: "text/csv"}},
+		{"c=<x.txt", FormPart{Name: "c", Value: "x.txt", Content: true}},
+		{"a=", FormPart{Name: "a"}},
+	}
+	for _, tt := range tests {
+		got, err := parseFormPart(tt.in)
+		if err != nil {
+			t.Errorf("parseFormPart(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFormPart(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"novalue", "=b", "f=@", "f=@-", "c=<-"} {
+		if part, err := parseFormPart(in); err == nil {
+			t.Errorf("parseFormPart(%q) = %+v, want error", in, part)
+		}
+	}
+}
+
+func TestMissingFiles(t *testing.T) {
+	lines := []string{
+		"curl -d @missing.txt http://a/",
+		"curl --data-urlencode n@missing.txt http://a/",
+		"curl -F f=@missing.bin http://a/",
+		"curl -F 'c=<missing.txt' http://a/",
+		"curl -T missing.bin http://a/",
+		"curl -b missing.cookies http://a/",
+	}
+	for _, line := range lines {
+		cmd, err := Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", line, err)
+		}
+		if _, err := cmd.NewRequest(Files{"other.txt": nil}); err == nil || !strings.Contains(err.Error(), "missing") {
+			t.Errorf("NewRequest(%q) = %v, want an error naming the file", line, err)
+		}
+	}
+}
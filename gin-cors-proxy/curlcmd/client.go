@@ -0,0 +1,260 @@
+package curlcmd
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout 未指定--connect-timeout和--max-time时的连接超时和总超时，Stream时为等待响应头的超时
+const DefaultTimeout = 30 * time.Second
+
+// NewClient 按命令中的超时、代理、--resolve、TLS和HTTP版本选项创建HTTP客户端，证书文件从files读取
+func (cmd *Command) NewClient(files Files) (*http.Client, error) {
+	tlsConfig, err := cmd.tlsConfig(files)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := cmd.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	// --resolve指定的IP不经过DNS，目标地址规则中允许的域名可以借此连接到任意内网地址
+	if cmd.Destinations != nil && len(cmd.Resolve) > 0 {
+		return nil, fmt.Errorf("服务端限制了请求的目标地址，不支持--resolve")
+	}
+
+	connectTimeout := DefaultTimeout
+	if cmd.ConnectTimeout > 0 {
+		connectTimeout = cmd.ConnectTimeout
+	}
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           cmd.dialContext(dialer),
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		// 与curl一样，只有--compressed时才请求压缩的响应
+		DisableCompression: true,
+		ForceAttemptHTTP2:  cmd.HTTPVersion != "1.1",
+	}
+	if cmd.HTTPVersion == "1.1" {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	timeout := DefaultTimeout
+	if cmd.MaxTime > 0 {
+		timeout = cmd.MaxTime
+	} else if cmd.Stream {
+		// 大文件和流式响应可能传输很久，只限制等待响应头的时间
+		timeout = 0
+		transport.ResponseHeaderTimeout = DefaultTimeout
+	}
+	// 重定向由Do按curl的规则处理，客户端本身不跟随
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return client, nil
+}
+
+// DestinationChecker 请求目标地址的访问控制，用于防止借助代理访问内网（SSRF）
+type DestinationChecker interface {
+	// CheckHost 发送请求（包括每次重定向）前检查URL中的主机和端口，host可能是域名或IP
+	CheckHost(host string, port int) error
+	// CheckDial 建立连接前检查实际连接的IP和端口，host是DNS解析前的主机名。
+	// 在解析之后、连接之前检查，可以防止DNS重绑定
+	CheckDial(host string, ip net.IP, port int) error
+}
+
+// DestinationError DestinationChecker拒绝访问时返回的错误，Err为检查返回的原始错误
+type DestinationError struct {
+	Err error
+}
+
+func (e *DestinationError) Error() string { return e.Err.Error() }
+
+func (e *DestinationError) Unwrap() error { return e.Err }
+
+// dialContext 连接时按--resolve把host:port替换为指定的IP，设置了Destinations时检查实际连接的地址
+func (cmd *Command) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		if ip, ok := cmd.Resolve[strings.ToLower(addr)]; ok {
+			_, port, _ := net.SplitHostPort(addr)
+			addr = net.JoinHostPort(ip, port)
+		}
+		if cmd.Destinations == nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		checked := *dialer
+		checked.Control = func(network, address string, _ syscall.RawConn) error {
+			ipStr, portStr, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			port, _ := strconv.Atoi(portStr)
+			if err := cmd.Destinations.CheckDial(host, net.ParseIP(ipStr), port); err != nil {
+				return &DestinationError{Err: err}
+			}
+			return nil
+		}
+		return checked.DialContext(ctx, network, addr)
+	}
+}
+
+// proxyFunc 返回代理选择函数：指定-x时使用该代理，否则使用HTTP_PROXY等环境变量；--noproxy中的主机直连。
+// 设置了Destinations时不经过任何代理：经代理时实际连接的是代理服务器，目标域名由代理解析，
+// CheckDial只能看到代理的IP，无法防止DNS重绑定，因此忽略环境变量并拒绝-x
+func (cmd *Command) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if cmd.Destinations != nil {
+		if cmd.Proxy != "" {
+			return nil, fmt.Errorf("服务端限制了请求的目标地址，不支持-x/--proxy代理")
+		}
+		return nil, nil
+	}
+	proxy := http.ProxyFromEnvironment
+	if cmd.Proxy != "" {
+		proxyURL, err := url.Parse(cmd.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("代理地址无效: %s", cmd.Proxy)
+		}
+		if cmd.ProxyUser != "" {
+			user, password, _ := strings.Cut(cmd.ProxyUser, ":")
+			proxyURL.User = url.UserPassword(user, password)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	if cmd.NoProxy == "" {
+		return proxy, nil
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		for _, entry := range strings.Split(cmd.NoProxy, ",") {
+			entry = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(entry), "."))
+			if entry == "*" || host == entry || strings.HasSuffix(host, "."+entry) {
+				return nil, nil
+			}
+		}
+		return proxy(req)
+	}, nil
+}
+
+// tlsConfig 按-k、--tlsv1.x、--cacert、--cert、--key生成TLS配置
+func (cmd *Command) tlsConfig(files Files) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: cmd.Insecure, MinVersion: cmd.TLSMinVersion}
+	if cmd.CACert != "" {
+		data, err := files.lookup(cmd.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("--cacert %s 不是PEM格式的证书", cmd.CACert)
+		}
+		config.RootCAs = pool
+		cmd.rootCAs = pool
+	}
+	if cmd.Cert != "" {
+		certPEM, err := files.lookup(cmd.Cert)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM := certPEM
+		if cmd.Key != "" {
+			if keyPEM, err = files.lookup(cmd.Key); err != nil {
+				return nil, err
+			}
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// ReadBody 读取响应体，最多读取limit字节（limit<=0时不限制），超出部分丢弃并返回truncated=true。
+// 指定--compressed时按Content-Encoding边读边解压gzip和deflate，limit按解压后的大小计算；
+// 其他编码或未指定--compressed时原样返回并记录警告
+func (cmd *Command) ReadBody(resp *http.Response, limit int64) (body []byte, truncated bool, err error) {
+	reader, err := cmd.bodyReader(resp)
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+
+	if limit > 0 {
+		body, err = io.ReadAll(io.LimitReader(reader, limit+1))
+		if int64(len(body)) > limit {
+			body, truncated = body[:limit], true
+		}
+	} else {
+		body, err = io.ReadAll(reader)
+	}
+	if err != nil {
+		if reader != resp.Body {
+			err = fmt.Errorf("解压响应体失败: %v", err)
+		}
+		return body, truncated, err
+	}
+	return body, truncated, nil
+}
+
+// bodyReader 按Content-Encoding返回解压后的响应体
+func (cmd *Command) bodyReader(resp *http.Response) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return resp.Body, nil
+	}
+	if !cmd.Compressed {
+		cmd.warnf("响应使用%s编码，可添加--compressed选项解压", encoding)
+		return resp.Body, nil
+	}
+
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("解压响应体失败: %v", err)
+		}
+		return reader, nil
+	case "deflate":
+		// 大多数服务器发送zlib格式，少数发送不带头的原始deflate，按前两个字节区分
+		buffered := bufio.NewReader(resp.Body)
+		if header, err := buffered.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("解压响应体失败: %v", err)
+			}
+			return reader, nil
+		}
+		return flate.NewReader(buffered), nil
+	default:
+		cmd.warnf("不支持解压%s编码的响应，响应体为原始数据", encoding)
+		return resp.Body, nil
+	}
+}
@@ -0,0 +1,130 @@
+package curlcmd
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingChecker 允许所有地址，记录实际连接的地址
+type recordingChecker struct {
+	dialed []string
+}
+
+func (r *recordingChecker) CheckHost(host string, port int) error { return nil }
+
+func (r *recordingChecker) CheckDial(host string, ip net.IP, port int) error {
+	r.dialed = append(r.dialed, net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+	return nil
+}
+
+func TestProxyDisabledWithDestinations(t *testing.T) {
+	cmd, err := Parse("curl -x http://proxy.example.com:8080 http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := cmd.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if proxyURL, _ := client.Transport.(*http.Transport).Proxy(req); proxyURL == nil || proxyURL.Host != "proxy.example.com:8080" {
+		t.Fatalf("proxy without destinations = %v", proxyURL)
+	}
+
+	// 设置了目标地址检查时经代理无法检查实际连接的IP，拒绝-x
+	cmd.Destinations = &recordingChecker{}
+	if _, err := cmd.NewClient(nil); err == nil || !strings.Contains(err.Error(), "--proxy") {
+		t.Fatalf("NewClient with -x and destinations: %v", err)
+	}
+}
+
+func TestDestinationsIgnoreEnvironmentProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer server.Close()
+
+	cmd, err := Parse("curl " + server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := &recordingChecker{}
+	cmd.Destinations = checker
+	client, err := cmd.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Transport.(*http.Transport).Proxy != nil {
+		t.Fatal("transport uses HTTP_PROXY from the environment")
+	}
+
+	req, err := cmd.NewRequest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err := cmd.Do(client, req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	target, _ := url.Parse(server.URL)
+	if len(checker.dialed) != 1 || checker.dialed[0] != target.Host {
+		t.Errorf("dialed = %v, want the target address", checker.dialed)
+	}
+}
+
+func TestResolveDisabledWithDestinations(t *testing.T) {
+	cmd, err := Parse("curl --resolve allowed.example.com:80:169.254.169.254 http://allowed.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmd.NewClient(nil); err != nil {
+		t.Fatalf("NewClient without destinations: %v", err)
+	}
+
+	// 目标地址规则允许某个域名时，--resolve可以让该域名连接到任意IP，如云平台元数据地址
+	checker := &recordingChecker{}
+	cmd.Destinations = checker
+	if _, err := cmd.NewClient(nil); err == nil || !strings.Contains(err.Error(), "--resolve") {
+		t.Fatalf("NewClient with --resolve and destinations: %v", err)
+	}
+	if len(checker.dialed) != 0 {
+		t.Errorf("dialed = %v", checker.dialed)
+	}
+}
+
+// TestStreamTimeouts raw模式没有--max-time时不设置总超时，只限制等待响应头的时间
+func TestStreamTimeouts(t *testing.T) {
+	tests := []struct {
+		line          string
+		stream        bool
+		timeout       time.Duration
+		headerTimeout time.Duration
+	}{
+		{"curl https://a/", false, DefaultTimeout, 0},
+		{"curl https://a/", true, 0, DefaultTimeout},
+		{"curl -m 5 https://a/", true, 5 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		cmd, err := Parse(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd.Stream = tt.stream
+		client, err := cmd.NewClient(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headerTimeout := client.Transport.(*http.Transport).ResponseHeaderTimeout
+		if client.Timeout != tt.timeout || headerTimeout != tt.headerTimeout {
+			t.Errorf("%q stream=%v: Timeout=%v ResponseHeaderTimeout=%v, want %v, %v",
+				tt.line, tt.stream, client.Timeout, headerTimeout, tt.timeout, tt.headerTimeout)
+		}
+	}
+}
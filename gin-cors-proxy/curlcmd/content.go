@@ -0,0 +1,59 @@
+package curlcmd
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// DetectContent 判断响应体的MIME类型以及能否作为文本返回。优先使用Content-Type响应头，
+// 没有或为application/octet-stream时按内容推测；非文本类型或不是合法UTF-8的内容视为二进制
+func DetectContent(contentType string, body []byte, truncated bool) (mimeType string, binary bool) {
+	mimeType, params, _ := mime.ParseMediaType(contentType)
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType, params, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	if !isTextType(mimeType) {
+		return mimeType, true
+	}
+	if charset := strings.ToLower(params["charset"]); charset != "" && charset != "utf-8" && charset != "utf8" && charset != "us-ascii" {
+		// 其他编码的文本转成字符串会乱码，按二进制返回由调用方解码
+		return mimeType, true
+	}
+
+	// 截断可能把多字节字符切成两半，末尾不完整的字符不算非法
+	if truncated {
+		body = trimPartialRune(body)
+	}
+	return mimeType, !utf8.Valid(body)
+}
+
+// trimPartialRune 去掉末尾不完整的UTF-8字符
+func trimPartialRune(body []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(body); i++ {
+		if utf8.RuneStart(body[len(body)-i]) {
+			if !utf8.FullRune(body[len(body)-i:]) {
+				return body[:len(body)-i]
+			}
+			break
+		}
+	}
+	return body
+}
+
+// isTextType 是否为可以作为字符串返回的文本类型
+func isTextType(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"),
+		strings.HasSuffix(mimeType, "+json"),
+		strings.HasSuffix(mimeType, "+xml"):
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/javascript", "application/ecmascript",
+		"application/x-www-form-urlencoded", "application/yaml", "application/x-yaml", "application/graphql", "application/x-ndjson":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package curlcmd
+
+import "testing"
+
+func TestDetectContent(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	chinese := []byte("中文内容")
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		truncated   bool
+		mimeType    string
+		binary      bool
+	}{
+		{"json", "application/json; charset=utf-8", []byte(`{"a":1}`), false, "application/json", false},
+		{"structured json suffix", "application/vnd.api+json", []byte(`{}`), false, "application/vnd.api+json", false},
+		{"image", "image/png", png, false, "image/png", true},
+		{"other charset", "text/plain; charset=gbk", []byte("abc"), false, "text/plain", true},
+		{"invalid utf-8", "text/plain", []byte("a\xffb"), false, "text/plain", true},
+		{"sniff html", "", []byte("<!DOCTYPE html><html></html>"), false, "text/html", false},
+		{"sniff octet-stream", "application/octet-stream", png, false, "image/png", true},
+		{"octet-stream text", "application/octet-stream", []byte("plain text"), false, "text/plain", false},
+		// 截断在多字节字符中间时末尾不完整的字符不算非法
+		{"truncated inside rune", "text/plain", chinese[:4], true, "text/plain", false},
+		{"cut rune not truncated", "text/plain", chinese[:4], false, "text/plain", true},
+		{"truncated invalid", "text/plain", []byte("a\xffb\xe4"), true, "text/plain", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mimeType, binary := DetectContent(tt.contentType, tt.body, tt.truncated)
+			if mimeType != tt.mimeType || binary != tt.binary {
+				t.Errorf("DetectContent = %q, %v, want %q, %v", mimeType, binary, tt.mimeType, tt.binary)
+			}
+		})
+	}
+}
+
+func TestTrimPartialRune(t *testing.T) {
+	text := []byte("a中")
+	for n, want := range map[int]string{0: "", 1: "a", 2: "a", 3: "a", 4: "a中"} {
+		if got := string(trimPartialRune(text[:n])); got != want {
+			t.Errorf("trimPartialRune(%q) = %q, want %q", text[:n], got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package curlcmd
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// addCookies 把-b指定的Cookie追加到Cookie请求头。参数包含=时按name=value字符串发送，
+// 否则视为Netscape格式的Cookie文件，只发送与请求地址匹配且未过期的Cookie
+func (cmd *Command) addCookies(header http.Header, rawURL string, files Files) error {
+	var cookies []string
+	for _, v := range cmd.Cookies {
+		if v == "" {
+			// -b ""只启用Cookie引擎，跟随重定向时发送途中设置的Cookie
+			continue
+		}
+		if strings.Contains(v, "=") {
+			cookies = append(cookies, strings.TrimSpace(v))
+			continue
+		}
+		data, err := files.lookup(v)
+		if err != nil {
+			return err
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		cookies = append(cookies, cookieFileMatches(data, u)...)
+	}
+	appendCookies(header, cookies)
+	return nil
+}
+
+// appendCookies 把name=value形式的Cookie追加到Cookie请求头
+func appendCookies(header http.Header, cookies []string) {
+	if len(cookies) == 0 {
+		return
+	}
+	if existing := header.Get("Cookie"); existing != "" {
+		cookies = append([]string{existing}, cookies...)
+	}
+	header.Set("Cookie", strings.Join(cookies, "; "))
+}
+
+// cookieFileMatches 从Netscape格式的Cookie文件中找出发送给u的Cookie，返回name=value列表。
+// 每行依次为：域名、是否包含子域名、路径、是否仅HTTPS、过期时间（Unix秒，0表示会话Cookie）、名称、值
+func cookieFileMatches(data []byte, u *url.URL) []string {
+	host := strings.ToLower(u.Hostname())
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now().Unix()
+
+	var matched []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		domain := strings.ToLower(strings.TrimPrefix(fields[0], "."))
+		includeSubdomains := strings.EqualFold(fields[1], "TRUE")
+		if host != domain && !(includeSubdomains && strings.HasSuffix(host, "."+domain)) {
+			continue
+		}
+		if !strings.HasPrefix(path, fields[2]) {
+			continue
+		}
+		if strings.EqualFold(fields[3], "TRUE") && u.Scheme != "https" {
+			continue
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires != 0 && expires < now {
+			continue
+		}
+		matched = append(matched, fields[5]+"="+fields[6])
+	}
+	return matched
+}
@@ -0,0 +1,264 @@
+package curlcmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultUserAgent 命令中没有指定User-Agent时使用的默认值
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+
+// Result 执行curl命令的结果，代理以JSON返回给前端
+type Result struct {
+	StatusCode      int               `json:"statusCode"`
+	ResponseBody    string            `json:"responseBody"`
+	ResponseHeaders map[string]string `json:"responseHeaders"`
+	Warnings        []string          `json:"warnings,omitempty"` // 被忽略的curl选项等提示
+	// 请求各阶段耗时（毫秒）和实际使用的连接
+	Timing           *Timing `json:"timing,omitempty"`
+	RemoteIP         string  `json:"remoteIp,omitempty"`
+	RemotePort       int     `json:"remotePort,omitempty"`
+	ConnectionReused bool    `json:"connectionReused"`
+	Protocol         string  `json:"protocol,omitempty"` // 响应的协议版本，如HTTP/1.1、HTTP/2.0
+	// TLS HTTPS请求的TLS版本、加密套件、ALPN和服务器证书链，证书验证失败时包含失败原因
+	TLS *TLSInfo `json:"tls,omitempty"`
+	// 指定-L时途经的重定向响应和最终请求的地址
+	Redirects    []Hop  `json:"redirects,omitempty"`
+	EffectiveURL string `json:"effectiveUrl,omitempty"`
+	// 响应体为二进制（图片、压缩包等）时responseBody为base64编码，bodyEncoding为base64
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+	ContentType  string `json:"contentType,omitempty"` // 响应体的MIME类型，没有Content-Type响应头时按内容推测
+	BodySize     int    `json:"bodySize"`              // 返回的响应体字节数
+	Truncated    bool   `json:"truncated,omitempty"`   // 响应体超过MaxBodySize，只返回了前面的部分
+}
+
+// Executor 执行curl命令：解析、发送请求并把结果写入Result
+type Executor struct {
+	Files Files // 命令中@file、<file引用的文件
+	// Destinations 不为nil时检查请求地址、重定向地址和实际连接的IP
+	Destinations DestinationChecker
+	// MaxBodySize Execute返回的响应体的最大字节数，超出部分截断，<=0时不限制
+	MaxBodySize int64
+	// Stream 为true时Send返回的响应体由调用方转发，未指定--max-time时不设置总超时
+	Stream bool
+	// Logf 打印执行过程，为nil时不打印
+	Logf func(format string, args ...interface{})
+}
+
+// logf 设置了Logf时打印日志
+func (e *Executor) logf(format string, args ...interface{}) {
+	if e.Logf != nil {
+		e.Logf(format, args...)
+	}
+}
+
+// Execute 执行curl命令，读取响应体并把结果写入result，请求失败时也写入已经过的阶段耗时
+func (e *Executor) Execute(curlCmd string, result *Result) error {
+	var tracer Tracer
+	defer func() { result.SetTrace(&tracer) }()
+
+	cmd, resp, err := e.Send(curlCmd, &tracer, result)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 读取响应体，指定--compressed时解压，超过上限的部分截断
+	e.logf("读取响应体...")
+	body, truncated, err := cmd.ReadBody(resp, e.MaxBodySize)
+	result.Warnings = cmd.Warnings
+	if err != nil {
+		e.logf("读取响应体失败: %v", err)
+		return fmt.Errorf("读取响应体失败: %w", err)
+	}
+	if truncated {
+		e.logf("响应体超过%d字节，已截断", e.MaxBodySize)
+	}
+	result.SetBody(resp.Header.Get("Content-Type"), body, truncated)
+
+	e.logf("响应处理完成: 状态码=%d, 响应体大小=%d字节, 类型=%s, 响应头数量=%d",
+		resp.StatusCode, len(body), result.ContentType, len(result.ResponseHeaders))
+	timing := tracer.Timing(time.Now())
+	e.logf("耗时分解: DNS=%.3fms, 连接=%.3fms, TLS=%.3fms, 首字节=%.3fms, 下载=%.3fms, 远端=%s:%d, 复用连接=%v, 协议=%s",
+		timing.DNS, timing.Connect, timing.TLS, timing.TTFB, timing.Download,
+		tracer.RemoteIP, tracer.RemotePort, tracer.Reused, resp.Proto)
+	return nil
+}
+
+// Send 解析curl命令并发送请求，响应头等信息写入result，响应体由调用方读取并关闭。
+// 目标地址被Destinations拒绝时返回*DestinationError，其他错误按DNS、超时、TLS等原因说明
+func (e *Executor) Send(curlCmd string, tracer *Tracer, result *Result) (*Command, *http.Response, error) {
+	e.logf("开始解析CURL命令...")
+	cmd, err := Parse(curlCmd)
+	if err != nil {
+		e.logf("解析CURL命令失败: %v", err)
+		return nil, nil, err
+	}
+	defer func() { result.Warnings = cmd.Warnings }()
+	for _, warning := range cmd.Warnings {
+		e.logf("警告: %s", warning)
+	}
+	e.logf("解析结果: 方法=%s, URL=%s, 数据段数=%d, 表单字段数=%d, 头部数量=%d, Insecure=%v",
+		cmd.Method, RedactURL(cmd.URL), len(cmd.Data), len(cmd.Form), len(cmd.Header), cmd.Insecure)
+
+	// 按命令中的超时、代理、TLS等选项创建HTTP客户端，设置了Destinations时不经过代理，-x会报错
+	cmd.Destinations = e.Destinations
+	cmd.Stream = e.Stream
+	client, err := cmd.NewClient(e.Files)
+	if err != nil {
+		e.logf("创建HTTP客户端失败: %v", err)
+		return nil, nil, err
+	}
+	if cmd.Insecure {
+		e.logf("启用不安全模式，跳过TLS证书验证")
+	}
+	if cmd.FollowRedirects {
+		e.logf("启用跟随重定向，最多%d次", cmd.MaxRedirs)
+	}
+
+	// 创建HTTP请求，请求体中引用的文件从随请求上传的文件中读取
+	req, err := cmd.NewRequest(e.Files)
+	if err != nil {
+		e.logf("创建HTTP请求失败: %v", err)
+		return nil, nil, err
+	}
+	if req.ContentLength > 0 {
+		e.logf("设置请求体数据 (%d字节)", req.ContentLength)
+	}
+	if _, exists := req.Header["User-Agent"]; !exists {
+		req.Header.Set("User-Agent", defaultUserAgent)
+	}
+
+	// 日志中隐藏查询参数和Authorization、Cookie等凭据，命令中的环境变量可能已替换为令牌
+	e.logf("发送HTTP请求: %s %s", req.Method, RedactURL(req.URL.String()))
+	e.logf("请求头详情:")
+	for k, v := range RedactHeader(req.Header) {
+		e.logf("  %s: %s", k, strings.Join(v, ", "))
+	}
+
+	startTime := time.Now()
+	resp, hops, err := cmd.Do(client, tracer.Trace(req), e.Files)
+	result.Redirects = hops
+	duration := time.Since(startTime)
+	if err != nil {
+		err = RedactError(err)
+		e.logf("执行HTTP请求失败: %v, 耗时: %v", err, duration)
+		return nil, nil, e.describeError(err, req, result)
+	}
+
+	for _, hop := range hops {
+		e.logf("重定向: %s %s -> %d %s", hop.Method, RedactURL(hop.URL), hop.StatusCode, RedactURL(hop.Location))
+	}
+	e.logf("收到响应: 状态码=%d, 耗时=%v", resp.StatusCode, duration)
+	e.logf("响应头详情:")
+	for k, v := range RedactHeader(resp.Header) {
+		e.logf("  %s: %s", k, strings.Join(v, ", "))
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.Protocol = resp.Proto
+	result.EffectiveURL = resp.Request.URL.String()
+	result.TLS = cmd.TLSInfo(resp)
+	if result.TLS != nil {
+		e.logf("TLS连接: 版本=%s, 加密套件=%s, ALPN=%s, 证书数量=%d, 验证通过=%v %s",
+			result.TLS.Version, result.TLS.CipherSuite, result.TLS.ALPN,
+			len(result.TLS.Certificates), result.TLS.Verified, result.TLS.VerifyError)
+	}
+	result.ResponseHeaders = HeaderToMap(resp.Header)
+	return cmd, resp, nil
+}
+
+// describeError 说明请求失败的原因，证书验证失败时把服务器的证书链写入result
+func (e *Executor) describeError(err error, req *http.Request, result *Result) error {
+	// 目标地址被拒绝时直接返回检查的错误，调用方可以用errors.Is判断原始错误
+	var denied *DestinationError
+	if errors.As(err, &denied) {
+		return denied
+	}
+	if strings.Contains(err.Error(), "lookup") && strings.Contains(err.Error(), "no such host") {
+		return fmt.Errorf("DNS解析失败，无法找到主机: %w", err)
+	}
+	if strings.Contains(err.Error(), "timeout") {
+		return fmt.Errorf("请求超时: %w", err)
+	}
+	if result.TLS = TLSErrorInfo(err, req.URL.Hostname()); result.TLS != nil {
+		return fmt.Errorf("TLS证书验证失败: %s，如确认可信可以添加--insecure选项", result.TLS.VerifyError)
+	}
+	if strings.Contains(err.Error(), "tls") || strings.Contains(err.Error(), "certificate") {
+		return fmt.Errorf("TLS/SSL错误: %w，请尝试添加--insecure选项", err)
+	}
+	return fmt.Errorf("执行HTTP请求失败: %w", err)
+}
+
+// SetBody 写入响应体，二进制内容以base64编码
+func (r *Result) SetBody(contentType string, body []byte, truncated bool) {
+	mimeType, binary := DetectContent(contentType, body, truncated)
+	r.ContentType = mimeType
+	r.BodySize = len(body)
+	r.Truncated = truncated
+	if binary {
+		r.BodyEncoding = "base64"
+		r.ResponseBody = base64.StdEncoding.EncodeToString(body)
+	} else {
+		r.ResponseBody = string(body)
+	}
+}
+
+// SetTrace 写入请求各阶段耗时和连接信息
+func (r *Result) SetTrace(tracer *Tracer) {
+	timing := tracer.Timing(time.Now())
+	r.Timing = &timing
+	r.RemoteIP = tracer.RemoteIP
+	r.RemotePort = tracer.RemotePort
+	r.ConnectionReused = tracer.Reused
+}
+
+// HeaderToMap 将HTTP头转换为map，重复的头以逗号连接
+func HeaderToMap(header http.Header) map[string]string {
+	result := make(map[string]string)
+	for key, values := range header {
+		if len(values) > 0 {
+			result[key] = strings.Join(values, ", ")
+		}
+	}
+	return result
+}
+
+// skippedUpstreamHeaders 转发上游响应时不复制的响应头：逐跳头、上游的CORS头（由代理自己设置），
+// 以及Set-Cookie（否则上游可以在代理的域名下设置Cookie，覆盖登录状态）
+var skippedUpstreamHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Set-Cookie":          true,
+}
+
+// CopyUpstreamHeaders 复制上游响应头，跳过skippedUpstreamHeaders和Access-Control-*。
+// 上游的HTML和脚本会以代理自己的源返回，禁止浏览器猜测类型，并以沙箱方式加载，脚本无法读取代理站点的登录状态
+func CopyUpstreamHeaders(dst, src http.Header) {
+	for key, values := range src {
+		if skippedUpstreamHeaders[key] || strings.HasPrefix(key, "Access-Control-") {
+			continue
+		}
+		dst[key] = values
+	}
+	dst.Set("X-Content-Type-Options", "nosniff")
+	dst.Set("Content-Security-Policy", "sandbox")
+}
+
+// Forward raw模式：把上游的状态码、响应头和响应体原样写入w，返回转发的响应体字节数
+func Forward(w http.ResponseWriter, resp *http.Response) (int64, error) {
+	CopyUpstreamHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	return io.Copy(w, resp.Body)
+}
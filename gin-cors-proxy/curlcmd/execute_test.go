@@ -0,0 +1,105 @@
+package curlcmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// errDenied 测试用的拒绝访问错误
+var errDenied = errors.New("denied")
+
+// denyingChecker 拒绝所有连接
+type denyingChecker struct{}
+
+func (denyingChecker) CheckHost(host string, port int) error { return nil }
+
+func (denyingChecker) CheckDial(host string, ip net.IP, port int) error { return errDenied }
+
+func TestExecuteTruncatesBody(t *testing.T) {
+	text := strings.Repeat("中", 10) // 30字节
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte(text))
+	zw.Close()
+	binary := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+		case "/png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(binary)
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(text))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		line      string
+		limit     int64
+		body      string
+		encoding  string
+		truncated bool
+	}{
+		{"under limit", "curl " + server.URL, 30, text, "", false},
+		{"no limit", "curl " + server.URL, 0, text, "", false},
+		// 截断在第4个字符中间，仍按文本返回
+		{"truncated inside rune", "curl " + server.URL, 10, text[:10], "", true},
+		// 上限按解压后的大小计算
+		{"compressed", "curl --compressed " + server.URL + "/gzip", 9, text[:9], "", true},
+		{"binary", "curl " + server.URL + "/png", 8, base64.StdEncoding.EncodeToString(binary[:8]), "base64", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result Result
+			executor := Executor{MaxBodySize: tt.limit}
+			if err := executor.Execute(tt.line, &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.ResponseBody != tt.body || result.BodyEncoding != tt.encoding || result.Truncated != tt.truncated {
+				t.Errorf("body = %q (%q), truncated %v, want %q (%q), %v",
+					result.ResponseBody, result.BodyEncoding, result.Truncated, tt.body, tt.encoding, tt.truncated)
+			}
+			size := len(text)
+			if tt.truncated {
+				size = int(tt.limit)
+			}
+			if result.BodySize != size {
+				t.Errorf("bodySize = %d, want %d", result.BodySize, size)
+			}
+			if result.StatusCode != http.StatusOK || result.Timing == nil || result.RemoteIP == "" {
+				t.Errorf("status %d, timing %v, remote %q", result.StatusCode, result.Timing, result.RemoteIP)
+			}
+		})
+	}
+}
+
+func TestExecuteDestinationDenied(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var result Result
+	executor := Executor{Destinations: denyingChecker{}}
+	err := executor.Execute("curl "+server.URL, &result)
+	var denied *DestinationError
+	if !errors.As(err, &denied) || !errors.Is(err, errDenied) {
+		t.Fatalf("Execute = %v, want DestinationError wrapping errDenied", err)
+	}
+	// 拒绝的原因原样返回，不再按连接失败说明
+	if err.Error() != errDenied.Error() {
+		t.Errorf("error = %q", err)
+	}
+}
@@ -0,0 +1,576 @@
+// Package curlcmd 把curl命令行解析为HTTP请求参数，供CORS代理使用。
+// 参数先按shell规则拆分，再像curl一样逐个解析选项，支持合并的短选项（-sSLk）、
+// 紧跟参数的短选项（-XPOST）和--opt=value形式的长选项。
+package curlcmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command 解析后的curl命令
+type Command struct {
+	Method          string
+	URL             string
+	Header          http.Header
+	Data            []DataPart        // -d、--data-binary、--data-urlencode、--json，按出现顺序拼接为请求体
+	Form            []FormPart        // -F、--form-string，以multipart/form-data发送
+	UploadFile      string            // -T上传的文件，以PUT发送文件内容
+	Get             bool              // -G，把请求体数据拼接到URL查询参数中
+	Cookies         []string          // -b的参数：name=value形式的Cookie，或Netscape格式的Cookie文件名
+	Insecure        bool              // 跳过TLS证书验证
+	FollowRedirects bool              // 跟随重定向
+	MaxRedirs       int               // --max-redirs，最多跟随的重定向次数，-1表示不限制
+	LocationTrusted bool              // --location-trusted，重定向到其他主机时仍发送认证信息和Cookie
+	PostRedirects   map[int]bool      // --post301、--post302、--post303，对应状态码的重定向保持POST方法
+	Compressed      bool              // --compressed，请求压缩响应并解压
+	ConnectTimeout  time.Duration     // 连接超时，0表示使用默认值
+	MaxTime         time.Duration     // 整个请求的超时，0表示使用默认值
+	Auth            string            // 基本认证信息 user:password
+	Proxy           string            // -x代理地址，为空时使用环境变量中的代理；设置了Destinations时不支持代理
+	ProxyUser       string            // --proxy-user代理认证信息
+	NoProxy         string            // --noproxy不走代理的主机，逗号分隔，*表示全部
+	Resolve         map[string]string // --resolve，host:port到IP地址的映射；设置了Destinations时不支持
+	HTTPVersion     string            // --http1.1、--http2
+	TLSMinVersion   uint16            // --tlsv1.x指定的最低TLS版本
+	CACert          string            // --cacert，PEM格式的CA证书文件
+	Cert            string            // --cert，PEM格式的客户端证书文件，可以同时包含私钥
+	Key             string            // --key，PEM格式的客户端私钥文件
+
+	// Warnings 解析和执行过程中被忽略的选项和参数，随响应返回给调用方
+	Warnings []string
+
+	// Destinations 不为nil时检查每次请求和连接的目标地址，由调用方在NewClient之前设置
+	Destinations DestinationChecker
+	// Stream 为true时响应体由调用方转发（raw模式），与curl一样未指定--max-time时不限制总时间，
+	// 只限制连接和等待响应头的时间，由调用方在NewClient之前设置
+	Stream bool
+
+	methodSet bool
+	head      bool
+	rootCAs   *x509.CertPool // --cacert加载的CA证书，为nil时使用系统证书
+}
+
+// option 一个curl选项。flag选项不带参数，可以用--no-前缀关闭；value选项需要一个参数
+type option struct {
+	long  string
+	short byte
+	flag  func(cmd *Command, on bool)
+	value func(cmd *Command, v string) error
+}
+
+// ignored 不影响代理请求结果的选项，解析时只跳过其参数
+func ignored(cmd *Command, on bool) {}
+
+func ignoredValue(cmd *Command, v string) error { return nil }
+
+// unsupported 代理无法实现的选项，忽略并给出警告
+func unsupported(name string) func(cmd *Command, on bool) {
+	return func(cmd *Command, on bool) {
+		if on {
+			cmd.warnf("不支持选项%s，已忽略", name)
+		}
+	}
+}
+
+// unsupportedValue 代理无法实现的带参数选项，跳过参数并给出警告
+func unsupportedValue(name string) func(cmd *Command, v string) error {
+	return func(cmd *Command, v string) error {
+		cmd.warnf("不支持选项%s，已忽略", name)
+		return nil
+	}
+}
+
+// setHeader 返回设置指定请求头的选项处理函数
+func setHeader(name string) func(cmd *Command, v string) error {
+	return func(cmd *Command, v string) error {
+		cmd.Header.Set(name, v)
+		return nil
+	}
+}
+
+// setString 返回设置字符串字段的选项处理函数
+func setString(field func(cmd *Command) *string) func(cmd *Command, v string) error {
+	return func(cmd *Command, v string) error {
+		*field(cmd) = v
+		return nil
+	}
+}
+
+// setPostRedirect 返回设置对应状态码的重定向是否保持POST方法的选项处理函数
+func setPostRedirect(status int) func(cmd *Command, on bool) {
+	return func(cmd *Command, on bool) {
+		cmd.PostRedirects[status] = on
+	}
+}
+
+// setTLSVersion 返回设置最低TLS版本的选项处理函数
+func setTLSVersion(version uint16) func(cmd *Command, on bool) {
+	return func(cmd *Command, on bool) {
+		if on {
+			cmd.TLSMinVersion = version
+		}
+	}
+}
+
+var options = []option{
+	{long: "request", short: 'X', value: func(cmd *Command, v string) error {
+		cmd.Method, cmd.methodSet = v, true
+		return nil
+	}},
+	{long: "header", short: 'H', value: addHeader},
+	{long: "data", short: 'd', value: dataOption(dataASCII)},
+	{long: "data-ascii", value: dataOption(dataASCII)},
+	{long: "data-raw", value: dataOption(dataRaw)},
+	{long: "data-binary", value: dataOption(dataBinary)},
+	{long: "data-urlencode", value: dataOption(dataURLEncode)},
+	{long: "json", value: func(cmd *Command, v string) error {
+		if cmd.Header.Get("Content-Type") == "" {
+			cmd.Header.Set("Content-Type", "application/json")
+		}
+		if cmd.Header.Get("Accept") == "" {
+			cmd.Header.Set("Accept", "application/json")
+		}
+		return dataOption(dataJSON)(cmd, v)
+	}},
+	{long: "form", short: 'F', value: func(cmd *Command, v string) error {
+		part, err := parseFormPart(v)
+		if err != nil {
+			return err
+		}
+		cmd.Form = append(cmd.Form, part)
+		return nil
+	}},
+	{long: "form-string", value: func(cmd *Command, v string) error {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return fmt.Errorf("--form-string格式错误，应为name=value: %s", v)
+		}
+		cmd.Form = append(cmd.Form, FormPart{Name: name, Value: value})
+		return nil
+	}},
+	{long: "upload-file", short: 'T', value: func(cmd *Command, v string) error {
+		if v == "" || v == "-" || v == "." {
+			return fmt.Errorf("不支持从标准输入上传")
+		}
+		cmd.UploadFile = v
+		return nil
+	}},
+	{long: "get", short: 'G', flag: func(cmd *Command, on bool) { cmd.Get = on }},
+	{long: "head", short: 'I', flag: func(cmd *Command, on bool) { cmd.head = on }},
+	{long: "url", value: func(cmd *Command, v string) error {
+		return setURL(cmd, v)
+	}},
+	{long: "user-agent", short: 'A', value: setHeader("User-Agent")},
+	{long: "referer", short: 'e', value: func(cmd *Command, v string) error {
+		// ;auto表示跟随重定向时自动设置Referer，代理不支持，只取地址部分
+		cmd.Header.Set("Referer", strings.TrimSuffix(v, ";auto"))
+		return nil
+	}},
+	{long: "range", short: 'r', value: func(cmd *Command, v string) error {
+		cmd.Header.Set("Range", "bytes="+v)
+		return nil
+	}},
+	{long: "cookie", short: 'b', value: func(cmd *Command, v string) error {
+		cmd.Cookies = append(cmd.Cookies, v)
+		return nil
+	}},
+	{long: "user", short: 'u', value: setString(func(cmd *Command) *string { return &cmd.Auth })},
+	{long: "oauth2-bearer", value: func(cmd *Command, v string) error {
+		cmd.Header.Set("Authorization", "Bearer "+v)
+		return nil
+	}},
+	{long: "insecure", short: 'k', flag: func(cmd *Command, on bool) { cmd.Insecure = on }},
+	{long: "location", short: 'L', flag: func(cmd *Command, on bool) { cmd.FollowRedirects = on }},
+	{long: "location-trusted", flag: func(cmd *Command, on bool) {
+		cmd.LocationTrusted = on
+		if on {
+			cmd.FollowRedirects = true
+		}
+	}},
+	{long: "max-redirs", value: func(cmd *Command, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < -1 {
+			return fmt.Errorf("--max-redirs的值无效: %s", v)
+		}
+		cmd.MaxRedirs = n
+		return nil
+	}},
+	{long: "post301", flag: setPostRedirect(http.StatusMovedPermanently)},
+	{long: "post302", flag: setPostRedirect(http.StatusFound)},
+	{long: "post303", flag: setPostRedirect(http.StatusSeeOther)},
+	{long: "compressed", flag: func(cmd *Command, on bool) { cmd.Compressed = on }},
+	{long: "connect-timeout", value: func(cmd *Command, v string) error {
+		return setSeconds(&cmd.ConnectTimeout, "--connect-timeout", v)
+	}},
+	{long: "max-time", short: 'm', value: func(cmd *Command, v string) error {
+		return setSeconds(&cmd.MaxTime, "--max-time", v)
+	}},
+	{long: "proxy", short: 'x', value: func(cmd *Command, v string) error {
+		if !strings.Contains(v, "://") {
+			v = "http://" + v
+		}
+		cmd.Proxy = v
+		return nil
+	}},
+	{long: "proxy-user", short: 'U', value: setString(func(cmd *Command) *string { return &cmd.ProxyUser })},
+	{long: "noproxy", value: setString(func(cmd *Command) *string { return &cmd.NoProxy })},
+	{long: "resolve", value: addResolve},
+	{long: "http1.1", flag: func(cmd *Command, on bool) {
+		if on {
+			cmd.HTTPVersion = "1.1"
+		}
+	}},
+	{long: "http2", flag: func(cmd *Command, on bool) {
+		if on {
+			cmd.HTTPVersion = "2"
+		}
+	}},
+	{long: "tlsv1", short: '1', flag: setTLSVersion(tls.VersionTLS10)},
+	{long: "tlsv1.0", flag: setTLSVersion(tls.VersionTLS10)},
+	{long: "tlsv1.1", flag: setTLSVersion(tls.VersionTLS11)},
+	{long: "tlsv1.2", flag: setTLSVersion(tls.VersionTLS12)},
+	{long: "tlsv1.3", flag: setTLSVersion(tls.VersionTLS13)},
+	{long: "cacert", value: setString(func(cmd *Command) *string { return &cmd.CACert })},
+	{long: "cert", short: 'E', value: func(cmd *Command, v string) error {
+		// 不支持带密码的证书，file:password只取文件名（Windows盘符中的冒号除外）
+		if i := strings.LastIndex(v, ":"); i > 1 {
+			cmd.warnf("不支持加密的客户端证书，已忽略--cert中的密码")
+			v = v[:i]
+		}
+		cmd.Cert = v
+		return nil
+	}},
+	{long: "key", value: setString(func(cmd *Command) *string { return &cmd.Key })},
+	{long: "basic", flag: ignored},
+
+	// 代理无法实现的选项
+	{long: "http1.0", short: '0', flag: unsupported("--http1.0")},
+	{long: "http2-prior-knowledge", flag: unsupported("--http2-prior-knowledge")},
+	{long: "http3", flag: unsupported("--http3")},
+	{long: "digest", flag: unsupported("--digest")},
+	{long: "ntlm", flag: unsupported("--ntlm")},
+	{long: "negotiate", flag: unsupported("--negotiate")},
+	{long: "proxy-header", value: unsupportedValue("--proxy-header")},
+	{long: "interface", value: unsupportedValue("--interface")},
+	{long: "local-port", value: unsupportedValue("--local-port")},
+	{long: "unix-socket", value: unsupportedValue("--unix-socket")},
+	{long: "capath", value: unsupportedValue("--capath")},
+	{long: "ciphers", value: unsupportedValue("--ciphers")},
+	{long: "cert-type", value: unsupportedValue("--cert-type")},
+	{long: "key-type", value: unsupportedValue("--key-type")},
+	{long: "pass", value: unsupportedValue("--pass")},
+	{long: "config", short: 'K', value: unsupportedValue("--config")},
+
+	// 只影响传输过程、不改变请求内容的选项
+	{long: "retry", value: ignoredValue},
+	{long: "retry-delay", value: ignoredValue},
+	{long: "retry-max-time", value: ignoredValue},
+	{long: "limit-rate", value: ignoredValue},
+	{long: "speed-time", short: 'y', value: ignoredValue},
+	{long: "speed-limit", short: 'Y', value: ignoredValue},
+	{long: "keepalive-time", value: ignoredValue},
+
+	// 只影响curl本地输出、不影响请求内容的选项
+	{long: "silent", short: 's', flag: ignored},
+	{long: "show-error", short: 'S', flag: ignored},
+	{long: "verbose", short: 'v', flag: ignored},
+	{long: "include", short: 'i', flag: ignored},
+	{long: "fail", short: 'f', flag: ignored},
+	{long: "progress-bar", short: '#', flag: ignored},
+	{long: "no-buffer", short: 'N', flag: ignored},
+	{long: "globoff", short: 'g', flag: ignored},
+	{long: "remote-name", short: 'O', flag: ignored},
+	{long: "output", short: 'o', value: ignoredValue},
+	{long: "write-out", short: 'w', value: ignoredValue},
+	{long: "dump-header", short: 'D', value: ignoredValue},
+	{long: "cookie-jar", short: 'c', value: ignoredValue},
+	{long: "trace", value: ignoredValue},
+	{long: "trace-ascii", value: ignoredValue},
+	{long: "stderr", value: ignoredValue},
+}
+
+var (
+	longOptions  = map[string]*option{}
+	shortOptions = map[byte]*option{}
+)
+
+func init() {
+	for i := range options {
+		opt := &options[i]
+		longOptions[opt.long] = opt
+		if opt.short != 0 {
+			shortOptions[opt.short] = opt
+		}
+	}
+}
+
+// Parse 解析curl命令行，命令须以curl开头。无法识别的选项和多余的参数记录在Warnings中
+func Parse(line string) (*Command, error) {
+	args, err := Split(line)
+	if err != nil {
+		return nil, fmt.Errorf("解析命令失败: %v", err)
+	}
+	if len(args) == 0 || !isCurl(args[0]) {
+		return nil, fmt.Errorf("command must start with 'curl'")
+	}
+
+	cmd := &Command{
+		Method:        http.MethodGet,
+		Header:        make(http.Header),
+		MaxRedirs:     DefaultMaxRedirs,
+		PostRedirects: make(map[int]bool),
+	}
+	if err := parseArgs(cmd, args[1:]); err != nil {
+		return nil, err
+	}
+	if cmd.URL == "" {
+		return nil, fmt.Errorf("无法解析URL")
+	}
+	if err := cmd.finish(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// isCurl 判断第一个参数是否为curl程序，允许带路径和.exe后缀
+func isCurl(arg string) bool {
+	name := strings.ToLower(path.Base(strings.ReplaceAll(arg, `\`, "/")))
+	return name == "curl" || name == "curl.exe"
+}
+
+// parseArgs 逐个解析参数，--之后的参数都视为URL
+func parseArgs(cmd *Command, args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		next := func(name string) (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("选项%s缺少参数", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch {
+		case arg == "--":
+			for _, rest := range args[i+1:] {
+				if err := setURL(cmd, rest); err != nil {
+					return err
+				}
+			}
+			return nil
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			opt, on := lookupLong(name)
+			if opt == nil {
+				cmd.warnf("未知选项--%s，已忽略", name)
+				continue
+			}
+			if opt.flag != nil {
+				opt.flag(cmd, on)
+				continue
+			}
+			if !hasValue {
+				v, err := next("--" + name)
+				if err != nil {
+					return err
+				}
+				value = v
+			}
+			if err := opt.value(cmd, value); err != nil {
+				return err
+			}
+
+		case len(arg) > 1 && arg[0] == '-':
+			// 合并的短选项，带参数的选项之后的部分即为参数，如-XPOST
+			for j := 1; j < len(arg); j++ {
+				opt := shortOptions[arg[j]]
+				if opt == nil {
+					cmd.warnf("未知选项-%c，已忽略", arg[j])
+					continue
+				}
+				if opt.flag != nil {
+					opt.flag(cmd, true)
+					continue
+				}
+				value := arg[j+1:]
+				if value == "" {
+					v, err := next("-" + string(arg[j]))
+					if err != nil {
+						return err
+					}
+					value = v
+				}
+				if err := opt.value(cmd, value); err != nil {
+					return err
+				}
+				break
+			}
+
+		default:
+			if err := setURL(cmd, arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupLong 查找长选项，flag选项支持--no-前缀
+func lookupLong(name string) (*option, bool) {
+	if opt := longOptions[name]; opt != nil {
+		return opt, true
+	}
+	if strings.HasPrefix(name, "no-") {
+		if opt := longOptions[name[3:]]; opt != nil && opt.flag != nil {
+			return opt, false
+		}
+	}
+	return nil, false
+}
+
+// warnf 记录一条警告
+func (cmd *Command) warnf(format string, args ...interface{}) {
+	cmd.Warnings = append(cmd.Warnings, fmt.Sprintf(format, args...))
+}
+
+// setURL 设置请求地址，只使用第一个URL；没有协议时与curl一样默认http
+func setURL(cmd *Command, v string) error {
+	if cmd.URL != "" {
+		cmd.warnf("只支持一个URL，已忽略参数%s", v)
+		return nil
+	}
+	if v == "" {
+		return fmt.Errorf("URL不能为空")
+	}
+	if !strings.Contains(v, "://") {
+		v = "http://" + v
+	}
+	cmd.URL = v
+	return nil
+}
+
+// addHeader 添加请求头。"Name:"（值为空）表示去掉该请求头，"Name;"表示发送空值
+func addHeader(cmd *Command, v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	if !ok {
+		if strings.HasSuffix(v, ";") {
+			cmd.Header[http.CanonicalHeaderKey(strings.TrimSpace(v[:len(v)-1]))] = []string{""}
+		}
+		return nil
+	}
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if name == "" {
+		return fmt.Errorf("请求头格式错误: %s", v)
+	}
+	if value == "" {
+		cmd.Header.Del(name)
+		return nil
+	}
+	cmd.Header.Add(name, value)
+	return nil
+}
+
+// addResolve 解析--resolve host:port:addr[,addr...]，只使用第一个地址；+前缀表示延迟生效，这里同样对待
+func addResolve(cmd *Command, v string) error {
+	entry := strings.TrimPrefix(v, "+")
+	host, rest, ok := strings.Cut(entry, ":")
+	port, addrs, ok2 := strings.Cut(rest, ":")
+	if !ok || !ok2 || host == "" || addrs == "" {
+		return fmt.Errorf("--resolve格式错误，应为host:port:addr: %s", v)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("--resolve的端口无效: %s", v)
+	}
+	addr, _, _ := strings.Cut(addrs, ",")
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if net.ParseIP(addr) == nil {
+		return fmt.Errorf("--resolve的地址须为IP: %s", v)
+	}
+	if cmd.Resolve == nil {
+		cmd.Resolve = make(map[string]string)
+	}
+	cmd.Resolve[net.JoinHostPort(strings.ToLower(host), port)] = addr
+	return nil
+}
+
+// setSeconds 解析秒数，允许小数，如2.5
+func setSeconds(dest *time.Duration, name, v string) error {
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil || seconds < 0 {
+		return fmt.Errorf("%s的值无效: %s", name, v)
+	}
+	*dest = time.Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// finish 检查选项组合并补全请求方法和认证头，Content-Type在生成请求体时确定
+func (cmd *Command) finish() error {
+	bodies := 0
+	for _, has := range []bool{len(cmd.Data) > 0, len(cmd.Form) > 0, cmd.UploadFile != ""} {
+		if has {
+			bodies++
+		}
+	}
+	switch {
+	case bodies > 1:
+		return fmt.Errorf("-d、-F、-T不能同时使用")
+	case cmd.head && cmd.HasBody():
+		return fmt.Errorf("-I不能与-d、-F、-T同时使用")
+	case cmd.Get && (len(cmd.Form) > 0 || cmd.UploadFile != ""):
+		return fmt.Errorf("-G只能与-d类选项同时使用")
+	}
+
+	if !cmd.methodSet {
+		switch {
+		case cmd.head:
+			cmd.Method = http.MethodHead
+		case cmd.Get:
+			cmd.Method = http.MethodGet
+		case cmd.UploadFile != "":
+			cmd.Method = http.MethodPut
+		case cmd.HasBody():
+			cmd.Method = http.MethodPost
+		}
+	}
+
+	if cmd.Auth != "" && cmd.Header.Get("Authorization") == "" {
+		cmd.Header.Set("Authorization", "Basic "+basicAuth(cmd.Auth))
+	}
+	if !cmd.Get && cmd.Header.Get("Content-Type") == "" && looksLikeJSON(cmd.Data) {
+		cmd.warnf("-d的内容是JSON，但与curl一样按application/x-www-form-urlencoded发送，需要时请改用--json或加上-H 'Content-Type: application/json'")
+	}
+	return nil
+}
+
+// looksLikeJSON 命令中直接写出的-d数据是否为JSON对象或数组
+func looksLikeJSON(data []DataPart) bool {
+	for _, part := range data {
+		if part.File || part.Mode == dataURLEncode || part.Mode == dataJSON {
+			continue
+		}
+		value := strings.TrimSpace(part.Value)
+		if (strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[")) && json.Valid([]byte(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// basicAuth 编码user:password，没有密码时密码为空
+func basicAuth(auth string) string {
+	if !strings.Contains(auth, ":") {
+		auth += ":"
+	}
+	return base64.StdEncoding.EncodeToString([]byte(auth))
+}
@@ -0,0 +1,263 @@
+package curlcmd
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		check func(t *testing.T, cmd *Command)
+	}{
+		{"combined short flags", "curl -sSLk https://a/", func(t *testing.T, cmd *Command) {
+			if !cmd.FollowRedirects || !cmd.Insecure || len(cmd.Warnings) != 0 {
+				t.Errorf("FollowRedirects=%v Insecure=%v Warnings=%v", cmd.FollowRedirects, cmd.Insecure, cmd.Warnings)
+			}
+		}},
+		{"short flags followed by value option", "curl -sSLkXPOST https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.Method != http.MethodPost || !cmd.FollowRedirects || !cmd.Insecure {
+				t.Errorf("Method=%s FollowRedirects=%v Insecure=%v", cmd.Method, cmd.FollowRedirects, cmd.Insecure)
+			}
+		}},
+		{"value in separate argument", "curl -X PUT https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.Method != http.MethodPut {
+				t.Errorf("Method = %s", cmd.Method)
+			}
+		}},
+		{"long option with equals", "curl --max-redirs=3 --request=PATCH https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.MaxRedirs != 3 || cmd.Method != http.MethodPatch {
+				t.Errorf("MaxRedirs=%d Method=%s", cmd.MaxRedirs, cmd.Method)
+			}
+		}},
+		{"no- prefix turns flag off", "curl -L -k --no-location --no-insecure https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.FollowRedirects || cmd.Insecure {
+				t.Errorf("FollowRedirects=%v Insecure=%v", cmd.FollowRedirects, cmd.Insecure)
+			}
+		}},
+		{"data-raw keeps @", "curl --data-raw @x https://a/", func(t *testing.T, cmd *Command) {
+			if want := []DataPart{{Mode: dataRaw, Value: "@x"}}; !reflect.DeepEqual(cmd.Data, want) {
+				t.Errorf("Data = %+v", cmd.Data)
+			}
+			if cmd.Method != http.MethodPost {
+				t.Errorf("Method = %s", cmd.Method)
+			}
+			if body, err := cmd.dataBody(nil, make(http.Header)); err != nil || string(body) != "@x" {
+				t.Errorf("body = %q, %v", body, err)
+			}
+		}},
+		{"data reads file", "curl -d @body.txt https://a/", func(t *testing.T, cmd *Command) {
+			if want := []DataPart{{Mode: dataASCII, Value: "body.txt", File: true}}; !reflect.DeepEqual(cmd.Data, want) {
+				t.Errorf("Data = %+v", cmd.Data)
+			}
+		}},
+		{"data-urlencode forms", "curl --data-urlencode 'a=b c' --data-urlencode n@f --data-urlencode =x https://a/", func(t *testing.T, cmd *Command) {
+			want := []DataPart{
+				{Mode: dataURLEncode, Name: "a", Value: "b c"},
+				{Mode: dataURLEncode, Name: "n", Value: "f", File: true},
+				{Mode: dataURLEncode, Value: "x"},
+			}
+			if !reflect.DeepEqual(cmd.Data, want) {
+				t.Errorf("Data = %+v", cmd.Data)
+			}
+		}},
+		{"json sets headers", `curl --json '{"a":1}' https://a/`, func(t *testing.T, cmd *Command) {
+			if cmd.Header.Get("Content-Type") != "application/json" || cmd.Header.Get("Accept") != "application/json" {
+				t.Errorf("Header = %v", cmd.Header)
+			}
+			if len(cmd.Data) != 1 || cmd.Data[0].Mode != dataJSON || cmd.Method != http.MethodPost {
+				t.Errorf("Data=%+v Method=%s", cmd.Data, cmd.Method)
+			}
+		}},
+		{"header removal and empty value", `curl -H 'Accept:' -H 'X-Empty;' -H 'X-A: 1' -H 'X-A: 2' https://a/`, func(t *testing.T, cmd *Command) {
+			want := http.Header{"X-Empty": {""}, "X-A": {"1", "2"}}
+			if !reflect.DeepEqual(cmd.Header, want) {
+				t.Errorf("Header = %v", cmd.Header)
+			}
+		}},
+		{"basic auth", "curl -u user https://a/", func(t *testing.T, cmd *Command) {
+			if got := cmd.Header.Get("Authorization"); got != "Basic dXNlcjo=" {
+				t.Errorf("Authorization = %s", got)
+			}
+		}},
+		{"explicit authorization wins over -u", "curl -u user:pw -H 'Authorization: Bearer t' https://a/", func(t *testing.T, cmd *Command) {
+			if got := cmd.Header.Get("Authorization"); got != "Bearer t" {
+				t.Errorf("Authorization = %s", got)
+			}
+		}},
+		{"head", "curl -I https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.Method != http.MethodHead {
+				t.Errorf("Method = %s", cmd.Method)
+			}
+		}},
+		{"get with data", "curl -G -d a=1 https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.Method != http.MethodGet || !cmd.Get {
+				t.Errorf("Method=%s Get=%v", cmd.Method, cmd.Get)
+			}
+		}},
+		{"upload file", "curl -T f.bin https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.Method != http.MethodPut || cmd.UploadFile != "f.bin" {
+				t.Errorf("Method=%s UploadFile=%s", cmd.Method, cmd.UploadFile)
+			}
+		}},
+		{"default scheme", "curl example.com/x", func(t *testing.T, cmd *Command) {
+			if cmd.URL != "http://example.com/x" {
+				t.Errorf("URL = %s", cmd.URL)
+			}
+		}},
+		{"url after double dash", "curl -k -- -weird", func(t *testing.T, cmd *Command) {
+			if cmd.URL != "http://-weird" {
+				t.Errorf("URL = %s", cmd.URL)
+			}
+		}},
+		{"curl.exe with path", `'C:\bin\curl.exe' https://a/`, func(t *testing.T, cmd *Command) {
+			if cmd.URL != "https://a/" {
+				t.Errorf("URL = %s", cmd.URL)
+			}
+		}},
+		{"unknown options and extra urls warn", "curl --foo -j https://a/ https://b/", func(t *testing.T, cmd *Command) {
+			if cmd.URL != "https://a/" || len(cmd.Warnings) != 3 {
+				t.Errorf("URL=%s Warnings=%v", cmd.URL, cmd.Warnings)
+			}
+		}},
+		{"unsupported option warns", "curl --digest https://a/", func(t *testing.T, cmd *Command) {
+			if len(cmd.Warnings) != 1 || !strings.Contains(cmd.Warnings[0], "--digest") {
+				t.Errorf("Warnings = %v", cmd.Warnings)
+			}
+		}},
+		{"ignored options consume their value", "curl -o out.txt -w '%{http_code}' https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.URL != "https://a/" || len(cmd.Warnings) != 0 {
+				t.Errorf("URL=%s Warnings=%v", cmd.URL, cmd.Warnings)
+			}
+		}},
+		{"transfer options consume their value", "curl --retry 3 --retry-delay 1 --retry-max-time 9 --limit-rate 100K -y 10 -Y 1 --keepalive-time 5 https://example.com/a", func(t *testing.T, cmd *Command) {
+			if cmd.URL != "https://example.com/a" || len(cmd.Warnings) != 0 {
+				t.Errorf("URL=%s Warnings=%v", cmd.URL, cmd.Warnings)
+			}
+		}},
+		{"unsupported value options consume their value", "curl --proxy-header X:1 --interface eth0 --local-port 4000 --unix-socket /s --capath /c --ciphers ALL --cert-type PEM --key-type PEM --pass pw -K cfg https://example.com/a", func(t *testing.T, cmd *Command) {
+			if cmd.URL != "https://example.com/a" || len(cmd.Warnings) != 10 {
+				t.Errorf("URL=%s Warnings=%v", cmd.URL, cmd.Warnings)
+			}
+		}},
+		{"timeouts", "curl -m 2.5 --connect-timeout 1 https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.MaxTime != 2500*time.Millisecond || cmd.ConnectTimeout != time.Second {
+				t.Errorf("MaxTime=%v ConnectTimeout=%v", cmd.MaxTime, cmd.ConnectTimeout)
+			}
+		}},
+		{"resolve", "curl --resolve Example.com:443:[::1],10.0.0.1 https://example.com/", func(t *testing.T, cmd *Command) {
+			if want := map[string]string{"example.com:443": "::1"}; !reflect.DeepEqual(cmd.Resolve, want) {
+				t.Errorf("Resolve = %v", cmd.Resolve)
+			}
+		}},
+		{"cert password is dropped", "curl --cert c.pem:secret https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.Cert != "c.pem" || len(cmd.Warnings) != 1 {
+				t.Errorf("Cert=%s Warnings=%v", cmd.Cert, cmd.Warnings)
+			}
+		}},
+		{"proxy default scheme", "curl -x proxy:8080 https://a/", func(t *testing.T, cmd *Command) {
+			if cmd.Proxy != "http://proxy:8080" {
+				t.Errorf("Proxy = %s", cmd.Proxy)
+			}
+		}},
+		{"post redirects and tls version", "curl --post301 --post303 --no-post303 --tlsv1.2 https://a/", func(t *testing.T, cmd *Command) {
+			if want := map[int]bool{301: true, 303: false}; !reflect.DeepEqual(cmd.PostRedirects, want) {
+				t.Errorf("PostRedirects = %v", cmd.PostRedirects)
+			}
+			if cmd.TLSMinVersion == 0 {
+				t.Error("TLSMinVersion not set")
+			}
+		}},
+		{"location-trusted implies location", "curl --location-trusted https://a/", func(t *testing.T, cmd *Command) {
+			if !cmd.FollowRedirects || !cmd.LocationTrusted {
+				t.Errorf("FollowRedirects=%v LocationTrusted=%v", cmd.FollowRedirects, cmd.LocationTrusted)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.line, err)
+			}
+			tt.check(t, cmd)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	lines := []string{
+		"",
+		"wget https://a/",
+		"curl",
+		"curl -k",
+		"curl ''",
+		"curl https://a/ -d",
+		"curl https://a/ -H",
+		"curl -d a -F b=c https://a/",
+		"curl -d a -T f https://a/",
+		"curl -I -d a https://a/",
+		"curl -G -F a=b https://a/",
+		"curl -d @- https://a/",
+		"curl -T - https://a/",
+		"curl --form-string novalue https://a/",
+		"curl --max-redirs=-2 https://a/",
+		"curl -m abc https://a/",
+		"curl --resolve example.com:443 https://a/",
+		"curl --resolve example.com:99999:1.2.3.4 https://a/",
+		"curl --resolve example.com:443:host https://a/",
+		"curl -H ': v' https://a/",
+		`curl "https://a/`,
+	}
+	for _, line := range lines {
+		if cmd, err := Parse(line); err == nil {
+			t.Errorf("Parse(%q) = %+v, want error", line, cmd)
+		}
+	}
+}
+
+func TestOptionTable(t *testing.T) {
+	longs := map[string]bool{}
+	shorts := map[byte]bool{}
+	for _, opt := range options {
+		if (opt.flag == nil) == (opt.value == nil) {
+			t.Errorf("--%s must have exactly one of flag and value", opt.long)
+		}
+		if opt.long == "" || longs[opt.long] {
+			t.Errorf("duplicate or empty long option %q", opt.long)
+		}
+		longs[opt.long] = true
+		if opt.short != 0 {
+			if shorts[opt.short] {
+				t.Errorf("duplicate short option -%c", opt.short)
+			}
+			shorts[opt.short] = true
+		}
+	}
+
+	tests := []struct {
+		name string
+		long string
+		on   bool
+	}{
+		{"location", "location", true},
+		{"no-location", "location", false},
+		{"no-buffer", "no-buffer", true},
+		{"no-request", "", false},
+		{"unknown", "", false},
+	}
+	for _, tt := range tests {
+		opt, on := lookupLong(tt.name)
+		long := ""
+		if opt != nil {
+			long = opt.long
+		}
+		if long != tt.long || on != tt.on {
+			t.Errorf("lookupLong(%q) = %q, %v, want %q, %v", tt.name, long, on, tt.long, tt.on)
+		}
+	}
+}
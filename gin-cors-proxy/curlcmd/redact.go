@@ -0,0 +1,110 @@
+package curlcmd
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted 替换凭据的占位符
+const redacted = "***"
+
+// sensitiveHeaders 日志和保存的记录中隐藏值的请求头和响应头
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// RedactURL 隐藏URL中的密码和查询参数的值，保留参数名，去掉片段，用于日志和保存的记录；无法解析时去掉?之后的部分
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if i := strings.IndexAny(raw, "?#"); i >= 0 {
+			raw = raw[:i]
+		}
+		return raw
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			// 没有=的参数本身可能就是令牌，整个隐藏
+			if name, _, ok := strings.Cut(param, "="); ok {
+				params[i] = name + "=" + redacted
+			} else if param != "" {
+				params[i] = redacted
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	u.Fragment, u.RawFragment = "", ""
+	// 密码替换为xxxxx
+	return u.Redacted()
+}
+
+// RedactHeaderValue 隐藏Authorization、Cookie等凭据头的值，Authorization保留认证方式，如Bearer ***；
+// Location中的地址按RedactURL处理
+func RedactHeaderValue(key, value string) string {
+	key = http.CanonicalHeaderKey(key)
+	if key == "Location" {
+		return RedactURL(value)
+	}
+	if !sensitiveHeaders[key] {
+		return value
+	}
+	if scheme, _, ok := strings.Cut(value, " "); ok && strings.HasSuffix(key, "Authorization") {
+		return scheme + " " + redacted
+	}
+	return redacted
+}
+
+// RedactHeader 返回隐藏了凭据头的值的副本
+func RedactHeader(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+	result := make(http.Header, len(header))
+	for key, values := range header {
+		copied := make([]string, len(values))
+		for i, value := range values {
+			copied[i] = RedactHeaderValue(key, value)
+		}
+		result[key] = copied
+	}
+	return result
+}
+
+// RedactError 隐藏请求错误中URL的密码和查询参数，net/http的错误信息包含完整的请求地址
+func RedactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	return &url.Error{Op: urlErr.Op, URL: RedactURL(urlErr.URL), Err: urlErr.Err}
+}
+
+// Redacted 返回隐藏了最终地址和重定向地址中的查询参数、以及响应头中Cookie等凭据的副本，用于保存请求记录
+func (r Result) Redacted() Result {
+	r.EffectiveURL = RedactURL(r.EffectiveURL)
+	if r.ResponseHeaders != nil {
+		headers := make(map[string]string, len(r.ResponseHeaders))
+		for key, value := range r.ResponseHeaders {
+			headers[key] = RedactHeaderValue(key, value)
+		}
+		r.ResponseHeaders = headers
+	}
+	if r.Redirects != nil {
+		hops := make([]Hop, len(r.Redirects))
+		for i, hop := range r.Redirects {
+			hop.URL = RedactURL(hop.URL)
+			hop.Location = RedactURL(hop.Location)
+			hop.Headers = RedactHeader(hop.Headers)
+			hops[i] = hop
+		}
+		r.Redirects = hops
+	}
+	return r
+}
@@ -0,0 +1,174 @@
+package curlcmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxRedirs 未指定--max-redirs时最多跟随的重定向次数，与curl相同
+const DefaultMaxRedirs = 50
+
+// Hop 跟随重定向时途经的一个3xx响应
+type Hop struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"statusCode"`
+	Headers    http.Header `json:"headers"` // 保留多个Set-Cookie等重复的响应头
+	Location   string      `json:"location"`
+	Timing     Timing      `json:"timing"`
+}
+
+// Do 发送请求。指定-L时按curl的规则跟随重定向：301、302把POST改为GET（--post301、--post302除外），
+// 303把HEAD以外的方法改为GET（--post303时保持POST），307、308保持方法和请求体；
+// -X指定的方法在重定向后保持不变。重定向到其他主机时不再发送Authorization和-H指定的Cookie（--location-trusted除外）。
+// 指定了-b时启用Cookie引擎，途中响应设置的Cookie会发送给后续请求。
+// 返回最终响应和途经的重定向响应，出错时也返回已经过的重定向
+func (cmd *Command) Do(client *http.Client, req *http.Request, files Files) (*http.Response, []Hop, error) {
+	var (
+		hops   []Hop
+		jar    *cookiejar.Jar
+		origin = req.URL
+	)
+	if len(cmd.Cookies) > 0 {
+		jar, _ = cookiejar.New(nil)
+	}
+
+	for {
+		if err := cmd.checkDestination(req.URL); err != nil {
+			return nil, hops, err
+		}
+		var tracer Tracer
+		resp, err := client.Do(tracer.Trace(req))
+		if err != nil {
+			return nil, hops, err
+		}
+
+		location := resp.Header.Get("Location")
+		if !cmd.FollowRedirects || !isRedirect(resp.StatusCode) || location == "" {
+			return resp, hops, nil
+		}
+		if cmd.MaxRedirs >= 0 && len(hops) >= cmd.MaxRedirs {
+			resp.Body.Close()
+			return nil, hops, fmt.Errorf("重定向次数超过上限%d，可以用--max-redirs调整", cmd.MaxRedirs)
+		}
+
+		// 读完中间响应的响应体，以便复用连接
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		hops = append(hops, Hop{
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header,
+			Location:   location,
+			Timing:     tracer.Timing(time.Now()),
+		})
+		if jar != nil {
+			jar.SetCookies(req.URL, resp.Cookies())
+		}
+
+		next, err := req.URL.Parse(location)
+		if err != nil {
+			return nil, hops, fmt.Errorf("重定向地址无效: %s", location)
+		}
+		if next.Scheme != "http" && next.Scheme != "https" {
+			return nil, hops, fmt.Errorf("不支持重定向到%s协议", next.Scheme)
+		}
+		if req, err = cmd.redirectRequest(req, resp.StatusCode, next, origin, jar, files); err != nil {
+			return nil, hops, err
+		}
+	}
+}
+
+// checkDestination 设置了Destinations时检查请求地址的主机和端口
+func (cmd *Command) checkDestination(u *url.URL) error {
+	if cmd.Destinations == nil {
+		return nil
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		port = 80
+		if u.Scheme == "https" {
+			port = 443
+		}
+	}
+	if err := cmd.Destinations.CheckHost(u.Hostname(), port); err != nil {
+		return &DestinationError{Err: err}
+	}
+	return nil
+}
+
+// isRedirect 是否为需要跟随的重定向状态码
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectRequest 生成跟随重定向的下一个请求
+func (cmd *Command) redirectRequest(prev *http.Request, status int, next, origin *url.URL, jar *cookiejar.Jar, files Files) (*http.Request, error) {
+	method, keepBody := prev.Method, true
+	switch {
+	case status == http.StatusSeeOther && prev.Method != http.MethodHead:
+		if !(prev.Method == http.MethodPost && cmd.PostRedirects[status]) {
+			method, keepBody = http.MethodGet, false
+		}
+	case (status == http.StatusMovedPermanently || status == http.StatusFound) && prev.Method == http.MethodPost:
+		if !cmd.PostRedirects[status] {
+			method, keepBody = http.MethodGet, false
+		}
+	}
+	// 与curl一样，-X指定的方法始终保持，只按上面的规则决定是否发送请求体
+	if cmd.methodSet {
+		method = prev.Method
+	}
+
+	var body io.ReadCloser
+	if keepBody && prev.GetBody != nil {
+		var err error
+		if body, err = prev.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(prev.Context(), method, next.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+	if keepBody {
+		req.GetBody, req.ContentLength = prev.GetBody, prev.ContentLength
+	}
+
+	req.Header = prev.Header.Clone()
+	req.Header.Del("Cookie")
+	if !keepBody {
+		req.Header.Del("Content-Type")
+		req.Header.Del("Content-Length")
+	}
+	sameOrigin := next.Scheme == origin.Scheme && next.Host == origin.Host
+	if sameOrigin || cmd.LocationTrusted {
+		if cookie := cmd.Header.Get("Cookie"); cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+	} else {
+		req.Header.Del("Authorization")
+	}
+	if err := cmd.addCookies(req.Header, next.String(), files); err != nil {
+		return nil, err
+	}
+	if jar != nil {
+		var cookies []string
+		for _, c := range jar.Cookies(next) {
+			cookies = append(cookies, c.Name+"="+c.Value)
+		}
+		appendCookies(req.Header, cookies)
+	}
+	return req, nil
+}
@@ -0,0 +1,155 @@
+package curlcmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// redirectServer /echo在X-Echo响应头中返回收到的方法、请求体、Authorization和Cookie（HEAD请求也能读到）；
+// /r/<状态码>?to=<地址> 以该状态码重定向，默认到本机/echo；/chain/<n> 连续重定向n次后到/echo
+func redirectServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Echo", fmt.Sprintf("%s|%s|%s|%s", r.Method, body, r.Header.Get("Authorization"), r.Header.Get("Cookie")))
+	})
+	mux.HandleFunc("/r/", func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/r/"))
+		to := r.URL.Query().Get("to")
+		if to == "" {
+			to = "/echo"
+		}
+		w.Header().Set("Location", to)
+		w.WriteHeader(status)
+	})
+	mux.HandleFunc("/chain/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/chain/"))
+		if n <= 0 {
+			http.Redirect(w, r, "/echo", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/chain/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRedirectRequest(t *testing.T) {
+	origin := redirectServer()
+	defer origin.Close()
+	other := redirectServer()
+	defer other.Close()
+	toOther := "?to=" + url.QueryEscape(other.URL+"/echo")
+
+	tests := []struct {
+		name string
+		args string // 追加在curl -L之后，{o}为起始服务器地址
+		want string // 最终请求的 方法|请求体|Authorization|Cookie
+		hops int
+	}{
+		{"301 turns POST into GET", "-d a=1 {o}/r/301", "GET|||", 1},
+		{"302 turns POST into GET", "-d a=1 {o}/r/302", "GET|||", 1},
+		{"303 turns POST into GET", "-d a=1 {o}/r/303", "GET|||", 1},
+		{"303 keeps HEAD", "-I {o}/r/303", "HEAD|||", 1},
+		{"307 keeps POST and body", "-d a=1 {o}/r/307", "POST|a=1||", 1},
+		{"308 keeps POST and body", "-d a=1 {o}/r/308", "POST|a=1||", 1},
+		{"--post301 keeps POST", "--post301 -d a=1 {o}/r/301", "POST|a=1||", 1},
+		{"--post302 keeps POST", "--post302 -d a=1 {o}/r/302", "POST|a=1||", 1},
+		{"--post303 keeps POST", "--post303 -d a=1 {o}/r/303", "POST|a=1||", 1},
+		{"--post301 does not apply to 302", "--post301 -d a=1 {o}/r/302", "GET|||", 1},
+		{"-X keeps method but drops body on 303", "-X POST -d a=1 {o}/r/303", "POST|||", 1},
+		{"-X keeps method on 301", "-X PUT -d a=1 {o}/r/301", "PUT|a=1||", 1},
+		{"-X keeps method on 307", "-X DELETE {o}/r/307", "DELETE|||", 1},
+		{"same host keeps credentials", "-H 'Authorization: Bearer t' -H 'Cookie: s=1' {o}/r/302", "GET||Bearer t|s=1", 1},
+		{"other host drops credentials", "-H 'Authorization: Bearer t' -H 'Cookie: s=1' '{o}/r/302" + toOther + "'", "GET|||", 1},
+		{"--location-trusted keeps credentials", "--location-trusted -H 'Authorization: Bearer t' -H 'Cookie: s=1' '{o}/r/302" + toOther + "'", "GET||Bearer t|s=1", 1},
+		{"-u dropped on other host", "-u user:pw '{o}/r/302" + toOther + "'", "GET|||", 1},
+		{"redirect chain", "{o}/chain/3", "GET|||", 4},
+		{"max-redirs allows the limit", "--max-redirs 4 {o}/chain/3", "GET|||", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			echo, hops, err := runRedirect(t, "curl -L "+strings.ReplaceAll(tt.args, "{o}", origin.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if echo != tt.want {
+				t.Errorf("final request = %q, want %q", echo, tt.want)
+			}
+			if len(hops) != tt.hops {
+				t.Errorf("hops = %d, want %d", len(hops), tt.hops)
+			}
+		})
+	}
+}
+
+func TestRedirectLimits(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		line string
+		hops int
+		err  string
+	}{
+		{"max-redirs exceeded", "curl -L --max-redirs 2 " + server.URL + "/chain/3", 2, "--max-redirs"},
+		{"max-redirs 0", "curl -L --max-redirs 0 " + server.URL + "/r/302", 0, "--max-redirs"},
+		{"unsupported scheme", "curl -L '" + server.URL + "/r/302?to=ftp://example.com/'", 1, "ftp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, hops, err := runRedirect(t, tt.line)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want it to mention %s", err, tt.err)
+			}
+			if len(hops) != tt.hops {
+				t.Errorf("hops = %d, want %d", len(hops), tt.hops)
+			}
+		})
+	}
+
+	// 不指定-L时返回3xx响应本身
+	cmd, err := Parse("curl " + server.URL + "/r/302")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ := cmd.NewClient(nil)
+	req, _ := cmd.NewRequest(nil)
+	resp, hops, err := cmd.Do(client, req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || len(hops) != 0 {
+		t.Errorf("without -L status = %d, hops = %d", resp.StatusCode, len(hops))
+	}
+}
+
+// runRedirect 执行命令，返回最终响应的X-Echo头和途经的重定向
+func runRedirect(t *testing.T, line string) (string, []Hop, error) {
+	t.Helper()
+	cmd, err := Parse(line)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", line, err)
+	}
+	client, err := cmd.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := cmd.NewRequest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, hops, err := cmd.Do(client, req, nil)
+	if err != nil {
+		return "", hops, err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("X-Echo"), hops, nil
+}
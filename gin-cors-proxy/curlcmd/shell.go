@@ -0,0 +1,417 @@
+package curlcmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// cmdContinuation Windows cmd的续行符：行尾的^
+var cmdContinuation = regexp.MustCompile(`\^\r?\n`)
+
+// Split 把命令行拆分为参数列表。默认按POSIX shell规则处理单双引号、反斜杠转义、$'...'和行尾反斜杠续行；
+// POSIX引号之外出现行尾^续行或^"时按Windows cmd规则处理（浏览器"复制为cURL(cmd)"的格式）。
+// 不做变量展开、通配符和管道等处理
+func Split(line string) ([]string, error) {
+	if isCmdLine(line) {
+		return splitCmd(line)
+	}
+	return splitPOSIX(line)
+}
+
+// isCmdLine 按POSIX规则跳过引号、转义和注释，检查其余部分是否有^"或行尾^续行。
+// 引号中的^是参数内容，如-d '{"re":"^\"a"}'仍按POSIX处理；引号未闭合时（POSIX规则下无法拆分）检查整个命令
+func isCmdLine(line string) bool {
+	unclosed := func() bool { return cmdContinuation.MatchString(line) || strings.Contains(line, `^"`) }
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\':
+			i++
+		case c == '#' && (i == 0 || strings.IndexByte(" \t\r\n", line[i-1]) >= 0):
+			for i < len(line) && line[i] != '\n' {
+				i++
+			}
+		case c == '\'':
+			// $'...'中可以用\'转义单引号，普通单引号中没有转义
+			escapes := i > 0 && line[i-1] == '$'
+			if i = closingQuote(line, i+1, '\'', escapes); i < 0 {
+				return unclosed()
+			}
+		case c == '"':
+			if i = closingQuote(line, i+1, '"', true); i < 0 {
+				return unclosed()
+			}
+		case c == '^':
+			if rest := line[i+1:]; strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// closingQuote 返回从start开始第一个未转义的quote的位置，escapes为true时反斜杠转义下一个字符；没有时返回-1
+func closingQuote(line string, start int, quote byte, escapes bool) int {
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// splitPOSIX 按POSIX shell规则拆分参数
+func splitPOSIX(line string) ([]string, error) {
+	var (
+		args   []string
+		word   strings.Builder
+		inWord bool
+		runes  = []rune(line)
+		n      = len(runes)
+		flush  = func() {
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		}
+	)
+
+	for i := 0; i < n; i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+
+		case r == '#' && !inWord:
+			// 注释，忽略到行尾
+			for i < n && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '\\':
+			if i+1 >= n {
+				inWord = true
+				word.WriteRune(r)
+				break
+			}
+			i++
+			switch {
+			case runes[i] == '\n':
+				// 续行
+			case runes[i] == '\r' && i+1 < n && runes[i+1] == '\n':
+				i++
+			default:
+				inWord = true
+				word.WriteRune(runes[i])
+			}
+
+		case r == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("单引号未闭合")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+
+		case r == '"' || (r == '$' && i+1 < n && runes[i+1] == '"'):
+			inWord = true
+			if r == '$' {
+				i++
+			}
+			end, err := readDoubleQuoted(runes, i+1, &word)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case r == '$' && i+1 < n && runes[i+1] == '\'':
+			inWord = true
+			end, err := readANSIC(runes, i+2, &word)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return args, nil
+}
+
+// readDoubleQuoted 读取双引号内的内容，反斜杠只转义$ ` " \和换行，返回右引号的位置
+func readDoubleQuoted(runes []rune, start int, word *strings.Builder) (int, error) {
+	for i := start; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 < len(runes) {
+				switch next := runes[i+1]; next {
+				case '$', '`', '"', '\\':
+					word.WriteRune(next)
+					i++
+					continue
+				case '\n':
+					i++
+					continue
+				case '\r':
+					if i+2 < len(runes) && runes[i+2] == '\n' {
+						i += 2
+						continue
+					}
+				}
+			}
+			word.WriteRune(r)
+		default:
+			word.WriteRune(r)
+		}
+	}
+	return 0, fmt.Errorf("双引号未闭合")
+}
+
+// readANSIC 读取$'...'中的内容并处理C风格转义，返回右引号的位置
+func readANSIC(runes []rune, start int, word *strings.Builder) (int, error) {
+	for i := start; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\'' {
+			return i, nil
+		}
+		if r != '\\' || i+1 >= len(runes) {
+			word.WriteRune(r)
+			continue
+		}
+		i++
+		switch esc := runes[i]; esc {
+		case 'a':
+			word.WriteByte('\a')
+		case 'b':
+			word.WriteByte('\b')
+		case 'e', 'E':
+			word.WriteByte(0x1b)
+		case 'f':
+			word.WriteByte('\f')
+		case 'n':
+			word.WriteByte('\n')
+		case 'r':
+			word.WriteByte('\r')
+		case 't':
+			word.WriteByte('\t')
+		case 'v':
+			word.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			word.WriteRune(esc)
+		case 'c':
+			// 控制字符，如\cA
+			if i+1 < len(runes) {
+				i++
+				word.WriteByte(byte(runes[i]) & 0x1f)
+			}
+		case 'x', 'u', 'U':
+			maxDigits := map[rune]int{'x': 2, 'u': 4, 'U': 8}[esc]
+			digits := readDigits(runes, i+1, maxDigits, 16)
+			if digits == "" {
+				word.WriteRune('\\')
+				word.WriteRune(esc)
+				break
+			}
+			i += len(digits)
+			v, _ := strconv.ParseUint(digits, 16, 32)
+			if esc == 'x' {
+				// \xHH表示单个字节，可以拼出UTF-8编码的字符
+				word.WriteByte(byte(v))
+			} else if utf8.ValidRune(rune(v)) {
+				word.WriteRune(rune(v))
+			}
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			digits := readDigits(runes, i, 3, 8)
+			i += len(digits) - 1
+			v, _ := strconv.ParseUint(digits, 8, 32)
+			word.WriteByte(byte(v))
+		default:
+			word.WriteRune('\\')
+			word.WriteRune(esc)
+		}
+	}
+	return 0, fmt.Errorf("$'引号未闭合")
+}
+
+// readDigits 从start开始读取最多max位指定进制的数字
+func readDigits(runes []rune, start, max, base int) string {
+	end := start
+	for end < len(runes) && end-start < max {
+		if _, err := strconv.ParseUint(string(runes[end]), base, 8); err != nil {
+			break
+		}
+		end++
+	}
+	return string(runes[start:end])
+}
+
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitCmd 按Windows规则拆分参数：先由cmd处理^转义和续行（cmd的双引号内^不转义），
+// 再按curl.exe的命令行规则（MSVC运行库）处理双引号和反斜杠
+func splitCmd(line string) ([]string, error) {
+	var (
+		unescaped strings.Builder
+		quoted    bool
+		runes     = []rune(line)
+	)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"':
+			quoted = !quoted
+			unescaped.WriteRune(r)
+		case r == '^' && !quoted && i+1 < len(runes):
+			i++
+			switch {
+			case runes[i] == '\n':
+			case runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n':
+				i++
+			default:
+				// ^"是cmd眼中的普通字符，对curl.exe仍是引号
+				unescaped.WriteRune(runes[i])
+			}
+		default:
+			unescaped.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("双引号未闭合")
+	}
+	return splitMSVC(unescaped.String()), nil
+}
+
+// splitMSVC 按MSVC运行库的规则拆分参数：2n个反斜杠加引号得到n个反斜杠并切换引号状态，
+// 2n+1个反斜杠加引号得到n个反斜杠和一个引号；引号内的""表示一个引号
+func splitMSVC(line string) []string {
+	var (
+		args   []string
+		word   strings.Builder
+		inWord bool
+		quoted bool
+		runes  = []rune(line)
+	)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case (r == ' ' || r == '\t' || r == '\n' || r == '\r') && !quoted:
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\\':
+			inWord = true
+			slashes := 0
+			for i < len(runes) && runes[i] == '\\' {
+				slashes++
+				i++
+			}
+			if i < len(runes) && runes[i] == '"' {
+				word.WriteString(strings.Repeat(`\`, slashes/2))
+				if slashes%2 == 1 {
+					word.WriteRune('"')
+					continue
+				}
+			} else {
+				word.WriteString(strings.Repeat(`\`, slashes))
+			}
+			i--
+		case r == '"':
+			inWord = true
+			if quoted && i+1 < len(runes) && runes[i+1] == '"' {
+				word.WriteRune('"')
+				i++
+				continue
+			}
+			quoted = !quoted
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args
+}
+
+// safeWord 不需要加引号的参数
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Join 把参数列表拼成命令行，是Split的逆操作：Split(Join(args))得到原样的args。
+// 含特殊字符的参数用单引号括起；含^、控制字符或无效UTF-8的参数用$'...'转义，避免被当作Windows cmd格式
+func Join(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quote 按POSIX shell规则给单个参数加引号
+func quote(arg string) string {
+	if safeWord.MatchString(arg) {
+		return arg
+	}
+	if !needsANSIC(arg) {
+		return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(arg); {
+		r, size := utf8.DecodeRuneInString(arg[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&b, `\x%02x`, arg[i])
+		case r == '\\' || r == '\'' || r == '"':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// needsANSIC 参数是否需要用$'...'表示
+func needsANSIC(arg string) bool {
+	if !utf8.ValidString(arg) || strings.ContainsRune(arg, '^') {
+		return true
+	}
+	for _, r := range arg {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
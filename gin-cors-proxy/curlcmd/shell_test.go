@@ -0,0 +1,176 @@
+package curlcmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPOSIX(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"plain words", "curl  -k\thttp://a/", []string{"curl", "-k", "http://a/"}},
+		{"nested quotes", `curl -H "X: 'a b'" -d '{"k":"v"}'`, []string{"curl", "-H", "X: 'a b'", "-d", `{"k":"v"}`}},
+		{"adjacent quotes join", `a'b c'"d e"f`, []string{"ab cd ef"}},
+		{"single quote keeps backslash", `'a\nb\'`, []string{`a\nb\`}},
+		{"double quote escapes", `"a\"b\$c\\d\n"`, []string{`a"b$c\d\n`}},
+		{"escaped space", `a\ b c`, []string{"a b", "c"}},
+		{"backslash continuation", "curl \\\n  -k \\\r\n  url", []string{"curl", "-k", "url"}},
+		{"continuation inside double quotes", "\"a\\\nb\"", []string{"ab"}},
+		{"trailing backslash", `a\`, []string{`a\`}},
+		{"empty quotes", `curl '' ""`, []string{"curl", "", ""}},
+		{"comment", "curl url # -k\n-L", []string{"curl", "url", "-L"}},
+		{"hash inside word", "a#b", []string{"a#b"}},
+		{"ansi-c escapes", `$'a\nb\tc\'d\\'`, []string{"a\nb\tc'd\\"}},
+		{"ansi-c hex and unicode", `$'\x41é\U0001F600\xe4\xb8\xad'`, []string{"Aé😀中"}},
+		{"ansi-c octal and control", `$'\101\0\cA'`, []string{"A\x00\x01"}},
+		{"ansi-c unknown escape", `$'\q\x'`, []string{`\q\x`}},
+		{"dollar double quote", `$"a b"`, []string{"a b"}},
+		{"empty line", "  \n ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitCmd(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{
+			"caret continuation",
+			"curl ^\"http://a/?x=1^&y=2^\" ^\n  -H ^\"X: y^\" ^\r\n  --compressed",
+			[]string{"curl", "http://a/?x=1&y=2", "-H", "X: y", "--compressed"},
+		},
+		{
+			"escaped quotes in json",
+			`curl ^"http://a/^" --data-raw ^"^{^\^"k^\^":^\^"a b^\^"^}^"`,
+			[]string{"curl", "http://a/", "--data-raw", `{"k":"a b"}`},
+		},
+		{
+			"caret inside cmd quotes is literal",
+			"curl \"a^b\" ^\n x",
+			[]string{"curl", "a^b", "x"},
+		},
+		{
+			"percent and ampersand",
+			`curl ^"http://a/?q=100^%^&r=1^"`,
+			[]string{"curl", "http://a/?q=100%&r=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitCaretInPOSIXQuotes POSIX引号中的^"和^续行是参数内容，不切换为cmd规则
+func TestSplitCaretInPOSIXQuotes(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"single quotes", `curl -d '{"re":"^\"a"}' x`, []string{"curl", "-d", `{"re":"^\"a"}`, "x"}},
+		{"double quotes", `curl -d "re=^\"a" x`, []string{"curl", "-d", `re=^"a`, "x"}},
+		{"ansi-c quotes", `curl -d $'^"a\'^"' x`, []string{"curl", "-d", `^"a'^"`, "x"}},
+		{"caret continuation inside quotes", "curl -d 'a ^\nb' x", []string{"curl", "-d", "a ^\nb", "x"}},
+		{"escaped caret", `curl -d \^"a"`, []string{"curl", "-d", `^a`}},
+		{"comment", "curl x # ^\"\n-k", []string{"curl", "x", "-k"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMSVC(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`a b`, []string{"a", "b"}},
+		{`"a b" c`, []string{"a b", "c"}},
+		{`a\\b`, []string{`a\\b`}},
+		{`\"a`, []string{`"a`}},
+		{`\\"a b"`, []string{`\a b`}},
+		{`\\\"a`, []string{`\"a`}},
+		{`"a""b"`, []string{`a"b`}},
+		{`""`, []string{""}},
+		{`a"b c"d`, []string{"ab cd"}},
+	}
+	for _, tt := range tests {
+		if got := splitMSVC(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitMSVC(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	lines := []string{
+		`curl 'abc`,
+		`curl "abc`,
+		`curl "a\"`,
+		`curl $'abc`,
+		`curl $'abc\'`,
+		"curl \"abc ^\n",
+	}
+	for _, line := range lines {
+		if args, err := Split(line); err == nil {
+			t.Errorf("Split(%q) = %q, want error", line, args)
+		}
+	}
+}
+
+func TestJoinRoundTrip(t *testing.T) {
+	args := []string{
+		"curl",
+		"http://a/?x=1&y=2",
+		"it's",
+		`{"k":"v"}`,
+		"a b",
+		"",
+		"^\"",
+		"line1\nline2",
+		"tab\there",
+		"\x00\x7f",
+		"\xff\xfe",
+		`back\slash`,
+		"中文",
+		"$HOME `id`",
+	}
+	line := Join(args)
+	got, err := Split(line)
+	if err != nil {
+		t.Fatalf("Split(%q): %v", line, err)
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("Split(Join(args)) = %q, want %q", got, args)
+	}
+}
@@ -0,0 +1,133 @@
+package curlcmd
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TLSInfo HTTPS连接的协商结果和服务器出示的证书链
+type TLSInfo struct {
+	Version      string     `json:"version,omitempty"`     // 如TLS 1.3，握手失败时为空
+	CipherSuite  string     `json:"cipherSuite,omitempty"` // 握手失败时为空
+	ALPN         string     `json:"alpn,omitempty"`        // 协商的应用层协议，如h2、http/1.1
+	ServerName   string     `json:"serverName,omitempty"`
+	Verified     bool       `json:"verified"`              // 证书链是否通过验证
+	VerifyError  string     `json:"verifyError,omitempty"` // 证书验证失败的具体原因，--insecure时也会给出
+	Certificates []CertInfo `json:"certificates"`          // 服务器出示的证书，第一个是服务器证书
+}
+
+// CertInfo 证书的主要信息
+type CertInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SANs         []string  `json:"sans,omitempty"` // 使用者可选名称：域名、IP、邮箱、URI
+	SerialNumber string    `json:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+	IsCA         bool      `json:"isCA"`
+	SHA256       string    `json:"sha256"` // 证书DER编码的SHA-256指纹，冒号分隔的十六进制
+}
+
+// tlsVersionNames TLS版本号对应的名称
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// TLSInfo 返回响应所用HTTPS连接的信息，不是HTTPS时返回nil。
+// 指定-k时握手不验证证书，这里按命令中的--cacert（或系统证书）重新验证，给出验证结果
+func (cmd *Command) TLSInfo(resp *http.Response) *TLSInfo {
+	state := resp.TLS
+	if state == nil {
+		return nil
+	}
+
+	version, ok := tlsVersionNames[state.Version]
+	if !ok {
+		version = fmt.Sprintf("0x%04x", state.Version)
+	}
+	info := &TLSInfo{
+		Version:      version,
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		ServerName:   resp.Request.URL.Hostname(),
+		Certificates: certInfos(state.PeerCertificates),
+	}
+	if len(state.VerifiedChains) > 0 {
+		info.Verified = true
+		return info
+	}
+	if len(state.PeerCertificates) == 0 {
+		info.VerifyError = "服务器没有出示证书"
+		return info
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         cmd.rootCAs,
+		DNSName:       info.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := state.PeerCertificates[0].Verify(opts); err != nil {
+		info.VerifyError = err.Error()
+	} else {
+		info.Verified = true
+	}
+	return info
+}
+
+// TLSErrorInfo 从请求错误中取出证书验证失败时服务器出示的证书和失败原因，不是证书验证错误时返回nil
+func TLSErrorInfo(err error, serverName string) *TLSInfo {
+	var verifyErr *tls.CertificateVerificationError
+	if !errors.As(err, &verifyErr) {
+		return nil
+	}
+	return &TLSInfo{
+		ServerName:   serverName,
+		VerifyError:  verifyErr.Err.Error(),
+		Certificates: certInfos(verifyErr.UnverifiedCertificates),
+	}
+}
+
+// certInfos 提取证书链中每个证书的信息
+func certInfos(certs []*x509.Certificate) []CertInfo {
+	infos := make([]CertInfo, 0, len(certs))
+	for _, cert := range certs {
+		sans := append([]string(nil), cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		sans = append(sans, cert.EmailAddresses...)
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+
+		sum := sha256.Sum256(cert.Raw)
+		fingerprint := make([]string, len(sum))
+		for i, b := range sum {
+			fingerprint[i] = fmt.Sprintf("%02X", b)
+		}
+
+		infos = append(infos, CertInfo{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SANs:         sans,
+			SerialNumber: fmt.Sprintf("%X", cert.SerialNumber),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			IsCA:         cert.IsCA,
+			SHA256:       strings.Join(fingerprint, ":"),
+		})
+	}
+	return infos
+}
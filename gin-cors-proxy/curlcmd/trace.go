@@ -0,0 +1,119 @@
+package curlcmd
+
+import (
+	"crypto/tls"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing 请求各阶段的耗时，单位毫秒。DNS、Connect、TLS是对应阶段本身的耗时，复用连接或直接使用IP时为0；
+// TTFB是从开始请求到收到响应第一个字节（与curl的time_starttransfer相同），Download是此后读取响应体的耗时
+type Timing struct {
+	DNS      float64 `json:"dns"`
+	Connect  float64 `json:"connect"`
+	TLS      float64 `json:"tls"`
+	TTFB     float64 `json:"ttfb"`
+	Download float64 `json:"download"`
+	Total    float64 `json:"total"`
+}
+
+// Tracer 通过httptrace记录请求各阶段的时间和所用的连接，跟随重定向时DNS、连接、TLS耗时累加，连接信息取最后一次请求
+type Tracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	firstByte    time.Time
+	dns          time.Duration
+	connect      time.Duration
+	tls          time.Duration
+
+	RemoteIP   string // 实际连接的IP，使用代理时为代理的IP
+	RemotePort int
+	Reused     bool // 是否复用了已有连接
+}
+
+// Trace 返回带有跟踪钩子的请求，并把当前时间作为请求开始时间
+func (t *Tracer) Trace(req *http.Request) *http.Request {
+	t.start = time.Now()
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns += time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		// 同时尝试多个地址时可能触发多次，只统计第一次开始到成功建立连接
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			if err == nil && !t.connectStart.IsZero() {
+				t.connect += time.Since(t.connectStart)
+				t.connectStart = time.Time{}
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls += time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.Reused = info.Reused
+			if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+				t.RemoteIP, t.RemotePort = addr.IP.String(), addr.Port
+			}
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			t.mu.Unlock()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// Timing 以end为请求结束时间（通常是读完响应体的时间）计算各阶段耗时
+func (t *Tracer) Timing(end time.Time) Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := Timing{
+		DNS:     milliseconds(t.dns),
+		Connect: milliseconds(t.connect),
+		TLS:     milliseconds(t.tls),
+		Total:   milliseconds(end.Sub(t.start)),
+	}
+	if !t.firstByte.IsZero() {
+		timing.TTFB = milliseconds(t.firstByte.Sub(t.start))
+		timing.Download = milliseconds(end.Sub(t.firstByte))
+	}
+	return timing
+}
+
+// milliseconds 把时长转换为毫秒，保留三位小数
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
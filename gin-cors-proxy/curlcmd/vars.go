@@ -0,0 +1,44 @@
+package curlcmd
+
+import "regexp"
+
+var (
+	// variablePattern {{name}} 形式的变量占位符，花括号内允许首尾空白
+	variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+	variableName    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+)
+
+// ValidVariableName 变量名是否合法：字母或下划线开头，由字母、数字、下划线、点和短横线组成
+func ValidVariableName(name string) bool {
+	return variableName.MatchString(name)
+}
+
+// Expand 把命令中的{{name}}替换为vars中的值。先按Split拆分参数，再在每个参数内替换，最后用Join拼回命令行，
+// 变量值中的空格、引号等只是参数的一部分，不会拆出新的选项，来自响应的值也可以安全地使用。
+// 返回替换后的命令和未定义的变量名（按出现顺序去重），未定义的占位符保持原样；没有占位符时原样返回命令
+func Expand(command string, vars map[string]string) (string, []string, error) {
+	if !variablePattern.MatchString(command) {
+		return command, nil, nil
+	}
+	args, err := Split(command)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var undefined []string
+	seen := make(map[string]bool)
+	for i, arg := range args {
+		args[i] = variablePattern.ReplaceAllStringFunc(arg, func(placeholder string) string {
+			name := variablePattern.FindStringSubmatch(placeholder)[1]
+			if value, ok := vars[name]; ok {
+				return value
+			}
+			if !seen[name] {
+				seen[name] = true
+				undefined = append(undefined, name)
+			}
+			return placeholder
+		})
+	}
+	return Join(args), undefined, nil
+}
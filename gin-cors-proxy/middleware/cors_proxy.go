@@ -1,39 +1,50 @@
 package middleware
 
 import (
-	"crypto/tls"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
 )
 
 // CurlRequest 定义请求体结构
 type CurlRequest struct {
-	CurlParam string `json:"curlParam" binding:"required"`
+	CurlParam string `json:"curlParam" form:"curlParam" binding:"required"`
+	// Files 命令中@file、<file引用的文件，键为文件名，值为base64编码的内容；
+	// 也可以用multipart/form-data上传，文件名即为键
+	Files map[string]string `json:"files" form:"-"`
+	// Raw 为true时直接返回上游的状态码、响应头和响应体，而不是JSON格式的CurlResponse
+	Raw bool `json:"raw" form:"raw"`
 }
 
 // CurlResponse 定义响应体结构
 type CurlResponse struct {
-	ExecutionTime   string            `json:"executionTime"`
-	StatusCode      int               `json:"statusCode"`
-	ResponseBody    string            `json:"responseBody"`
-	ResponseHeaders map[string]string `json:"responseHeaders"`
-	Error           string            `json:"error,omitempty"`
+	ExecutionTime string `json:"executionTime"`
+	curlcmd.Result
+	Error string `json:"error,omitempty"`
 }
 
+// MaxBodySize 返回的响应体的最大字节数，超出部分截断；raw模式直接转发，不受限制
+var MaxBodySize int64 = 10 << 20
+
 // CorsProxyMiddleware 返回一个处理CORS代理请求的中间件
 func CorsProxyMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 允许所有来源的CORS请求
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, PATCH, HEAD")
+		// 透传代理会转发任意请求头，预检请求中声明的请求头都允许
+		allowHeaders := "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization"
+		if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			allowHeaders = requested
+		}
+		c.Writer.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		// 允许前端读取透传代理返回的全部上游响应头
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "*")
 
 		// 处理预检请求
 		if c.Request.Method == "OPTIONS" {
@@ -48,23 +59,22 @@ func CorsProxyMiddleware() gin.HandlerFunc {
 
 // HandleCurlProxy 处理curl代理请求
 func HandleCurlProxy(c *gin.Context) {
-	var request CurlRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
+	request, files, err := bindCurlRequest(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if request.Raw {
+		streamCurlAsHTTP(c, request.CurlParam, files)
+		return
+	}
+
 	// 解析curl命令并执行HTTP请求
+	var response CurlResponse
 	startTime := time.Now()
-	statusCode, responseBody, responseHeaders, err := executeCurlAsHTTP(request.CurlParam)
-	executionTime := time.Since(startTime).String()
-
-	response := CurlResponse{
-		ExecutionTime:   executionTime,
-		StatusCode:      statusCode,
-		ResponseBody:    responseBody,
-		ResponseHeaders: responseHeaders,
-	}
+	err = executeCurlAsHTTP(request.CurlParam, files, &response)
+	response.ExecutionTime = time.Since(startTime).String()
 
 	if err != nil {
 		response.Error = err.Error()
@@ -73,185 +83,60 @@ func HandleCurlProxy(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// CurlCommand 表示解析后的curl命令
-type CurlCommand struct {
-	Method      string
-	URL         string
-	Headers     map[string]string
-	Data        string
-	Insecure    bool
-	FormData    map[string]string
-	QueryParams map[string]string
-}
-
-// executeCurlAsHTTP 将curl命令解析为HTTP请求并执行
-func executeCurlAsHTTP(curlCmd string) (int, string, map[string]string, error) {
-	// 确保命令以curl开头
-	if !strings.HasPrefix(curlCmd, "curl ") {
-		return 0, "", nil, fmt.Errorf("command must start with 'curl'")
-	}
-
-	// 将多行命令合并为一行（去掉行尾的反斜杠和换行符）
-	curlCmd = strings.ReplaceAll(curlCmd, "\\\n", " ")
-	curlCmd = strings.ReplaceAll(curlCmd, "\\\r\n", " ")
-
-	// 解析curl命令
-	cmd, err := parseCurlCommand(curlCmd)
-	if err != nil {
-		return 0, "", nil, err
-	}
-
-	// 创建HTTP客户端
-	transport := &http.Transport{}
-	
-	// 如果指定了insecure选项，则跳过TLS证书验证
-	if cmd.Insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	}
-	
-	client := &http.Client{
-		Timeout:   30 * time.Second,
-		Transport: transport,
-	}
-
-	// 准备请求体
-	var reqBody io.Reader
-	if cmd.Data != "" {
-		reqBody = strings.NewReader(cmd.Data)
-	}
-
-	// 创建HTTP请求
-	req, err := http.NewRequest(cmd.Method, cmd.URL, reqBody)
-	if err != nil {
-		return 0, "", nil, fmt.Errorf("创建HTTP请求失败: %v", err)
-	}
-
-	// 添加请求头
-	for key, value := range cmd.Headers {
-		req.Header.Set(key, value)
-	}
-
-	// 执行请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, "", nil, fmt.Errorf("执行HTTP请求失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应体
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return resp.StatusCode, "", headerToMap(resp.Header), fmt.Errorf("读取响应体失败: %v", err)
-	}
-
-	return resp.StatusCode, string(bodyBytes), headerToMap(resp.Header), nil
-}
-
-// parseCurlCommand 解析curl命令
-func parseCurlCommand(curlCmd string) (*CurlCommand, error) {
-	cmd := &CurlCommand{
-		Method:      "GET",
-		Headers:     make(map[string]string),
-		FormData:    make(map[string]string),
-		QueryParams: make(map[string]string),
-	}
-
-	// 提取URL
-	urlPattern := regexp.MustCompile(`curl\s+['"]([^'"]+)['"]`)
-	urlMatches := urlPattern.FindStringSubmatch(curlCmd)
-	if len(urlMatches) < 2 {
-		// 尝试不带引号的URL
-		urlPattern = regexp.MustCompile(`curl\s+(\S+)`)
-		urlMatches = urlPattern.FindStringSubmatch(curlCmd)
-		if len(urlMatches) < 2 {
-			return nil, fmt.Errorf("无法解析URL")
+// bindCurlRequest 解析代理请求，支持JSON和multipart/form-data两种格式，同时返回随请求上传的文件
+func bindCurlRequest(c *gin.Context) (*CurlRequest, curlcmd.Files, error) {
+	var request CurlRequest
+	if c.ContentType() == binding.MIMEMultipartPOSTForm {
+		if err := c.ShouldBindWith(&request, binding.FormMultipart); err != nil {
+			return nil, nil, err
 		}
-	}
-	cmd.URL = urlMatches[1]
-
-	// 提取请求方法
-	methodPattern := regexp.MustCompile(`-X\s+(\S+)`)
-	methodMatches := methodPattern.FindStringSubmatch(curlCmd)
-	if len(methodMatches) >= 2 {
-		cmd.Method = methodMatches[1]
-	}
-
-	// 提取请求头
-	headerPattern := regexp.MustCompile(`-H\s+['"]([^'"]+)['"]`)
-	headerMatches := headerPattern.FindAllStringSubmatch(curlCmd, -1)
-	for _, match := range headerMatches {
-		if len(match) >= 2 {
-			headerParts := strings.SplitN(match[1], ":", 2)
-			if len(headerParts) == 2 {
-				cmd.Headers[strings.TrimSpace(headerParts[0])] = strings.TrimSpace(headerParts[1])
-			}
+		form, err := c.MultipartForm()
+		if err != nil {
+			return nil, nil, err
 		}
+		files, err := curlcmd.FilesFromMultipart(form)
+		return &request, files, err
 	}
 
-	// 提取请求体数据 - 支持多种数据格式
-	// 1. --data-raw
-	dataRawPattern := regexp.MustCompile(`--data-raw\s+['"]([^'"]+)['"]`)
-	dataRawMatches := dataRawPattern.FindStringSubmatch(curlCmd)
-	if len(dataRawMatches) >= 2 {
-		cmd.Data = dataRawMatches[1]
-	}
-	
-	// 2. --data 或 -d
-	if cmd.Data == "" {
-		dataPattern := regexp.MustCompile(`(?:--data|-d)\s+['"]([^'"]+)['"]`)
-		dataMatches := dataPattern.FindStringSubmatch(curlCmd)
-		if len(dataMatches) >= 2 {
-			cmd.Data = dataMatches[1]
-		}
-	}
-	
-	// 3. --json
-	if cmd.Data == "" {
-		jsonPattern := regexp.MustCompile(`--json\s+['"]([^'"]+)['"]`)
-		jsonMatches := jsonPattern.FindStringSubmatch(curlCmd)
-		if len(jsonMatches) >= 2 {
-			cmd.Data = jsonMatches[1]
-			cmd.Headers["Content-Type"] = "application/json"
-		}
-	}
-	
-	// 如果有数据，设置适当的Content-Type和Method
-	if cmd.Data != "" {
-		// 如果没有明确指定Content-Type，根据数据格式推断
-		if _, exists := cmd.Headers["Content-Type"]; !exists {
-			if strings.HasPrefix(cmd.Data, "{") && strings.HasSuffix(cmd.Data, "}") {
-				cmd.Headers["Content-Type"] = "application/json"
-			} else {
-				cmd.Headers["Content-Type"] = "application/x-www-form-urlencoded"
-			}
-		}
-		// 如果是POST请求但没有指定方法，则设置为POST
-		if cmd.Method == "GET" {
-			cmd.Method = "POST"
-		}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		return nil, nil, err
 	}
+	files, err := curlcmd.DecodeFiles(request.Files)
+	return &request, files, err
+}
 
-	// 检查是否有--insecure选项
-	cmd.Insecure = strings.Contains(curlCmd, "--insecure") || strings.Contains(curlCmd, "-k")
-
-	return cmd, nil
+// executeCurlAsHTTP 将curl命令解析为HTTP请求并执行，结果写入response
+func executeCurlAsHTTP(curlCmd string, files curlcmd.Files, response *CurlResponse) error {
+	executor := curlcmd.Executor{Files: files, MaxBodySize: MaxBodySize}
+	return executor.Execute(curlCmd, &response.Result)
 }
 
-// headerToMap 将HTTP头转换为map
-func headerToMap(header http.Header) map[string]string {
-	result := make(map[string]string)
-	for key, values := range header {
-		if len(values) > 0 {
-			result[key] = strings.Join(values, ", ")
+// streamCurlAsHTTP raw模式：执行请求后把上游的状态码、响应头和响应体原样转发，不经过JSON包装
+func streamCurlAsHTTP(c *gin.Context, curlCmd string, files curlcmd.Files) {
+	var (
+		result curlcmd.Result
+		tracer curlcmd.Tracer
+	)
+	executor := curlcmd.Executor{Files: files, Stream: true}
+	_, resp, err := executor.Send(curlCmd, &tracer, &result)
+	if err != nil {
+		body := gin.H{"error": err.Error()}
+		if len(result.Warnings) > 0 {
+			body["warnings"] = result.Warnings
 		}
+		c.JSON(http.StatusBadGateway, body)
+		return
 	}
-	return result
+	defer resp.Body.Close()
+
+	curlcmd.Forward(c.Writer, resp)
 }
 
 // RegisterCorsProxyRoutes 注册CORS代理路由到Gin引擎
 func RegisterCorsProxyRoutes(r *gin.Engine) {
 	r.Use(CorsProxyMiddleware())
 	r.POST("/cors-proxy", HandleCurlProxy)
+	r.Any(passthroughPrefix+"*url", HandleProxyPassthrough)
 }
 
 // StartStandalone 启动独立的CORS代理服务器
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
+)
+
+// passthroughPrefix 透传代理的路由前缀，目标地址紧跟其后，如 /proxy/https://api.example.com/path?a=1
+const passthroughPrefix = "/proxy/"
+
+// passthroughClient 透传代理使用的HTTP客户端。不设置总超时，以便转发大文件和流式响应；
+// 不自动解压，上游的Content-Encoding原样交给浏览器处理
+var passthroughClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		DisableCompression:    true,
+		ForceAttemptHTTP2:     true,
+	},
+}
+
+// skippedRequestHeaders 透传时不转发给上游的请求头：逐跳头，以及属于代理自身域名的Cookie和浏览器的Origin
+var skippedRequestHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Cookie":              true,
+	"Origin":              true,
+}
+
+// HandleProxyPassthrough 透传代理：把 /proxy/{url} 收到的请求按原方法、请求头和请求体转发到url，
+// 跟随重定向后原样返回上游的状态码、响应头和响应体。前端只需把请求地址的前缀换成 /proxy/ 即可跨域访问
+func HandleProxyPassthrough(c *gin.Context) {
+	target, err := passthroughTarget(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, target, c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("创建HTTP请求失败: %v", err)})
+		return
+	}
+	req.ContentLength = c.Request.ContentLength
+	for key, values := range c.Request.Header {
+		if !skippedRequestHeaders[key] {
+			req.Header[key] = values
+		}
+	}
+
+	resp, err := passthroughClient.Do(req)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("执行HTTP请求失败: %v", err)})
+		return
+	}
+	defer resp.Body.Close()
+
+	curlcmd.CopyUpstreamHeaders(c.Writer.Header(), resp.Header)
+	c.Status(resp.StatusCode)
+	copyFlushing(c.Writer, resp.Body)
+}
+
+// passthroughTarget 从请求路径中取出目标地址，并带上原请求的查询参数
+func passthroughTarget(r *http.Request) (string, error) {
+	raw := strings.TrimPrefix(r.URL.EscapedPath(), passthroughPrefix)
+	// 部分客户端和反向代理会把路径中的//合并为/
+	for _, scheme := range []string{"http:/", "https:/"} {
+		if strings.HasPrefix(raw, scheme) && !strings.HasPrefix(raw, scheme+"/") {
+			raw = scheme + "/" + raw[len(scheme):]
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("目标地址无效，格式为 %shttps://example.com/path", passthroughPrefix)
+	}
+	u.RawQuery = r.URL.RawQuery
+	return u.String(), nil
+}
+
+// copyFlushing 转发响应体，每次写入后立即刷新，使Server-Sent Events等流式响应能实时到达浏览器
+func copyFlushing(w gin.ResponseWriter, body io.Reader) (int64, error) {
+	var written int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			m, writeErr := w.Write(buf[:n])
+			written += int64(m)
+			if writeErr != nil {
+				return written, writeErr
+			}
+			w.Flush()
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package audit 安全审计日志：记录谁在什么时间、从哪个IP执行了什么操作以及结果。
+// 日志只追加不修改，管理员可按条件分页查询。
+package audit
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// 操作结果
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultDenied  = "denied" // 未登录、无权限或被锁定
+)
+
+// contextActorKey 当前登录用户在gin.Context中的键，由鉴权中间件通过SetActor写入
+const contextActorKey = "auditActor"
+
+// Event 一条审计记录
+type Event struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor,omitempty"` // 操作人，登录类操作为尝试登录的账号
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"` // 操作对象，如用户名、URL或主机
+	IP     string    `json:"ip,omitempty"`
+	Result string    `json:"result"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Filter 查询条件，字符串条件为空表示不限制；Action按前缀匹配，Target按子串匹配
+type Filter struct {
+	Actor  string
+	Action string
+	Target string
+	IP     string
+	Result string
+	Since  time.Time
+	Until  time.Time
+	Offset int
+	Limit  int
+}
+
+// Match 判断记录是否满足查询条件
+func (f Filter) Match(e Event) bool {
+	if f.Actor != "" && !strings.EqualFold(e.Actor, f.Actor) {
+		return false
+	}
+	if f.Action != "" && !strings.HasPrefix(e.Action, f.Action) {
+		return false
+	}
+	if f.Target != "" && !strings.Contains(strings.ToLower(e.Target), strings.ToLower(f.Target)) {
+		return false
+	}
+	if f.IP != "" && e.IP != f.IP {
+		return false
+	}
+	if f.Result != "" && e.Result != f.Result {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Sink 审计日志的存储后端
+type Sink interface {
+	// Append 追加一条记录
+	Append(e Event) error
+	// Query 按时间倒序返回一页满足条件的记录，以及满足条件的总数
+	Query(f Filter) ([]Event, int, error)
+	Close() error
+}
+
+// Open 按配置打开审计日志后端，store后端写入st；JSON存储每次写入都重写整个文件，不能用于审计日志
+func Open(cfg config.AuditConfig, storage config.StorageConfig, st store.Store) (Sink, error) {
+	switch cfg.Backend {
+	case config.AuditFile:
+		return NewFileSink(cfg.Dir, int64(cfg.MaxSizeMB)<<20, cfg.MaxFiles)
+	case config.AuditStore:
+		if storage.Backend != config.StorageBolt {
+			return nil, fmt.Errorf("审计日志的store方式需要bolt存储后端，当前为%s", storage.Backend)
+		}
+		return NewStoreSink(st, cfg.MaxEvents)
+	default:
+		return nil, fmt.Errorf("不支持的审计日志后端: %s", cfg.Backend)
+	}
+}
+
+var (
+	mu      sync.RWMutex
+	current Sink = &StoreSink{st: store.NewMemoryStore()}
+
+	sequence uint32
+)
+
+// SetSink 设置全局使用的审计日志后端
+func SetSink(s Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = s
+}
+
+// newID 生成按时间排序的记录ID
+func newID(t time.Time) string {
+	return fmt.Sprintf("%016x%08x", t.UnixNano(), atomic.AddUint32(&sequence, 1))
+}
+
+// Record 写入一条审计记录，写入失败只打印日志，不影响业务流程
+func Record(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.ID == "" {
+		e.ID = newID(e.Time)
+	}
+
+	mu.RLock()
+	sink := current
+	mu.RUnlock()
+	if err := sink.Append(e); err != nil {
+		log.Printf("[AUDIT] 写入审计日志失败: %v, 记录: %+v", err, e)
+	}
+}
+
+// Query 查询审计记录
+func Query(f Filter) ([]Event, int, error) {
+	mu.RLock()
+	sink := current
+	mu.RUnlock()
+	return sink.Query(f)
+}
+
+// SetActor 鉴权通过后记录当前用户，之后的Log调用以该用户作为操作人
+func SetActor(c *gin.Context, username string) {
+	c.Set(contextActorKey, username)
+}
+
+// Log 以当前登录用户为操作人记录请求中的操作
+func Log(c *gin.Context, action, target, result, detail string) {
+	LogAs(c, c.GetString(contextActorKey), action, target, result, detail)
+}
+
+// LogAs 以指定的操作人记录请求中的操作，用于登录、注册等尚未登录的场景
+func LogAs(c *gin.Context, actor, action, target, result, detail string) {
+	Record(Event{
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		IP:     c.ClientIP(),
+		Result: result,
+		Detail: detail,
+	})
+}
+
+// page 从按时间倒序排列的记录中取出一页，Offset为负数时按0处理
+func page(events []Event, f Filter) []Event {
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	if f.Offset >= len(events) {
+		return []Event{}
+	}
+	end := len(events)
+	if f.Limit > 0 && f.Limit < end-f.Offset {
+		end = f.Offset + f.Limit
+	}
+	return events[f.Offset:end]
+}
@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	events := make([]Event, 5)
+	for i := range events {
+		events[i].ID = string(rune('a' + i))
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"all", Filter{}, "abcde"},
+		{"first page", Filter{Limit: 2}, "ab"},
+		{"middle page", Filter{Offset: 2, Limit: 2}, "cd"},
+		{"last partial page", Filter{Offset: 4, Limit: 2}, "e"},
+		{"past the end", Filter{Offset: 5, Limit: 2}, ""},
+		{"negative offset", Filter{Offset: -10, Limit: 2}, "ab"},
+		{"huge offset", Filter{Offset: math.MaxInt, Limit: 2}, ""},
+		{"huge limit", Filter{Offset: 1, Limit: math.MaxInt}, "bcde"},
+	}
+	for _, tt := range tests {
+		var got string
+		for _, e := range page(events, tt.filter) {
+			got += e.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s: page = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
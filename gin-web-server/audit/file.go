@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	currentFileName = "audit.jsonl"
+	rotatedPrefix   = "audit-"
+	rotatedSuffix   = ".jsonl"
+	rotatedLayout   = "20060102T150405.000000000"
+	// maxLineSize 单条记录的最大长度，超长的行在查询时跳过
+	maxLineSize = 1 << 20
+)
+
+// FileSink 审计日志写入JSONL文件，每行一条记录；文件达到maxSize后改名为带时间戳的历史文件，
+// 只保留最近maxFiles个历史文件
+type FileSink struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink 打开日志目录下的当前日志文件，以追加方式写入
+func NewFileSink(dir string, maxSize int64, maxFiles int) (*FileSink, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &FileSink{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := s.openCurrent(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) openCurrent() error {
+	file, err := os.OpenFile(filepath.Join(s.dir, currentFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// Append 追加一行记录，写入前检查是否需要轮转
+func (s *FileSink) Append(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("审计日志已关闭")
+	}
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("轮转审计日志失败: %w", err)
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate 把当前文件改名为历史文件并新建当前文件，调用方需持有mu
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	rotated := filepath.Join(s.dir, rotatedPrefix+time.Now().UTC().Format(rotatedLayout)+rotatedSuffix)
+	if err := os.Rename(filepath.Join(s.dir, currentFileName), rotated); err != nil {
+		return err
+	}
+	if err := s.openCurrent(); err != nil {
+		return err
+	}
+
+	files, err := s.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for i := s.maxFiles; i < len(files); i++ {
+		if err := os.Remove(files[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotatedFiles 返回历史文件路径，按时间从新到旧排列
+func (s *FileSink) rotatedFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, rotatedPrefix) && strings.HasSuffix(name, rotatedSuffix) {
+			files = append(files, filepath.Join(s.dir, name))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// Query 从新到旧读取当前文件和历史文件
+func (s *FileSink) Query(f Filter) ([]Event, int, error) {
+	s.mu.Lock()
+	files, err := s.rotatedFiles()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	files = append([]string{filepath.Join(s.dir, currentFileName)}, files...)
+
+	var matched []Event
+	for _, path := range files {
+		events, err := readEvents(path, f)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// 查询期间文件被轮转或清理
+				continue
+			}
+			return nil, 0, err
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			matched = append(matched, events[i])
+		}
+	}
+	return page(matched, f), len(matched), nil
+}
+
+// readEvents 按文件中的顺序读取满足条件的记录，跳过无法解析和超过maxLineSize的行
+func readEvents(path string, f Filter) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	reader := bufio.NewReader(file)
+	for {
+		line, err := readLine(reader)
+		if len(line) > 0 {
+			var e Event
+			if json.Unmarshal(line, &e) == nil && f.Match(e) {
+				events = append(events, e)
+			}
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+	}
+}
+
+// readLine 读取一行（不含换行符），超过maxLineSize的行读完后丢弃，返回nil
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > maxLineSize+1 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLong {
+			return nil, err
+		}
+		return bytes.TrimRight(line, "\r\n"), err
+	}
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
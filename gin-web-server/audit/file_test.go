@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSinkSkipsOversizedLines(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(dir, 64<<20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err := sink.Append(Event{ID: newID(time.Now()), Action: "auth.login", Result: ResultSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	// 超长的行、刚好不超长的行和不完整的行混在正常记录之间
+	long := `{"id":"x","action":"auth.long","detail":"` + strings.Repeat("a", maxLineSize) + `"}` + "\n"
+	limit := `{"id":"y","action":"auth.limit","detail":"`
+	limit += strings.Repeat("b", maxLineSize-len(limit)-2) + `"}` + "\n"
+	file, err := os.OpenFile(filepath.Join(dir, currentFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(long + limit + "not json\r\n")
+	file.Close()
+	sink.size += int64(len(long) + len(limit) + 10)
+
+	if err := sink.Append(Event{ID: newID(time.Now()), Action: "auth.logout", Result: ResultSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	events, total, err := sink.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	if got := strings.Join(actions, ","); total != 3 || got != "auth.logout,auth.limit,auth.login" {
+		t.Errorf("Query = %s (total %d)", got, total)
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(dir, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := sink.Append(Event{ID: newID(time.Now()), Action: "auth.login", Detail: strings.Repeat("x", 100)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := sink.rotatedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("rotated files = %d, want 2", len(files))
+	}
+	if _, total, err := sink.Query(Filter{}); err != nil || total != 3 {
+		t.Errorf("Query total = %d, %v, want 3", total, err)
+	}
+}
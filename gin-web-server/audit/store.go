@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+const bucketAudit = "audit_log"
+
+// StoreSink 审计日志写入存储后端，每条记录以按时间排序的ID为键，只新增不覆盖；
+// 记录数超过maxEvents后删除最旧的记录
+type StoreSink struct {
+	st        store.Store
+	maxEvents int
+
+	mu   sync.Mutex
+	keys []string // 已保存记录的ID，按时间从旧到新排列
+}
+
+// NewStoreSink 读取已有记录的ID，maxEvents不大于0时不限制记录数
+func NewStoreSink(st store.Store, maxEvents int) (*StoreSink, error) {
+	s := &StoreSink{st: st, maxEvents: maxEvents}
+	err := st.ForEach(bucketAudit, func(key string, raw []byte) error {
+		s.keys = append(s.keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(s.keys)
+	if err := s.trim(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *StoreSink) Append(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.st.Create(bucketAudit, e.ID, e); err != nil {
+		return err
+	}
+	// ID按时间生成，通常已是最新的，插入排序只需比较一次
+	i := sort.SearchStrings(s.keys, e.ID)
+	s.keys = append(s.keys, "")
+	copy(s.keys[i+1:], s.keys[i:])
+	s.keys[i] = e.ID
+	return s.trim()
+}
+
+// trim 删除超出maxEvents的最旧记录，调用方须持有mu
+func (s *StoreSink) trim() error {
+	if s.maxEvents <= 0 {
+		return nil
+	}
+	for len(s.keys) > s.maxEvents {
+		if err := s.st.Delete(bucketAudit, s.keys[0]); err != nil {
+			return err
+		}
+		s.keys = s.keys[1:]
+	}
+	return nil
+}
+
+func (s *StoreSink) Query(f Filter) ([]Event, int, error) {
+	var matched []Event
+	err := s.st.ForEach(bucketAudit, func(key string, raw []byte) error {
+		var e Event
+		if json.Unmarshal(raw, &e) == nil && f.Match(e) {
+			matched = append(matched, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+	return page(matched, f), len(matched), nil
+}
+
+// Close 存储由调用方管理，这里不关闭
+func (s *StoreSink) Close() error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+func TestStoreSinkRetention(t *testing.T) {
+	st := store.NewMemoryStore()
+	sink, err := NewStoreSink(st, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		e := Event{ID: newID(base.Add(time.Duration(i) * time.Second)), Action: fmt.Sprintf("auth.%d", i)}
+		if err := sink.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, total, err := sink.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || events[0].Action != "auth.4" || events[2].Action != "auth.2" {
+		t.Errorf("Query = %+v (total %d), want the 3 newest events", events, total)
+	}
+
+	// 重新打开时按新的上限清理已有记录
+	sink, err = NewStoreSink(st, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, total, err = sink.Query(Filter{})
+	if err != nil || total != 1 || events[0].Action != "auth.4" {
+		t.Errorf("Query after reopen = %+v (total %d), %v", events, total, err)
+	}
+}
+
+func TestOpenStoreRequiresBolt(t *testing.T) {
+	cfg := config.AuditConfig{Backend: config.AuditStore, MaxEvents: 10}
+	if _, err := Open(cfg, config.StorageConfig{Backend: config.StorageJSON}, store.NewMemoryStore()); err == nil {
+		t.Error("Open accepted the JSON storage backend")
+	}
+	if _, err := Open(cfg, config.StorageConfig{Backend: config.StorageBolt}, store.NewMemoryStore()); err != nil {
+		t.Errorf("Open with bolt: %v", err)
+	}
+}
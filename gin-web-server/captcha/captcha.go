@@ -0,0 +1,85 @@
+// Package captcha 服务端渲染的图形验证码，提供字符、算式和滑块三种可替换的验证方式。
+package captcha
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"math/big"
+)
+
+// 验证方式
+const (
+	TypeText   = "text"   // 扭曲字符
+	TypeMath   = "math"   // 算式，填写计算结果
+	TypeSlider = "slider" // 拖动拼图块到缺口处，提交横坐标
+)
+
+// Difficulty 难度，影响字符长度、扭曲程度、干扰线数量和滑块容差
+type Difficulty string
+
+const (
+	Easy   Difficulty = "easy"
+	Medium Difficulty = "medium"
+	Hard   Difficulty = "hard"
+)
+
+// Challenge 一次验证码挑战，Answer只保存在服务端，其余字段返回给客户端
+type Challenge struct {
+	Type   string
+	Image  string // PNG图片的data URL
+	Width  int
+	Height int
+	// 滑块验证的拼图块图片及其纵坐标
+	Piece  string
+	PieceY int
+	Answer string
+}
+
+// Provider 验证码提供者，可按配置替换
+type Provider interface {
+	Type() string
+	Generate() (*Challenge, error)
+	// Verify 校验客户端提交的答案
+	Verify(answer, response string) bool
+}
+
+// New 按类型和难度创建验证码提供者
+func New(kind string, difficulty Difficulty) (Provider, error) {
+	st, ok := styles[difficulty]
+	if !ok {
+		return nil, fmt.Errorf("不支持的验证码难度: %s", difficulty)
+	}
+
+	switch kind {
+	case TypeText:
+		return &textProvider{style: st}, nil
+	case TypeMath:
+		return &mathProvider{style: st, difficulty: difficulty}, nil
+	case TypeSlider:
+		return &sliderProvider{style: st}, nil
+	default:
+		return nil, fmt.Errorf("不支持的验证码类型: %s", kind)
+	}
+}
+
+// encodeDataURL 将图片编码为PNG data URL
+func encodeDataURL(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// secureIntn 答案相关的随机数使用crypto/rand，绘制干扰只用math/rand
+func secureIntn(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,75 @@
+package captcha
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mathProvider 算式验证码，难度越高数字范围越大，困难模式包含乘法
+type mathProvider struct {
+	style      style
+	difficulty Difficulty
+}
+
+func (p *mathProvider) Type() string { return TypeMath }
+
+func (p *mathProvider) Generate() (*Challenge, error) {
+	ops := "+"
+	limit := 10
+	switch p.difficulty {
+	case Medium:
+		ops, limit = "+-", 20
+	case Hard:
+		ops, limit = "+-*", 50
+	}
+
+	n, err := secureIntn(len(ops))
+	if err != nil {
+		return nil, err
+	}
+	op := ops[n]
+	if op == '*' {
+		limit = 10
+	}
+	a, err := secureIntn(limit - 1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := secureIntn(limit - 1)
+	if err != nil {
+		return nil, err
+	}
+	a, b = a+1, b+1
+
+	var result int
+	switch op {
+	case '+':
+		result = a + b
+	case '-':
+		// 保证结果非负
+		if a < b {
+			a, b = b, a
+		}
+		result = a - b
+	case '*':
+		result = a * b
+	}
+
+	img := renderText(fmt.Sprintf("%d%c%d=?", a, op, b), p.style)
+	data, err := encodeDataURL(img)
+	if err != nil {
+		return nil, fmt.Errorf("编码验证码图片失败: %w", err)
+	}
+	return &Challenge{
+		Type:   TypeMath,
+		Image:  data,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Answer: strconv.Itoa(result),
+	}, nil
+}
+
+func (p *mathProvider) Verify(answer, response string) bool {
+	return answer != "" && answer == strings.TrimSpace(response)
+}
@@ -0,0 +1,192 @@
+package captcha
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// style 各难度的绘制参数
+type style struct {
+	length    int     // 字符验证码长度
+	rotation  float64 // 单个字符最大旋转角度(弧度)
+	warp      float64 // 整体正弦扭曲幅度(像素)
+	lines     int     // 干扰线数量
+	dots      int     // 噪点数量
+	strike    bool    // 是否绘制贯穿字符的干扰曲线
+	tolerance int     // 滑块允许的误差(像素)
+	decoy     bool    // 滑块是否绘制干扰缺口
+}
+
+var styles = map[Difficulty]style{
+	Easy:   {length: 4, rotation: 0.15, warp: 1.5, lines: 2, dots: 80, tolerance: 6},
+	Medium: {length: 5, rotation: 0.3, warp: 2.5, lines: 4, dots: 250, strike: true, tolerance: 4},
+	Hard:   {length: 6, rotation: 0.45, warp: 3.5, lines: 6, dots: 500, strike: true, tolerance: 3, decoy: true},
+}
+
+const (
+	glyphScale = 4  // 点阵放大倍数
+	glyphCell  = 28 // 每个字符占用的宽度
+	textHeight = 50
+	textMargin = 12
+)
+
+// renderText 绘制扭曲的字符图片：随机背景色、逐字旋转和错切、整体正弦扭曲、干扰线和噪点
+func renderText(text string, st style) *image.RGBA {
+	runes := []rune(text)
+	width := len(runes)*glyphCell + textMargin*2
+	src := image.NewRGBA(image.Rect(0, 0, width, textHeight))
+	fillGradient(src, randomColor(200, 255), randomColor(200, 255))
+
+	for i := 0; i < st.lines/2; i++ {
+		drawLine(src, randomPoint(src.Bounds()), randomPoint(src.Bounds()), randomColor(120, 220), 1)
+	}
+
+	for i, ch := range runes {
+		cx := float64(textMargin + i*glyphCell + glyphCell/2 + rand.Intn(5) - 2)
+		cy := float64(textHeight/2 + rand.Intn(7) - 3)
+		drawGlyph(src, ch, cx, cy, st, randomColor(20, 140))
+	}
+
+	dst := warpImage(src, st.warp)
+
+	for i := st.lines / 2; i < st.lines; i++ {
+		drawLine(dst, randomPoint(dst.Bounds()), randomPoint(dst.Bounds()), randomColor(40, 180), 1+rand.Intn(2))
+	}
+	if st.strike {
+		drawStrike(dst, randomColor(20, 140))
+	}
+	for i := 0; i < st.dots; i++ {
+		dst.Set(rand.Intn(width), rand.Intn(textHeight), randomColor(0, 255))
+	}
+	return dst
+}
+
+// drawGlyph 以(cx, cy)为中心绘制旋转、错切后的字形，逆向映射每个像素到点阵坐标采样
+func drawGlyph(img *image.RGBA, ch rune, cx, cy float64, st style, c color.RGBA) {
+	angle := (rand.Float64()*2 - 1) * st.rotation
+	shear := (rand.Float64()*2 - 1) * st.rotation * 0.5
+	scale := glyphScale * (0.9 + rand.Float64()*0.25)
+	sin, cos := math.Sincos(angle)
+
+	radius := int(glyphHeight * scale)
+	for y := int(cy) - radius; y <= int(cy)+radius; y++ {
+		for x := int(cx) - radius; x <= int(cx)+radius; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			u := dx*cos + dy*sin
+			v := -dx*sin + dy*cos
+			u -= v * shear
+			if glyphPixel(ch, u/scale+glyphWidth/2.0, v/scale+glyphHeight/2.0) {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+// warpImage 沿两个方向做正弦位移，使字符笔画弯曲
+func warpImage(src *image.RGBA, amplitude float64) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	periodX := 30 + rand.Float64()*30
+	periodY := 20 + rand.Float64()*20
+	phaseX := rand.Float64() * 2 * math.Pi
+	phaseY := rand.Float64() * 2 * math.Pi
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			sx := x + int(amplitude*math.Sin(2*math.Pi*float64(y)/periodY+phaseY))
+			sy := y + int(amplitude*0.6*math.Sin(2*math.Pi*float64(x)/periodX+phaseX))
+			dst.SetRGBA(x, y, src.RGBAAt(clamp(sx, b.Min.X, b.Max.X-1), clamp(sy, b.Min.Y, b.Max.Y-1)))
+		}
+	}
+	return dst
+}
+
+// drawStrike 绘制横穿全图的正弦曲线，颜色与字符相近，增加分割字符的难度
+func drawStrike(img *image.RGBA, c color.RGBA) {
+	b := img.Bounds()
+	mid := float64(b.Dy())/2 + float64(rand.Intn(11)-5)
+	amplitude := 4 + rand.Float64()*6
+	period := 40 + rand.Float64()*40
+	phase := rand.Float64() * 2 * math.Pi
+	for x := b.Min.X; x < b.Max.X; x++ {
+		y := int(mid + amplitude*math.Sin(2*math.Pi*float64(x)/period+phase))
+		img.SetRGBA(x, y, c)
+		img.SetRGBA(x, y+1, c)
+	}
+}
+
+func drawLine(img *image.RGBA, from, to image.Point, c color.RGBA, thickness int) {
+	dx, dy := abs(to.X-from.X), -abs(to.Y-from.Y)
+	sx, sy := 1, 1
+	if from.X > to.X {
+		sx = -1
+	}
+	if from.Y > to.Y {
+		sy = -1
+	}
+	x, y, e := from.X, from.Y, dx+dy
+	for {
+		for t := 0; t < thickness; t++ {
+			img.SetRGBA(x, y+t, c)
+		}
+		if x == to.X && y == to.Y {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x += sx
+		} else {
+			e += dx
+			y += sy
+		}
+	}
+}
+
+// fillGradient 从左到右的渐变背景
+func fillGradient(img *image.RGBA, from, to color.RGBA) {
+	b := img.Bounds()
+	for x := b.Min.X; x < b.Max.X; x++ {
+		t := float64(x-b.Min.X) / float64(b.Dx())
+		c := color.RGBA{
+			R: lerp(from.R, to.R, t),
+			G: lerp(from.G, to.G, t),
+			B: lerp(from.B, to.B, t),
+			A: 255,
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func randomColor(min, max int) color.RGBA {
+	channel := func() uint8 { return uint8(min + rand.Intn(max-min+1)) }
+	return color.RGBA{R: channel(), G: channel(), B: channel(), A: 255}
+}
+
+func randomPoint(b image.Rectangle) image.Point {
+	return image.Pt(b.Min.X+rand.Intn(b.Dx()), b.Min.Y+rand.Intn(b.Dy()))
+}
+
+func lerp(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
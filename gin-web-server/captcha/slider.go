@@ -0,0 +1,144 @@
+package captcha
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	sliderWidth  = 280
+	sliderHeight = 140
+	pieceSize    = 44 // 拼图块外框边长
+	pieceBody    = 34 // 拼图块主体方块边长
+	pieceKnob    = 7  // 凸起半径
+)
+
+// sliderProvider 滑块拼图验证码：背景图上挖出缺口，客户端把拼图块拖到缺口处并提交横坐标
+type sliderProvider struct {
+	style style
+}
+
+func (p *sliderProvider) Type() string { return TypeSlider }
+
+func (p *sliderProvider) Generate() (*Challenge, error) {
+	// 缺口不出现在拼图块的初始位置附近
+	minX := pieceSize + 20
+	span := sliderWidth - pieceSize - 10 - minX
+	x, err := secureIntn(span)
+	if err != nil {
+		return nil, err
+	}
+	y, err := secureIntn(sliderHeight - pieceSize - 10)
+	if err != nil {
+		return nil, err
+	}
+	x, y = x+minX, y+5
+
+	bg := image.NewRGBA(image.Rect(0, 0, sliderWidth, sliderHeight))
+	fillGradient(bg, randomColor(60, 220), randomColor(60, 220))
+	for i := 0; i < 30; i++ {
+		fillCircle(bg, randomPoint(bg.Bounds()), 5+rand.Intn(25), randomColor(40, 240))
+	}
+	for i := 0; i < p.style.dots; i++ {
+		bg.Set(rand.Intn(sliderWidth), rand.Intn(sliderHeight), randomColor(0, 255))
+	}
+
+	piece := image.NewRGBA(image.Rect(0, 0, pieceSize, pieceSize))
+	for py := 0; py < pieceSize; py++ {
+		for px := 0; px < pieceSize; px++ {
+			if !inPiece(px, py) {
+				continue
+			}
+			c := bg.RGBAAt(x+px, y+py)
+			if onPieceEdge(px, py) {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			piece.SetRGBA(px, py, c)
+		}
+	}
+
+	carveHole(bg, x, y, 0.45)
+	if p.style.decoy {
+		// 干扰缺口颜色较浅，避免通过查找最暗区域直接定位
+		decoyX := minX + (x-minX+span/2)%span
+		carveHole(bg, decoyX, y, 0.75)
+	}
+
+	bgData, err := encodeDataURL(bg)
+	if err != nil {
+		return nil, fmt.Errorf("编码验证码图片失败: %w", err)
+	}
+	pieceData, err := encodeDataURL(piece)
+	if err != nil {
+		return nil, fmt.Errorf("编码验证码图片失败: %w", err)
+	}
+	return &Challenge{
+		Type:   TypeSlider,
+		Image:  bgData,
+		Width:  sliderWidth,
+		Height: sliderHeight,
+		Piece:  pieceData,
+		PieceY: y,
+		Answer: strconv.Itoa(x),
+	}, nil
+}
+
+// Verify 提交的横坐标与缺口位置的误差在容差范围内即通过
+func (p *sliderProvider) Verify(answer, response string) bool {
+	want, err := strconv.Atoi(answer)
+	if err != nil {
+		return false
+	}
+	got, err := strconv.ParseFloat(strings.TrimSpace(response), 64)
+	if err != nil {
+		return false
+	}
+	return abs(int(got+0.5)-want) <= p.style.tolerance
+}
+
+// inPiece 拼图块形状：方块主体加顶部和右侧两个半圆凸起
+func inPiece(px, py int) bool {
+	top := pieceSize - pieceBody
+	if px < pieceBody && py >= top {
+		return true
+	}
+	inCircle := func(cx, cy int) bool {
+		dx, dy := px-cx, py-cy
+		return dx*dx+dy*dy <= pieceKnob*pieceKnob
+	}
+	return inCircle(pieceBody/2, top) || inCircle(pieceBody, top+pieceBody/2)
+}
+
+func onPieceEdge(px, py int) bool {
+	return !inPiece(px-1, py) || !inPiece(px+1, py) || !inPiece(px, py-1) || !inPiece(px, py+1)
+}
+
+// carveHole 把背景图中拼图块形状的区域调暗，形成缺口
+func carveHole(img *image.RGBA, x, y int, brightness float64) {
+	for py := 0; py < pieceSize; py++ {
+		for px := 0; px < pieceSize; px++ {
+			if !inPiece(px, py) {
+				continue
+			}
+			c := img.RGBAAt(x+px, y+py)
+			c.R = uint8(float64(c.R) * brightness)
+			c.G = uint8(float64(c.G) * brightness)
+			c.B = uint8(float64(c.B) * brightness)
+			img.SetRGBA(x+px, y+py, c)
+		}
+	}
+}
+
+func fillCircle(img *image.RGBA, center image.Point, radius int, c color.RGBA) {
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius {
+				img.SetRGBA(center.X+x, center.Y+y, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package captcha
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textChars 去掉了易混淆的 I、O、0、1
+const textChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// textProvider 扭曲字符验证码，不区分大小写
+type textProvider struct {
+	style style
+}
+
+func (p *textProvider) Type() string { return TypeText }
+
+func (p *textProvider) Generate() (*Challenge, error) {
+	code := make([]byte, p.style.length)
+	for i := range code {
+		n, err := secureIntn(len(textChars))
+		if err != nil {
+			return nil, err
+		}
+		code[i] = textChars[n]
+	}
+
+	img := renderText(string(code), p.style)
+	data, err := encodeDataURL(img)
+	if err != nil {
+		return nil, fmt.Errorf("编码验证码图片失败: %w", err)
+	}
+	return &Challenge{
+		Type:   TypeText,
+		Image:  data,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Answer: string(code),
+	}, nil
+}
+
+func (p *textProvider) Verify(answer, response string) bool {
+	return answer != "" && strings.EqualFold(answer, strings.TrimSpace(response))
+}
@@ -0,0 +1,429 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// Config 服务配置，从JSON文件加载，未配置的字段使用默认值
+type Config struct {
+	Auth    AuthConfig    `json:"auth"`
+	Storage StorageConfig `json:"storage"`
+	Captcha CaptchaConfig `json:"captcha"`
+	Mail    MailConfig    `json:"mail"`
+	Audit   AuditConfig   `json:"audit"`
+	Proxy   ProxyConfig   `json:"proxy"`
+	// Destinations 代理和端口扫描允许访问的目标地址
+	Destinations DestinationConfig `json:"destinations"`
+	// TrustedProxies 信任的反向代理地址，只有来自这些地址的X-Forwarded-For才会用于识别客户端IP；
+	// 默认不信任任何代理，防止伪造IP绕过按IP的登录限制
+	TrustedProxies []string `json:"trustedProxies"`
+	// WebSocketOrigins 允许发起WebSocket连接的其他站点，如"https://tools.example.com"，"*"表示任意站点；
+	// 同源页面和不发送Origin的非浏览器客户端始终允许
+	WebSocketOrigins []string `json:"websocketOrigins"`
+}
+
+// 可选的存储后端
+const (
+	StorageJSON = "json" // 每类数据一个JSON文件
+	StorageBolt = "bolt" // bbolt嵌入式KV数据库
+)
+
+// 可选的邮件发送方式
+const (
+	MailFile = "file" // 写入文件并打印日志，用于本地开发
+	MailSMTP = "smtp"
+)
+
+// 可选的审计日志后端
+const (
+	AuditFile  = "file"  // 按大小轮转的JSONL文件
+	AuditStore = "store" // 写入存储后端，只支持bolt
+)
+
+// AuditConfig 审计日志配置，记录登录、账号变更、管理操作以及代理和端口扫描请求
+type AuditConfig struct {
+	Backend   string `json:"backend"`
+	Dir       string `json:"dir"`       // file方式的日志目录
+	MaxSizeMB int    `json:"maxSizeMB"` // 单个文件达到该大小后轮转
+	MaxFiles  int    `json:"maxFiles"`  // 保留的历史文件数量，超出后删除最旧的文件
+	MaxEvents int    `json:"maxEvents"` // store方式保留的记录数，超出后删除最旧的记录
+}
+
+// ProxyConfig CORS代理配置
+type ProxyConfig struct {
+	// MaxBodyMB 代理返回的响应体上限，超出部分截断；raw模式直接转发，不受限制
+	MaxBodyMB int `json:"maxBodyMB"`
+	// HistoryLimit 每个用户保留的请求历史条数，超出后删除最旧的未置顶记录；
+	// HistoryBodyKB 历史记录中保存的响应体上限，超出部分截断
+	HistoryLimit  int `json:"historyLimit"`
+	HistoryBodyKB int `json:"historyBodyKB"`
+}
+
+// DestinationConfig CORS代理、透传代理和端口扫描可以访问的目标地址，防止借助本服务访问内网（SSRF）。
+// 默认禁止回环、私有、链路本地（含云平台元数据地址169.254.169.254）等内网地址
+type DestinationConfig struct {
+	DestinationRule
+	// Roles 按角色覆盖的规则，配置了的角色完全使用该规则，不再使用上面的默认规则
+	Roles map[string]DestinationRule `json:"roles"`
+}
+
+// DestinationRule 目标地址规则，依次检查端口、Deny、Allow，最后按AllowPrivate决定是否允许内网地址。
+// Allow、Deny的每一项可以是IP、CIDR、域名或*.example.com（匹配所有子域名）；
+// 域名规则同时按请求中的主机名和DNS解析前的主机名匹配，IP规则按实际连接的IP匹配
+type DestinationRule struct {
+	AllowPrivate bool     `json:"allowPrivate"`
+	Allow        []string `json:"allow"` // 允许的目标，命中时即使是内网地址也允许
+	Deny         []string `json:"deny"`  // 禁止的目标，优先于Allow
+	// Ports 允许的端口，如"443"、"8000-9000"，为空表示不限制；DenyPorts 禁止的端口，优先于Ports
+	Ports     []string `json:"ports"`
+	DenyPorts []string `json:"denyPorts"`
+}
+
+// MailConfig 邮件发送配置，用于找回密码和邮箱验证
+type MailConfig struct {
+	Backend string     `json:"backend"`
+	Dir     string     `json:"dir"` // file方式的邮件目录
+	From    string     `json:"from"`
+	SMTP    SMTPConfig `json:"smtp"`
+	// BaseURL 邮件中链接的站点地址，不从请求头推断，防止伪造Host篡改重置链接
+	BaseURL string `json:"baseURL"`
+}
+
+// SMTPConfig SMTP服务器配置
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CaptchaConfig 图形验证码配置
+type CaptchaConfig struct {
+	Provider   string   `json:"provider"`   // text（扭曲字符）、math（算式）或slider（滑块拼图）
+	Difficulty string   `json:"difficulty"` // easy、medium或hard
+	TTL        Duration `json:"ttl"`        // 验证码有效期
+}
+
+// StorageConfig 用户、令牌等数据的存储配置
+type StorageConfig struct {
+	Backend string `json:"backend"`
+	Dir     string `json:"dir"` // 数据目录，JSON文件和数据库文件都放在这里
+}
+
+// AuthConfig 登录鉴权相关配置
+type AuthConfig struct {
+	// ProtectedRoutes 需要登录后才能访问的路由前缀
+	ProtectedRoutes []string `json:"protectedRoutes"`
+	// RoutePermissions 路由前缀需要的权限，登录用户的角色须具备该权限
+	RoutePermissions map[string]string `json:"routePermissions"`
+	// Roles 角色及其权限列表，"*"表示全部权限；内置admin和user角色，可覆盖或新增自定义角色
+	Roles map[string][]string `json:"roles"`
+	// Password 密码哈希(argon2id)参数
+	Password PasswordConfig `json:"password"`
+	// AccessTokenTTL 访问令牌有效期，过期后需用刷新令牌换取新令牌
+	AccessTokenTTL Duration `json:"accessTokenTTL"`
+	// RefreshTokenTTL 刷新令牌有效期，即会话在无活动时的最长保留时间
+	RefreshTokenTTL Duration `json:"refreshTokenTTL"`
+	// SweepInterval 后台清理过期令牌和会话的间隔
+	SweepInterval Duration `json:"sweepInterval"`
+	// TokenMode 访问令牌模式：opaque为随机令牌，需查存储校验；jwt为签名令牌，可无状态校验
+	TokenMode string `json:"tokenMode"`
+	// JWT TokenMode为jwt时的签名配置
+	JWT JWTConfig `json:"jwt"`
+	// Lockout 登录失败次数限制
+	Lockout LockoutConfig `json:"lockout"`
+	// ResetTokenTTL 找回密码链接有效期；VerifyTokenTTL 邮箱验证链接有效期
+	ResetTokenTTL  Duration `json:"resetTokenTTL"`
+	VerifyTokenTTL Duration `json:"verifyTokenTTL"`
+	// RequireEmailVerification 为true时邮箱未验证的用户不能登录
+	RequireEmailVerification bool `json:"requireEmailVerification"`
+	// OIDC OpenID Connect单点登录
+	OIDC OIDCConfig `json:"oidc"`
+}
+
+// OIDCConfig OpenID Connect单点登录配置，使用授权码+PKCE流程。
+// 首次登录时创建本地用户（或按邮箱关联已有用户），之后按IdP的sub识别。
+type OIDCConfig struct {
+	Enabled bool   `json:"enabled"`
+	Name    string `json:"name"` // 登录按钮上显示的名称
+	// Issuer IdP地址，从 {issuer}/.well-known/openid-configuration 自动发现各端点
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"` // 公共客户端可留空，仅依赖PKCE
+	// RedirectURL IdP回调地址，为空时使用 mail.baseURL + /api/auth/oidc/callback
+	RedirectURL string   `json:"redirectURL"`
+	Scopes      []string `json:"scopes"`
+	// UsernameClaim 新建用户时作为用户名的声明；GroupsClaim 分组声明，值为字符串或字符串数组
+	UsernameClaim string `json:"usernameClaim"`
+	GroupsClaim   string `json:"groupsClaim"`
+	// GroupRoles IdP分组到本地角色的映射，按顺序匹配，第一个命中的生效；配置后每次登录都会同步角色
+	GroupRoles []OIDCGroupRole `json:"groupRoles"`
+	// DefaultRole 没有命中任何分组时的角色；RequireGroup为true时没有命中分组的用户不能登录
+	DefaultRole  string `json:"defaultRole"`
+	RequireGroup bool   `json:"requireGroup"`
+	// LinkByEmail 首次登录时，IdP声明邮箱已验证且与本地用户已验证的邮箱一致则关联该用户
+	LinkByEmail bool `json:"linkByEmail"`
+	// DisablePasswordLogin 为true时关闭本地密码登录、注册和找回密码，只能通过单点登录
+	DisablePasswordLogin bool `json:"disablePasswordLogin"`
+}
+
+// OIDCGroupRole 单条分组到角色的映射
+type OIDCGroupRole struct {
+	Group string `json:"group"`
+	Role  string `json:"role"`
+}
+
+// LockoutConfig 登录防暴力破解配置，按账号和客户端IP分别统计失败次数。
+// 失败次数达到阈值后锁定，之后每多失败一次锁定时长翻倍，直至MaxDuration。
+type LockoutConfig struct {
+	UserThreshold int      `json:"userThreshold"` // 同一账号连续失败达到该次数后锁定
+	IPThreshold   int      `json:"ipThreshold"`   // 同一IP失败（含验证码错误）达到该次数后锁定
+	BaseDuration  Duration `json:"baseDuration"`  // 首次锁定时长
+	MaxDuration   Duration `json:"maxDuration"`   // 最长锁定时长
+	// Window 失败计数的保留时间，超过该时间没有新的失败则计数清零
+	Window Duration `json:"window"`
+}
+
+// 访问令牌模式
+const (
+	TokenModeOpaque = "opaque"
+	TokenModeJWT    = "jwt"
+)
+
+// JWTConfig JWT签发与验签配置
+type JWTConfig struct {
+	Issuer string `json:"issuer"`
+	// SigningKey 当前用于签发的密钥kid，其余密钥只用于校验轮换前签发的令牌
+	SigningKey string         `json:"signingKey"`
+	Keys       []JWTKeyConfig `json:"keys"`
+}
+
+// JWTKeyConfig 单个JWT密钥，HS256使用secret，RS256/EdDSA使用PEM私钥或公钥文件
+type JWTKeyConfig struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret"`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	PublicKeyFile  string `json:"publicKeyFile"`
+}
+
+// PasswordConfig argon2id密码哈希参数，调大可提高破解成本，但会增加登录耗时和内存占用
+type PasswordConfig struct {
+	Memory      uint32 `json:"memory"` // 内存开销(KiB)
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Auth: AuthConfig{
+			ProtectedRoutes: []string{"/cors-proxy", "/proxy", "/port-scan", "/ws", "/api/generate-qrcode", "/api/qrcode"},
+			RoutePermissions: map[string]string{
+				"/cors-proxy":          "proxy",
+				"/proxy":               "proxy",
+				"/port-scan":           "portscan",
+				"/ws":                  "websocket",
+				"/api/generate-qrcode": "qrcode",
+				"/api/qrcode":          "qrcode",
+			},
+			Roles: map[string][]string{
+				"admin": {"*"},
+				"user":  {"proxy", "portscan", "websocket", "qrcode"},
+			},
+			Password: PasswordConfig{
+				Memory:      64 * 1024,
+				Iterations:  3,
+				Parallelism: 2,
+			},
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(7 * 24 * time.Hour),
+			SweepInterval:   Duration(10 * time.Minute),
+			TokenMode:       TokenModeOpaque,
+			JWT: JWTConfig{
+				Issuer: "lf-web-tools",
+			},
+			Lockout: LockoutConfig{
+				UserThreshold: 5,
+				IPThreshold:   20,
+				BaseDuration:  Duration(time.Minute),
+				MaxDuration:   Duration(time.Hour),
+				Window:        Duration(15 * time.Minute),
+			},
+			ResetTokenTTL:  Duration(30 * time.Minute),
+			VerifyTokenTTL: Duration(24 * time.Hour),
+			OIDC: OIDCConfig{
+				Name:          "单点登录",
+				Scopes:        []string{"openid", "profile", "email"},
+				UsernameClaim: "preferred_username",
+				GroupsClaim:   "groups",
+				DefaultRole:   "user",
+			},
+		},
+		Storage: StorageConfig{
+			Backend: StorageJSON,
+			Dir:     "data",
+		},
+		Captcha: CaptchaConfig{
+			Provider:   "text",
+			Difficulty: "medium",
+			TTL:        Duration(5 * time.Minute),
+		},
+		Audit: AuditConfig{
+			Backend:   AuditFile,
+			Dir:       "data/audit",
+			MaxSizeMB: 10,
+			MaxFiles:  10,
+			MaxEvents: 100000,
+		},
+		Proxy: ProxyConfig{
+			MaxBodyMB:     10,
+			HistoryLimit:  100,
+			HistoryBodyKB: 64,
+		},
+		Mail: MailConfig{
+			Backend: MailFile,
+			Dir:     "data/mail",
+			From:    "LF Web Tools <noreply@localhost>",
+			BaseURL: "http://localhost:8080",
+		},
+	}
+}
+
+// Load 从指定路径加载配置，文件不存在时返回默认配置
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	cfg.fillDefaults()
+	return cfg, nil
+}
+
+// fillDefaults 将配置文件中显式置零的数值恢复为默认值
+func (c *Config) fillDefaults() {
+	def := Default()
+	if c.Auth.Password.Memory == 0 {
+		c.Auth.Password.Memory = def.Auth.Password.Memory
+	}
+	if c.Auth.Password.Iterations == 0 {
+		c.Auth.Password.Iterations = def.Auth.Password.Iterations
+	}
+	if c.Auth.Password.Parallelism == 0 {
+		c.Auth.Password.Parallelism = def.Auth.Password.Parallelism
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		c.Auth.AccessTokenTTL = def.Auth.AccessTokenTTL
+	}
+	if c.Auth.RefreshTokenTTL <= 0 {
+		c.Auth.RefreshTokenTTL = def.Auth.RefreshTokenTTL
+	}
+	if c.Auth.SweepInterval <= 0 {
+		c.Auth.SweepInterval = def.Auth.SweepInterval
+	}
+	if c.Auth.TokenMode == "" {
+		c.Auth.TokenMode = def.Auth.TokenMode
+	}
+	if c.Auth.Lockout.UserThreshold <= 0 {
+		c.Auth.Lockout.UserThreshold = def.Auth.Lockout.UserThreshold
+	}
+	if c.Auth.Lockout.IPThreshold <= 0 {
+		c.Auth.Lockout.IPThreshold = def.Auth.Lockout.IPThreshold
+	}
+	if c.Auth.Lockout.BaseDuration <= 0 {
+		c.Auth.Lockout.BaseDuration = def.Auth.Lockout.BaseDuration
+	}
+	if c.Auth.Lockout.MaxDuration <= 0 {
+		c.Auth.Lockout.MaxDuration = def.Auth.Lockout.MaxDuration
+	}
+	if c.Auth.Lockout.MaxDuration < c.Auth.Lockout.BaseDuration {
+		c.Auth.Lockout.MaxDuration = c.Auth.Lockout.BaseDuration
+	}
+	if c.Auth.Lockout.Window <= 0 {
+		c.Auth.Lockout.Window = def.Auth.Lockout.Window
+	}
+	if c.Auth.ResetTokenTTL <= 0 {
+		c.Auth.ResetTokenTTL = def.Auth.ResetTokenTTL
+	}
+	if c.Auth.VerifyTokenTTL <= 0 {
+		c.Auth.VerifyTokenTTL = def.Auth.VerifyTokenTTL
+	}
+	if c.Auth.OIDC.Name == "" {
+		c.Auth.OIDC.Name = def.Auth.OIDC.Name
+	}
+	if len(c.Auth.OIDC.Scopes) == 0 {
+		c.Auth.OIDC.Scopes = def.Auth.OIDC.Scopes
+	}
+	if c.Auth.OIDC.UsernameClaim == "" {
+		c.Auth.OIDC.UsernameClaim = def.Auth.OIDC.UsernameClaim
+	}
+	if c.Auth.OIDC.GroupsClaim == "" {
+		c.Auth.OIDC.GroupsClaim = def.Auth.OIDC.GroupsClaim
+	}
+	if c.Auth.OIDC.DefaultRole == "" {
+		c.Auth.OIDC.DefaultRole = def.Auth.OIDC.DefaultRole
+	}
+	if c.Audit.Backend == "" {
+		c.Audit.Backend = def.Audit.Backend
+	}
+	if c.Audit.Dir == "" {
+		c.Audit.Dir = def.Audit.Dir
+	}
+	if c.Audit.MaxSizeMB <= 0 {
+		c.Audit.MaxSizeMB = def.Audit.MaxSizeMB
+	}
+	if c.Audit.MaxFiles <= 0 {
+		c.Audit.MaxFiles = def.Audit.MaxFiles
+	}
+	if c.Audit.MaxEvents <= 0 {
+		c.Audit.MaxEvents = def.Audit.MaxEvents
+	}
+	if c.Proxy.MaxBodyMB <= 0 {
+		c.Proxy.MaxBodyMB = def.Proxy.MaxBodyMB
+	}
+	if c.Proxy.HistoryLimit <= 0 {
+		c.Proxy.HistoryLimit = def.Proxy.HistoryLimit
+	}
+	if c.Proxy.HistoryBodyKB <= 0 {
+		c.Proxy.HistoryBodyKB = def.Proxy.HistoryBodyKB
+	}
+	if c.Storage.Backend == "" {
+		c.Storage.Backend = def.Storage.Backend
+	}
+	if c.Storage.Dir == "" {
+		c.Storage.Dir = def.Storage.Dir
+	}
+	if c.Captcha.Provider == "" {
+		c.Captcha.Provider = def.Captcha.Provider
+	}
+	if c.Captcha.Difficulty == "" {
+		c.Captcha.Difficulty = def.Captcha.Difficulty
+	}
+	if c.Captcha.TTL <= 0 {
+		c.Captcha.TTL = def.Captcha.TTL
+	}
+	if c.Mail.Backend == "" {
+		c.Mail.Backend = def.Mail.Backend
+	}
+	if c.Mail.Dir == "" {
+		c.Mail.Dir = def.Mail.Dir
+	}
+	if c.Mail.From == "" {
+		c.Mail.From = def.Mail.From
+	}
+	if c.Mail.BaseURL == "" {
+		c.Mail.BaseURL = def.Mail.BaseURL
+	}
+}
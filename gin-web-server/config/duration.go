@@ -0,0 +1,28 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration 以 "15m"、"24h" 形式配置的时长
+type Duration time.Duration
+
+// UnmarshalJSON 解析时长字符串
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON 输出时长字符串
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
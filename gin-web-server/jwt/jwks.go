@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK 单个公钥的JWK表示
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS /.well-known/jwks.json 的响应结构
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 导出全部非对称密钥的公钥，HS256共享密钥不会公开
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0)}
+	for _, kid := range ks.order {
+		key := ks.keys[kid]
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Algorithm: key.Algorithm,
+				Use:       "sig",
+				N:         encodeSegment(public.N.Bytes()),
+				E:         encodeSegment(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Algorithm: key.Algorithm,
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         encodeSegment(public),
+			})
+		}
+	}
+	return set
+}
+
+// PublicKey 把JWK解析为公钥，支持RSA和Ed25519
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeSegment(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("JWK %s 的n无效: %w", k.KeyID, err)
+		}
+		e, err := decodeSegment(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("JWK %s 的e无效", k.KeyID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("不支持的曲线: %s", k.Curve)
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("JWK %s 的x无效", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型: %s", k.KeyType)
+}
+
+// KeySetFromJWKS 用外部JWKS创建仅用于验签的密钥集合，跳过加密用途和不支持的密钥
+func KeySetFromJWKS(set JWKS) (*KeySet, error) {
+	keys := make([]*Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		key, err := NewVerifyKey(jwk.KeyID, public)
+		if err != nil {
+			continue
+		}
+		if jwk.Algorithm != "" && jwk.Algorithm != key.Algorithm {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS中没有可用的签名公钥")
+	}
+	return NewKeySet("", keys...)
+}
@@ -0,0 +1,243 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 支持的签名算法
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrMalformed        = errors.New("令牌格式错误")
+	ErrUnknownKey       = errors.New("未知的签名密钥")
+	ErrInvalidSignature = errors.New("令牌签名无效")
+	ErrExpired          = errors.New("令牌已过期")
+	ErrNoExpiry         = errors.New("令牌缺少过期时间")
+	ErrNotYetValid      = errors.New("令牌尚未生效")
+)
+
+// Key 签名或验签密钥，kid写入JWT头部用于密钥轮换
+type Key struct {
+	ID        string
+	Algorithm string
+
+	secret  []byte           // HS256
+	private crypto.Signer    // RS256/EdDSA签名私钥，仅验签的旧密钥为空
+	public  crypto.PublicKey // RS256/EdDSA验签公钥
+}
+
+// NewHMACKey 创建HS256共享密钥
+func NewHMACKey(kid string, secret []byte) *Key {
+	return &Key{ID: kid, Algorithm: HS256, secret: secret}
+}
+
+// NewSigningKey 使用RSA或Ed25519私钥创建签名密钥
+func NewSigningKey(kid string, private crypto.Signer) (*Key, error) {
+	key, err := NewVerifyKey(kid, private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.private = private
+	return key, nil
+}
+
+// NewVerifyKey 使用RSA或Ed25519公钥创建仅用于验签的密钥
+func NewVerifyKey(kid string, public crypto.PublicKey) (*Key, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Algorithm: RS256, public: public}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Algorithm: EdDSA, public: public}, nil
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型: %T", public)
+	}
+}
+
+// CanSign 是否持有签名所需的密钥材料
+func (k *Key) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RS256:
+		digest := sha256.Sum256(input)
+		return rsa.SignPKCS1v15(rand.Reader, k.private.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case EdDSA:
+		return ed25519.Sign(k.private.(ed25519.PrivateKey), input), nil
+	}
+	return nil, fmt.Errorf("不支持的签名算法: %s", k.Algorithm)
+}
+
+func (k *Key) verify(input, signature []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(k.public.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case EdDSA:
+		return ed25519.Verify(k.public.(ed25519.PublicKey), input, signature)
+	}
+	return false
+}
+
+// KeySet 一组密钥：用当前签名密钥签发，用任意已知密钥按kid验签
+type KeySet struct {
+	keys    map[string]*Key
+	order   []string
+	signing *Key
+}
+
+// NewKeySet 创建密钥集合，signingKid指定签发使用的密钥
+func NewKeySet(signingKid string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key)}
+	for _, key := range keys {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("重复的密钥ID: %s", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.order = append(ks.order, key.ID)
+	}
+
+	if signingKid != "" {
+		signing, ok := ks.keys[signingKid]
+		if !ok || !signing.CanSign() {
+			return nil, fmt.Errorf("签名密钥 %s 不存在或缺少私钥", signingKid)
+		}
+		ks.signing = signing
+	}
+	return ks, nil
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Sign 使用当前签名密钥签发JWT
+func (ks *KeySet) Sign(claims interface{}) (string, error) {
+	if ks.signing == nil {
+		return "", errors.New("未配置签名密钥")
+	}
+
+	headerJSON, err := json.Marshal(header{Algorithm: ks.signing.Algorithm, Type: "JWT", KeyID: ks.signing.ID})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	signature, err := ks.signing.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + encodeSegment(signature), nil
+}
+
+// Verify 校验签名及exp/nbf，并把载荷解码到claims。exp是必需的，没有exp的令牌永不过期，一律拒绝
+func (ks *KeySet) Verify(token string, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return ErrMalformed
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return ErrMalformed
+	}
+
+	key := ks.lookup(h.KeyID)
+	if key == nil {
+		return ErrUnknownKey
+	}
+	// 算法必须与密钥绑定的算法一致，防止alg混淆攻击（如用公钥作HMAC密钥）
+	if h.Algorithm != key.Algorithm {
+		return ErrInvalidSignature
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return ErrMalformed
+	}
+	if !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return ErrInvalidSignature
+	}
+
+	claimsJSON, err := decodeSegment(parts[1])
+	if err != nil {
+		return ErrMalformed
+	}
+	var times struct {
+		ExpiresAt int64 `json:"exp"`
+		NotBefore int64 `json:"nbf"`
+	}
+	if err := json.Unmarshal(claimsJSON, &times); err != nil {
+		return ErrMalformed
+	}
+	now := time.Now().Unix()
+	if times.ExpiresAt == 0 {
+		return ErrNoExpiry
+	}
+	if now >= times.ExpiresAt {
+		return ErrExpired
+	}
+	if times.NotBefore != 0 && now < times.NotBefore {
+		return ErrNotYetValid
+	}
+
+	if err := json.Unmarshal(claimsJSON, claims); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// lookup 按kid查找验签密钥，签发的令牌总是带kid，没有kid的令牌一律拒绝
+func (ks *KeySet) lookup(kid string) *Key {
+	if kid == "" {
+		return nil
+	}
+	return ks.keys[kid]
+}
+
+// LooksLikeJWT 粗略判断令牌是否为JWT格式
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
@@ -0,0 +1,212 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+}
+
+func validClaims() testClaims {
+	return testClaims{Subject: "alice", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+}
+
+// testKeys 每种算法一个签名密钥
+func testKeys(t *testing.T) []*Key {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSigning, err := NewSigningKey("rsa", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSigning, err := NewSigningKey("ed", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []*Key{NewHMACKey("hmac", []byte(strings.Repeat("s", 32))), rsaSigning, edSigning}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, key := range testKeys(t) {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			ks, err := NewKeySet(key.ID, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := ks.Sign(validClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !LooksLikeJWT(token) {
+				t.Fatalf("token = %s", token)
+			}
+			var got testClaims
+			if err := ks.Verify(token, &got); err != nil || got.Subject != "alice" {
+				t.Fatalf("Verify = %+v, %v", got, err)
+			}
+
+			// 篡改载荷后签名无效
+			parts := strings.Split(token, ".")
+			forged, _ := json.Marshal(testClaims{Subject: "admin", ExpiresAt: validClaims().ExpiresAt})
+			parts[1] = encodeSegment(forged)
+			if err := ks.Verify(strings.Join(parts, "."), &got); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("forged payload: %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestVerifyTimeClaims(t *testing.T) {
+	ks, err := NewKeySet("hmac", NewHMACKey("hmac", []byte(strings.Repeat("s", 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tests := []struct {
+		name   string
+		claims testClaims
+		want   error
+	}{
+		{"valid", testClaims{ExpiresAt: now.Add(time.Minute).Unix()}, nil},
+		{"missing exp", testClaims{}, ErrNoExpiry},
+		{"expired", testClaims{ExpiresAt: now.Add(-time.Second).Unix()}, ErrExpired},
+		{"not yet valid", testClaims{ExpiresAt: now.Add(time.Hour).Unix(), NotBefore: now.Add(time.Minute).Unix()}, ErrNotYetValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ks.Sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ks.Verify(token, &testClaims{}); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	_, oldPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	_, newPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	oldKey, _ := NewSigningKey("2025-01", oldPrivate)
+	newKey, _ := NewSigningKey("2025-06", newPrivate)
+
+	before, err := NewKeySet("2025-01", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := before.Sign(validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后用新密钥签发，旧密钥只保留公钥用于验签
+	oldVerify, err := NewVerifyKey("2025-01", oldPrivate.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := NewKeySet("2025-06", newKey, oldVerify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := after.Sign(validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(decodeHeader(t, newToken), `"kid":"2025-06"`) {
+		t.Errorf("new token header = %s", decodeHeader(t, newToken))
+	}
+	for _, token := range []string{oldToken, newToken} {
+		if err := after.Verify(token, &testClaims{}); err != nil {
+			t.Errorf("Verify after rotation: %v", err)
+		}
+	}
+	// 旧密钥集不认识新kid
+	if err := before.Verify(newToken, &testClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("old key set verify new token: %v, want ErrUnknownKey", err)
+	}
+	// 仅验签的密钥不能作为签名密钥
+	if _, err := NewKeySet("2025-01", oldVerify); err == nil {
+		t.Error("NewKeySet accepted a verify-only signing key")
+	}
+	if _, err := NewKeySet("", oldKey, oldVerify); err == nil {
+		t.Error("NewKeySet accepted duplicate kids")
+	}
+
+	// JWKS导出的公钥可以独立验签
+	remote, err := KeySetFromJWKS(after.JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Verify(newToken, &testClaims{}); err != nil {
+		t.Errorf("Verify with JWKS: %v", err)
+	}
+}
+
+func TestVerifyRejectsAlgorithmConfusion(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := NewSigningKey("ed", private)
+	ks, _ := NewKeySet("ed", key)
+
+	// 以公钥作为HMAC密钥伪造HS256令牌
+	hmacKey := NewHMACKey("ed", []byte(private.Public().(ed25519.PublicKey)))
+	forger, _ := NewKeySet("ed", hmacKey)
+	forged, err := forger.Sign(validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Verify(forged, &testClaims{}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("HS256 token with public key: %v, want ErrInvalidSignature", err)
+	}
+
+	none := encodeSegment([]byte(`{"alg":"none","kid":"ed"}`)) + "." + encodeSegment([]byte(`{"sub":"alice","exp":9999999999}`)) + "."
+	if err := ks.Verify(none, &testClaims{}); err == nil {
+		t.Error("alg none token was accepted")
+	}
+	// 只有一个密钥时也不接受没有kid的令牌
+	parts := strings.Split(mustSign(t, ks), ".")
+	noKid := encodeSegment([]byte(`{"alg":"EdDSA"}`)) + "." + parts[1] + "." + parts[2]
+	if err := ks.Verify(noKid, &testClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token without kid: %v, want ErrUnknownKey", err)
+	}
+	for _, token := range []string{"", "a.b", "a.b.c", "!!.!!.!!"} {
+		if err := ks.Verify(token, &testClaims{}); err == nil {
+			t.Errorf("Verify(%q) succeeded", token)
+		}
+	}
+}
+
+func mustSign(t *testing.T, ks *KeySet) string {
+	t.Helper()
+	token, err := ks.Sign(validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func decodeHeader(t *testing.T, token string) string {
+	t.Helper()
+	header, err := decodeSegment(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(header)
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSender 把邮件写入目录下的.eml文件并打印到日志，用于本地开发和测试
+type FileSender struct {
+	dir  string
+	from string
+}
+
+// NewFileSender 创建写文件的发送器，目录不存在时自动创建
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+func (s *FileSender) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), sanitizeFileName(msg.To))
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, buildMessage(s.from, msg), 0o600); err != nil {
+		return err
+	}
+	log.Printf("[MAIL] 发送给 %s 的邮件「%s」已写入 %s\n%s", msg.To, msg.Subject, path, msg.Body)
+	return nil
+}
+
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,44 @@
+// Package mail 邮件发送，生产环境使用SMTP，本地开发可写入文件并打印到日志。
+package mail
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// validate 拒绝包含换行的收件人和主题，防止邮件头注入
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return fmt.Errorf("无效的收件人或主题")
+	}
+	return nil
+}
+
+// Sender 邮件发送接口
+type Sender interface {
+	Send(msg Message) error
+}
+
+// New 按配置创建邮件发送器
+func New(cfg config.MailConfig) (Sender, error) {
+	switch cfg.Backend {
+	case config.MailFile:
+		return NewFileSender(cfg.Dir, cfg.From)
+	case config.MailSMTP:
+		if cfg.SMTP.Host == "" || cfg.From == "" {
+			return nil, fmt.Errorf("SMTP发信需要配置host和from")
+		}
+		return NewSMTPSender(cfg.SMTP, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("不支持的邮件发送方式: %s", cfg.Backend)
+	}
+}
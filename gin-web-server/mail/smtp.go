@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+// SMTPSender 通过SMTP服务器发信，服务器支持时自动使用STARTTLS
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender 创建SMTP发送器，未配置用户名时不做认证
+func NewSMTPSender(cfg config.SMTPConfig, from string) *SMTPSender {
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	s := &SMTPSender{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		from: from,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return s
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, buildMessage(s.from, msg)); err != nil {
+		return fmt.Errorf("SMTP发信失败: %w", err)
+	}
+	return nil
+}
+
+// buildMessage 生成UTF-8纯文本邮件，正文使用base64编码
+func buildMessage(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
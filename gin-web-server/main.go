@@ -1,16 +1,54 @@
 package main
 
 import (
+	"flag"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/config"
 	"github.com/lf-web-tools/gin-web-server/middleware"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
 	"github.com/lf-web-tools/gin-web-server/routes"
+	"github.com/lf-web-tools/gin-web-server/store"
+	"github.com/lf-web-tools/gin-web-server/workspace"
 )
 
 func main() {
+	// 解析命令行参数
+	configPath := flag.String("config", "config.json", "Path to the config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	// 打开用户和令牌存储
+	st, err := store.Open(cfg.Storage)
+	if err != nil {
+		log.Fatalf("打开存储失败: %v", err)
+	}
+	defer st.Close()
+
+	// 打开审计日志
+	auditSink, err := audit.Open(cfg.Audit, cfg.Storage, st)
+	if err != nil {
+		log.Fatalf("打开审计日志失败: %v", err)
+	}
+	defer auditSink.Close()
+	audit.SetSink(auditSink)
+
 	// 创建一个默认的gin路由引擎
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("信任代理配置无效: %v", err)
+	}
+
+	// 需要登录才能访问的路由（CORS代理、端口扫描、WebSocket等），须在注册路由前挂载；
+	// 透传代理的CORS头在登录校验之前设置，401和403响应也能被浏览器读取
+	r.Use(middleware.PassthroughCORSHeaders(), routes.ProtectRoutes(cfg.Auth))
 
 	// 设置静态文件目录
 	r.Static("/static", "./static")
@@ -37,19 +75,30 @@ func main() {
 	})
 
 	// 设置API路由
-	routes.SetupAPIRoutes(r)
+	routes.SetupAPIRoutes(r, cfg, st)
 
 	// 设置WebSocket路由
-	routes.SetupWebSocketRoutes(r)
+	routes.SetupWebSocketRoutes(r, cfg.WebSocketOrigins)
 
 	// 设置页面路由
 	routes.SetupPageRoutes(r)
 
+	// 代理和端口扫描的目标地址策略，按登录用户的角色选择规则
+	destinations, err := netpolicy.New(cfg.Destinations)
+	if err != nil {
+		log.Fatalf("目标地址策略配置无效: %v", err)
+	}
+	middleware.SetDestinationPolicy(destinations)
+	middleware.SetAuthContext(routes.CurrentUser, routes.CurrentRole)
+
+	// CURL测试的请求历史、集合和环境保存在同一存储中
+	workspace.Setup(st, cfg.Proxy)
+
 	// 设置CORS代理路由
-	middleware.RegisterCorsProxyRoutes(r)
+	middleware.RegisterCorsProxyRoutes(r, cfg.Proxy, routes.RequireAccess(routes.PermProxy))
 
 	// 设置端口扫描路由
-	middleware.RegisterPortScanRoutes(r)
+	middleware.RegisterPortScanRoutes(r, routes.RequireAccess(routes.PermPortScan))
 
 	// 启动服务器
 	r.Run(":8080") // 监听并在0.0.0.0:8081上启动服务
@@ -1,43 +1,91 @@
 package middleware
 
 import (
-	"crypto/tls"
-	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
 	"net/url"
-	"os"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
+)
+
+// 审计日志的操作类型
+const (
+	auditProxyRequest = "proxy.request"
+	auditPortScan     = "portscan.scan"
 )
 
 // CurlRequest 定义请求体结构
 type CurlRequest struct {
-	CurlParam string `json:"curlParam" binding:"required"`
+	CurlParam string `json:"curlParam" form:"curlParam" binding:"required"`
+	// Files 命令中@file、<file引用的文件，键为文件名，值为base64编码的内容；
+	// 也可以用multipart/form-data上传，文件名即为键
+	Files map[string]string `json:"files" form:"-"`
+	// Raw 为true时直接返回上游的状态码、响应头和响应体，而不是JSON格式的CurlResponse
+	Raw bool `json:"raw" form:"raw"`
+	// Environment 当前用户的环境名称，执行前把命令中的{{name}}替换为该环境的变量
+	Environment string `json:"environment" form:"environment"`
 }
 
 // CurlResponse 定义响应体结构
 type CurlResponse struct {
-	ExecutionTime   string            `json:"executionTime"`
-	StatusCode      int               `json:"statusCode"`
-	ResponseBody    string            `json:"responseBody"`
-	ResponseHeaders map[string]string `json:"responseHeaders"`
-	Error           string            `json:"error,omitempty"`
+	ExecutionTime string `json:"executionTime"`
+	curlcmd.Result
+	Error     string `json:"error,omitempty"`
+	HistoryID string `json:"historyId,omitempty"` // 保存的历史记录ID，可用于置顶、删除和重放
+}
+
+// maxBodySize 返回的响应体的最大字节数，由RegisterCorsProxyRoutes按配置设置；raw模式直接转发，不受限制
+var maxBodySize int64
+
+// currentUser、currentRole 获取登录用户名和角色，由SetAuthContext设置为routes包的实现
+var (
+	currentUser = func(*gin.Context) string { return "" }
+	currentRole = func(*gin.Context) string { return "" }
+)
+
+// SetAuthContext 设置获取登录用户名和角色的方法，用于按用户保存请求历史和按角色选择目标地址规则
+func SetAuthContext(userOf, roleOf func(*gin.Context) string) {
+	currentUser, currentRole = userOf, roleOf
+}
+
+// setCORSHeaders 设置透传代理的CORS响应头
+func setCORSHeaders(c *gin.Context) {
+	// 允许所有来源的CORS请求
+	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+	c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, PATCH, HEAD")
+	// 透传代理会转发任意请求头，预检请求中声明的请求头都允许
+	allowHeaders := "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization"
+	if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+		allowHeaders = requested
+	}
+	c.Writer.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+	// 允许前端读取透传代理返回的全部上游响应头
+	c.Writer.Header().Set("Access-Control-Expose-Headers", "*")
+}
+
+// PassthroughCORSHeaders 返回为透传代理路径提前设置CORS响应头的中间件，须挂载在登录校验之前，
+// 使未登录（401）和无权限（403）的响应也带有CORS头，浏览器中的页面才能读到错误信息
+func PassthroughCORSHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, passthroughPrefix) {
+			setCORSHeaders(c)
+		}
+		c.Next()
+	}
 }
 
 // CorsProxyMiddleware 返回一个处理CORS代理请求的中间件
 func CorsProxyMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 允许所有来源的CORS请求
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		setCORSHeaders(c)
 
 		// 处理预检请求
 		if c.Request.Method == "OPTIONS" {
@@ -58,454 +106,169 @@ func HandleCurlProxy(c *gin.Context) {
 	fmt.Printf("[CORS-PROXY] [%s] 收到请求 - 客户端IP: %s, 请求方法: %s, 请求路径: %s\n",
 		requestID, clientIP, c.Request.Method, c.Request.URL.Path)
 
-	var request CurlRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
+	request, files, err := bindCurlRequest(c)
+	if err != nil {
 		fmt.Printf("[CORS-PROXY] [%s] 请求体解析失败: %v\n", requestID, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 打印CURL命令（截断过长的命令）
-	curlCmd := request.CurlParam
-	if len(curlCmd) > 100 {
-		fmt.Printf("[CORS-PROXY] [%s] CURL命令: %s...(已截断，总长度: %d)\n",
-			requestID, curlCmd[:100], len(curlCmd))
-	} else {
-		fmt.Printf("[CORS-PROXY] [%s] CURL命令: %s\n", requestID, curlCmd)
+	// 替换环境变量
+	curlCmd, envWarnings, err := expandCommand(c, request.CurlParam, request.Environment)
+	if err != nil {
+		fmt.Printf("[CORS-PROXY] [%s] %v\n", requestID, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Environment != "" {
+		fmt.Printf("[CORS-PROXY] [%s] 使用环境: %s\n", requestID, request.Environment)
+	}
+
+	// 只打印请求方法和隐藏了用户信息、查询参数的目标地址，命令中的请求头和数据可能带有令牌
+	fmt.Printf("[CORS-PROXY] [%s] 请求目标: %s\n", requestID, proxyAuditTarget(curlCmd))
+
+	// raw模式直接转发上游响应
+	if request.Raw {
+		fmt.Printf("[CORS-PROXY] [%s] raw模式，直接转发上游响应\n", requestID)
+		status, err := streamCurlAsHTTP(c, requestID, curlCmd, files)
+		if err != nil {
+			audit.Log(c, auditProxyRequest, proxyAuditTarget(curlCmd), failureResult(err), err.Error())
+		} else {
+			audit.Log(c, auditProxyRequest, proxyAuditTarget(curlCmd), audit.ResultSuccess, fmt.Sprintf("HTTP %d (raw)", status))
+		}
+		return
 	}
 
 	// 解析curl命令并执行HTTP请求
 	startTime := time.Now()
 	fmt.Printf("[CORS-PROXY] [%s] 开始执行请求...\n", requestID)
 
-	statusCode, responseBody, responseHeaders, err := executeCurlAsHTTP(request.CurlParam)
+	var response CurlResponse
+	err = executeCurlAsHTTP(curlCmd, files, destinationRule(c), &response)
 	executionTime := time.Since(startTime)
+	response.ExecutionTime = executionTime.String()
+	response.Warnings = append(envWarnings, response.Warnings...)
 
 	// 打印响应信息
 	if err != nil {
 		fmt.Printf("[CORS-PROXY] [%s] 请求失败: %v, 耗时: %v\n", requestID, err, executionTime)
 	} else {
-		// 截断响应体以避免日志过长
-		bodyPreview := responseBody
-		if len(bodyPreview) > 200 {
-			bodyPreview = bodyPreview[:200] + "...(已截断)"
-		}
-
 		fmt.Printf("[CORS-PROXY] [%s] 请求成功: 状态码=%d, 响应头数量=%d, 响应体长度=%d, 耗时=%v\n",
-			requestID, statusCode, len(responseHeaders), len(responseBody), executionTime)
-		fmt.Printf("[CORS-PROXY] [%s] 响应体预览: %s\n", requestID, bodyPreview)
-	}
-
-	response := CurlResponse{
-		ExecutionTime:   executionTime.String(),
-		StatusCode:      statusCode,
-		ResponseBody:    responseBody,
-		ResponseHeaders: responseHeaders,
+			requestID, response.StatusCode, len(response.ResponseHeaders), len(response.ResponseBody), executionTime)
 	}
 
 	if err != nil {
 		response.Error = err.Error()
+		audit.Log(c, auditProxyRequest, proxyAuditTarget(curlCmd), failureResult(err), err.Error())
+	} else {
+		audit.Log(c, auditProxyRequest, proxyAuditTarget(curlCmd), audit.ResultSuccess, fmt.Sprintf("HTTP %d", response.StatusCode))
 	}
+	recordHistory(c, request.CurlParam, request.Environment, curlCmd, &response)
 
 	fmt.Printf("[CORS-PROXY] [%s] 请求处理完成, 总耗时: %v\n", requestID, executionTime)
 	c.JSON(http.StatusOK, response)
 }
 
-// CurlCommand 表示解析后的curl命令
-type CurlCommand struct {
-	Method          string
-	URL             string
-	Headers         map[string]string
-	Data            string
-	Insecure        bool
-	FormData        map[string]string
-	QueryParams     map[string]string
-	Timeout         int               // 连接超时时间（秒）
-	FollowRedirects bool              // 是否跟随重定向
-	Auth            string            // 基本认证信息
-	Cookies         map[string]string // Cookie信息
-}
-
-// executeCurlAsHTTP 将curl命令解析为HTTP请求并执行
-func executeCurlAsHTTP(curlCmd string) (int, string, map[string]string, error) {
-	requestID := fmt.Sprintf("%d", time.Now().UnixNano())
-
-	// 确保命令以curl开头
-	if !strings.HasPrefix(curlCmd, "curl ") {
-		return 0, "", nil, fmt.Errorf("command must start with 'curl'")
-	}
-
-	// 将多行命令合并为一行（去掉行尾的反斜杠和换行符）
-	curlCmd = strings.ReplaceAll(curlCmd, "\\\n", " ")
-	curlCmd = strings.ReplaceAll(curlCmd, "\\\r\n", " ")
-
-	fmt.Printf("[CORS-PROXY] [%s] 开始解析CURL命令...\n", requestID)
-
-	// 解析curl命令
-	cmd, err := parseCurlCommand(curlCmd)
+// proxyAuditTarget 审计日志中记录的目标地址，去掉URL中的用户信息和查询参数，避免把凭据写入日志
+func proxyAuditTarget(curlCmd string) string {
+	cmd, err := curlcmd.Parse(curlCmd)
 	if err != nil {
-		fmt.Printf("[CORS-PROXY] [%s] 解析CURL命令失败: %v\n", requestID, err)
-		return 0, "", nil, err
-	}
-
-	fmt.Printf("[CORS-PROXY] [%s] 解析结果: 方法=%s, URL=%s, 数据长度=%d, 头部数量=%d, Insecure=%v\n",
-		requestID, cmd.Method, cmd.URL, len(cmd.Data), len(cmd.Headers), cmd.Insecure)
-
-	// 创建HTTP客户端
-	transport := &http.Transport{
-		// 设置更宽松的连接超时
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+		return ""
 	}
-
-	// 如果指定了insecure选项，则跳过TLS证书验证
-	if cmd.Insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		fmt.Printf("[CORS-PROXY] [%s] 启用不安全模式，跳过TLS证书验证\n", requestID)
+	u, err := url.Parse(cmd.URL)
+	if err != nil {
+		return cmd.Method
 	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return cmd.Method + " " + u.String()
+}
 
-	// 检查环境变量中是否设置了HTTP代理
-	httpProxy := os.Getenv("HTTP_PROXY")
-	httpsProxy := os.Getenv("HTTPS_PROXY")
-
-	if httpProxy != "" || httpsProxy != "" {
-		proxyURL, err := url.Parse(httpProxy)
-		if err == nil && httpProxy != "" {
-			transport.Proxy = http.ProxyURL(proxyURL)
-			fmt.Printf("[CORS-PROXY] [%s] 使用HTTP代理: %s\n", requestID, httpProxy)
-		}
-
-		if httpsProxy != "" && httpProxy != httpsProxy {
-			proxyURL, err = url.Parse(httpsProxy)
-			if err == nil {
-				transport.Proxy = http.ProxyURL(proxyURL)
-				fmt.Printf("[CORS-PROXY] [%s] 使用HTTPS代理: %s\n", requestID, httpsProxy)
-			}
+// bindCurlRequest 解析代理请求，支持JSON和multipart/form-data两种格式，同时返回随请求上传的文件
+func bindCurlRequest(c *gin.Context) (*CurlRequest, curlcmd.Files, error) {
+	var request CurlRequest
+	if c.ContentType() == binding.MIMEMultipartPOSTForm {
+		if err := c.ShouldBindWith(&request, binding.FormMultipart); err != nil {
+			return nil, nil, err
 		}
-	}
-
-	// 设置客户端选项
-	client := &http.Client{
-		Timeout:   time.Duration(cmd.Timeout) * time.Second,
-		Transport: transport,
-	}
-
-	// 设置重定向策略
-	if cmd.FollowRedirects {
-		fmt.Printf("[CORS-PROXY] [%s] 启用跟随重定向\n", requestID)
-	} else {
-		// 不跟随重定向
-		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
+		form, err := c.MultipartForm()
+		if err != nil {
+			return nil, nil, err
 		}
+		files, err := curlcmd.FilesFromMultipart(form)
+		return &request, files, err
 	}
 
-	// 准备请求体
-	var reqBody io.Reader
-	if cmd.Data != "" {
-		reqBody = strings.NewReader(cmd.Data)
-		fmt.Printf("[CORS-PROXY] [%s] 设置请求体数据 (%d字节)\n", requestID, len(cmd.Data))
-	}
-
-	// 创建HTTP请求
-	fmt.Printf("[CORS-PROXY] [%s] 创建HTTP请求: %s %s\n", requestID, cmd.Method, cmd.URL)
-	req, err := http.NewRequest(cmd.Method, cmd.URL, reqBody)
-	if err != nil {
-		fmt.Printf("[CORS-PROXY] [%s] 创建HTTP请求失败: %v\n", requestID, err)
-		return 0, "", nil, fmt.Errorf("创建HTTP请求失败: %v", err)
-	}
-
-	// 添加请求头
-	for key, value := range cmd.Headers {
-		req.Header.Set(key, value)
-		fmt.Printf("[CORS-PROXY] [%s] 添加请求头: %s: %s\n", requestID, key, value)
-	}
-
-	// 如果没有设置User-Agent，添加默认User-Agent
-	if _, exists := cmd.Headers["User-Agent"]; !exists {
-		defaultUserAgent := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
-		req.Header.Set("User-Agent", defaultUserAgent)
-		fmt.Printf("[CORS-PROXY] [%s] 添加默认User-Agent: %s\n", requestID, defaultUserAgent)
+	if err := c.ShouldBindJSON(&request); err != nil {
+		return nil, nil, err
 	}
+	files, err := curlcmd.DecodeFiles(request.Files)
+	return &request, files, err
+}
 
-	// 执行请求
-	fmt.Printf("[CORS-PROXY] [%s] 发送HTTP请求: %s %s\n", requestID, req.Method, req.URL.String())
-
-	// 打印所有请求头，便于调试
-	fmt.Printf("[CORS-PROXY] [%s] 请求头详情:\n", requestID)
-	for k, v := range req.Header {
-		fmt.Printf("[CORS-PROXY] [%s]   %s: %s\n", requestID, k, strings.Join(v, ", "))
+// newExecutor 返回执行curl命令的Executor，日志带上requestID；
+// 请求地址、重定向地址和实际连接的IP都要经过dest检查
+func newExecutor(requestID string, files curlcmd.Files, dest curlcmd.DestinationChecker) *curlcmd.Executor {
+	return &curlcmd.Executor{
+		Files:        files,
+		Destinations: dest,
+		MaxBodySize:  maxBodySize,
+		Logf: func(format string, args ...interface{}) {
+			fmt.Printf("[CORS-PROXY] [%s] "+format+"\n", append([]interface{}{requestID}, args...)...)
+		},
 	}
+}
 
-	startTime := time.Now()
-	resp, err := client.Do(req)
-	requestDuration := time.Since(startTime)
+// executeCurlAsHTTP 将curl命令解析为HTTP请求并执行，结果写入response
+func executeCurlAsHTTP(curlCmd string, files curlcmd.Files, dest curlcmd.DestinationChecker, response *CurlResponse) error {
+	requestID := fmt.Sprintf("%d", time.Now().UnixNano())
+	return newExecutor(requestID, files, dest).Execute(curlCmd, &response.Result)
+}
 
+// streamCurlAsHTTP raw模式：执行请求后把上游的状态码、响应头和响应体原样转发，不经过JSON包装
+func streamCurlAsHTTP(c *gin.Context, requestID, curlCmd string, files curlcmd.Files) (int, error) {
+	var (
+		result curlcmd.Result
+		tracer curlcmd.Tracer
+	)
+	executor := newExecutor(requestID, files, destinationRule(c))
+	executor.Stream = true
+	_, resp, err := executor.Send(curlCmd, &tracer, &result)
 	if err != nil {
-		fmt.Printf("[CORS-PROXY] [%s] 执行HTTP请求失败: %v, 耗时: %v\n", requestID, err, requestDuration)
-		// 检查是否是DNS解析错误
-		if strings.Contains(err.Error(), "lookup") && strings.Contains(err.Error(), "no such host") {
-			return 0, "", nil, fmt.Errorf("DNS解析失败，无法找到主机: %v", err)
-		}
-		// 检查是否是连接超时
-		if strings.Contains(err.Error(), "timeout") {
-			return 0, "", nil, fmt.Errorf("请求超时: %v", err)
+		body := gin.H{"error": err.Error()}
+		if len(result.Warnings) > 0 {
+			body["warnings"] = result.Warnings
 		}
-		// 检查是否是TLS错误
-		if strings.Contains(err.Error(), "tls") || strings.Contains(err.Error(), "certificate") {
-			return 0, "", nil, fmt.Errorf("TLS/SSL错误: %v，请尝试添加--insecure选项", err)
+		status := http.StatusBadGateway
+		if errors.Is(err, netpolicy.ErrDenied) {
+			status = http.StatusForbidden
 		}
-		return 0, "", nil, fmt.Errorf("执行HTTP请求失败: %v", err)
-	}
-
-	fmt.Printf("[CORS-PROXY] [%s] 收到响应: 状态码=%d, 耗时=%v\n", requestID, resp.StatusCode, requestDuration)
-
-	// 打印响应头，便于调试
-	fmt.Printf("[CORS-PROXY] [%s] 响应头详情:\n", requestID)
-	for k, v := range resp.Header {
-		fmt.Printf("[CORS-PROXY] [%s]   %s: %s\n", requestID, k, strings.Join(v, ", "))
+		c.JSON(status, body)
+		return 0, err
 	}
-
 	defer resp.Body.Close()
 
-	// 读取响应体
-	fmt.Printf("[CORS-PROXY] [%s] 读取响应体...\n", requestID)
-	bodyBytes, err := io.ReadAll(resp.Body)
+	written, err := curlcmd.Forward(c.Writer, resp)
+	fmt.Printf("[CORS-PROXY] [%s] raw模式转发完成: 状态码=%d, 响应体大小=%d字节\n", requestID, resp.StatusCode, written)
 	if err != nil {
-		fmt.Printf("[CORS-PROXY] [%s] 读取响应体失败: %v\n", requestID, err)
-		return resp.StatusCode, "", headerToMap(resp.Header), fmt.Errorf("读取响应体失败: %v", err)
-	}
-
-	responseHeaders := headerToMap(resp.Header)
-	fmt.Printf("[CORS-PROXY] [%s] 响应处理完成: 状态码=%d, 响应体大小=%d字节, 响应头数量=%d\n",
-		requestID, resp.StatusCode, len(bodyBytes), len(responseHeaders))
-
-	return resp.StatusCode, string(bodyBytes), responseHeaders, nil
-}
-
-// parseCurlCommand 解析curl命令
-func parseCurlCommand(curlCmd string) (*CurlCommand, error) {
-	cmd := &CurlCommand{
-		Method:          "GET",
-		Headers:         make(map[string]string),
-		FormData:        make(map[string]string),
-		QueryParams:     make(map[string]string),
-		Cookies:         make(map[string]string),
-		FollowRedirects: false,
-		Timeout:         30, // 默认30秒超时
-	}
-
-	// 先提取请求方法，避免将-X误认为是URL的一部分
-	methodPattern := regexp.MustCompile(`-X\s+(\S+)`)
-	methodMatches := methodPattern.FindStringSubmatch(curlCmd)
-	if len(methodMatches) >= 2 {
-		cmd.Method = methodMatches[1]
-	}
-
-	// 提取URL (在处理完方法后)
-	// 尝试直接匹配http或https URL
-	urlPattern := regexp.MustCompile(`(https?://[^\s'"]+)`)
-	urlMatches := urlPattern.FindStringSubmatch(curlCmd)
-
-	if len(urlMatches) >= 1 {
-		cmd.URL = urlMatches[1]
-	} else {
-		// 如果没有找到http开头的URL，尝试其他方式
-		quotedUrlPattern := regexp.MustCompile(`curl\s+['"]([^'"]+)['"]`)
-		quotedUrlMatches := quotedUrlPattern.FindStringSubmatch(curlCmd)
-
-		if len(quotedUrlMatches) >= 2 {
-			cmd.URL = quotedUrlMatches[1]
-		} else {
-			// 分析命令行参数，找到不是选项的参数作为URL
-			parts := strings.Fields(curlCmd)
-			for i, part := range parts {
-				// 跳过curl本身和所有选项及其参数
-				if part == "curl" || strings.HasPrefix(part, "-") {
-					continue
-				}
-				// 跳过选项的参数
-				if i > 0 && (parts[i-1] == "-X" || parts[i-1] == "-H" ||
-					parts[i-1] == "-d" || parts[i-1] == "--data" ||
-					parts[i-1] == "--data-raw") {
-					continue
-				}
-				// 找到的第一个非选项参数可能是URL
-				cmd.URL = part
-				break
-			}
-
-			// 如果仍然没有找到URL
-			if cmd.URL == "" {
-				return nil, fmt.Errorf("无法解析URL")
-			}
-		}
-	}
-
-	// 提取请求头 - 支持多种引号格式
-	// 单引号
-	headerPattern1 := regexp.MustCompile(`-H\s+'([^']+)'`)
-	headerMatches1 := headerPattern1.FindAllStringSubmatch(curlCmd, -1)
-	for _, match := range headerMatches1 {
-		if len(match) >= 2 {
-			headerParts := strings.SplitN(match[1], ":", 2)
-			if len(headerParts) == 2 {
-				cmd.Headers[strings.TrimSpace(headerParts[0])] = strings.TrimSpace(headerParts[1])
-			}
-		}
-	}
-
-	// 双引号
-	headerPattern2 := regexp.MustCompile(`-H\s+"([^"]+)"`)
-	headerMatches2 := headerPattern2.FindAllStringSubmatch(curlCmd, -1)
-	for _, match := range headerMatches2 {
-		if len(match) >= 2 {
-			headerParts := strings.SplitN(match[1], ":", 2)
-			if len(headerParts) == 2 {
-				cmd.Headers[strings.TrimSpace(headerParts[0])] = strings.TrimSpace(headerParts[1])
-			}
-		}
-	}
-
-	// 提取请求体数据 - 支持多种数据格式和引号
-	// 1. --data-raw (单引号)
-	dataRawPattern1 := regexp.MustCompile(`--data-raw\s+'([^']*(?:\\'[^']*)*)'`)
-	dataRawMatches1 := dataRawPattern1.FindStringSubmatch(curlCmd)
-	if len(dataRawMatches1) >= 2 {
-		cmd.Data = dataRawMatches1[1]
-		// 处理转义的单引号
-		cmd.Data = strings.ReplaceAll(cmd.Data, "\\'", "'")
+		fmt.Printf("[CORS-PROXY] [%s] 转发响应体中断: %v\n", requestID, err)
 	}
-
-	// --data-raw (双引号)
-	if cmd.Data == "" {
-		dataRawPattern2 := regexp.MustCompile(`--data-raw\s+"([^"]*(?:\\"[^"]*)*)"`)
-		dataRawMatches2 := dataRawPattern2.FindStringSubmatch(curlCmd)
-		if len(dataRawMatches2) >= 2 {
-			cmd.Data = dataRawMatches2[1]
-			// 处理转义的双引号
-			cmd.Data = strings.ReplaceAll(cmd.Data, "\\\"", "\"")
-		}
-	}
-
-	// 2. --data 或 -d (单引号)
-	if cmd.Data == "" {
-		dataPattern1 := regexp.MustCompile(`(?:--data|-d)\s+'([^']*(?:\\'[^']*)*)'`)
-		dataMatches1 := dataPattern1.FindStringSubmatch(curlCmd)
-		if len(dataMatches1) >= 2 {
-			cmd.Data = dataMatches1[1]
-			cmd.Data = strings.ReplaceAll(cmd.Data, "\\'", "'")
-		}
-	}
-
-	// --data 或 -d (双引号)
-	if cmd.Data == "" {
-		dataPattern2 := regexp.MustCompile(`(?:--data|-d)\s+"([^"]*(?:\\"[^"]*)*)"`)
-		dataMatches2 := dataPattern2.FindStringSubmatch(curlCmd)
-		if len(dataMatches2) >= 2 {
-			cmd.Data = dataMatches2[1]
-			cmd.Data = strings.ReplaceAll(cmd.Data, "\\\"", "\"")
-		}
-	}
-
-	// 3. --json (单引号)
-	if cmd.Data == "" {
-		jsonPattern1 := regexp.MustCompile(`--json\s+'([^']*(?:\\'[^']*)*)'`)
-		jsonMatches1 := jsonPattern1.FindStringSubmatch(curlCmd)
-		if len(jsonMatches1) >= 2 {
-			cmd.Data = jsonMatches1[1]
-			cmd.Data = strings.ReplaceAll(cmd.Data, "\\'", "'")
-			cmd.Headers["Content-Type"] = "application/json"
-		}
-	}
-
-	// --json (双引号)
-	if cmd.Data == "" {
-		jsonPattern2 := regexp.MustCompile(`--json\s+"([^"]*(?:\\"[^"]*)*)"`)
-		jsonMatches2 := jsonPattern2.FindStringSubmatch(curlCmd)
-		if len(jsonMatches2) >= 2 {
-			cmd.Data = jsonMatches2[1]
-			cmd.Data = strings.ReplaceAll(cmd.Data, "\\\"", "\"")
-			cmd.Headers["Content-Type"] = "application/json"
-		}
-	}
-
-	// 如果有数据，设置适当的Content-Type和Method
-	if cmd.Data != "" {
-		// 如果没有明确指定Content-Type，根据数据格式推断
-		if _, exists := cmd.Headers["Content-Type"]; !exists {
-			if strings.HasPrefix(cmd.Data, "{") && strings.HasSuffix(cmd.Data, "}") {
-				cmd.Headers["Content-Type"] = "application/json"
-			} else {
-				cmd.Headers["Content-Type"] = "application/x-www-form-urlencoded"
-			}
-		}
-		// 如果是POST请求但没有指定方法，则设置为POST
-		if cmd.Method == "GET" {
-			cmd.Method = "POST"
-		}
-	}
-
-	// 检查是否有--insecure选项
-	cmd.Insecure = strings.Contains(curlCmd, "--insecure") || strings.Contains(curlCmd, "-k")
-
-	// 检查是否有超时选项
-	timeoutPattern := regexp.MustCompile(`--connect-timeout\s+(\d+)`)
-	timeoutMatches := timeoutPattern.FindStringSubmatch(curlCmd)
-	if len(timeoutMatches) >= 2 {
-		if timeout, err := strconv.Atoi(timeoutMatches[1]); err == nil {
-			cmd.Timeout = timeout
-		}
-	}
-
-	// 检查是否有跟随重定向选项
-	cmd.FollowRedirects = strings.Contains(curlCmd, "--location") || strings.Contains(curlCmd, "-L")
-
-	// 检查是否有用户代理选项
-	userAgentPattern := regexp.MustCompile(`-A\s+['"]([^'"]+)['"]`)
-	userAgentMatches := userAgentPattern.FindStringSubmatch(curlCmd)
-	if len(userAgentMatches) >= 2 {
-		cmd.Headers["User-Agent"] = userAgentMatches[1]
-	}
-
-	// 检查是否有基本认证选项
-	authPattern := regexp.MustCompile(`-u\s+['"]?([^'"]+)['"]?`)
-	authMatches := authPattern.FindStringSubmatch(curlCmd)
-	if len(authMatches) >= 2 {
-		auth := authMatches[1]
-		cmd.Auth = auth
-		// 如果包含:，则是用户名:密码格式
-		if strings.Contains(auth, ":") {
-			parts := strings.SplitN(auth, ":", 2)
-			if len(parts) == 2 {
-				// 将用户名:密码编码为Base64
-				authHeader := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
-				cmd.Headers["Authorization"] = authHeader
-			}
-		}
-	}
-
-	return cmd, nil
+	return resp.StatusCode, nil
 }
 
-// headerToMap 将HTTP头转换为map
-func headerToMap(header http.Header) map[string]string {
-	result := make(map[string]string)
-	for key, values := range header {
-		if len(values) > 0 {
-			result[key] = strings.Join(values, ", ")
-		}
-	}
-	return result
+// registerAuthenticatedRoutes 在/cors-proxy下注册按用户保存数据和批量发出请求的接口，
+// 先经过auth校验，没有登录用户时返回401
+func registerAuthenticatedRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	g := r.Group("/cors-proxy", auth, requireWorkspaceUser)
+	registerWorkspaceRoutes(g)
+	registerRunnerRoutes(g)
 }
 
-// RegisterCorsProxyRoutes 注册CORS代理路由到Gin引擎
-func RegisterCorsProxyRoutes(r *gin.Engine) {
-	r.POST("/cors-proxy", HandleCurlProxy)
+// RegisterCorsProxyRoutes 注册CORS代理路由到Gin引擎。透传代理对所有来源开放CORS，
+// auth是全部代理接口（包括请求历史、集合、环境和运行集合接口）的登录和权限校验中间件，透传代理中挂在CORS中间件之后，
+// 不依赖protectedRoutes配置
+func RegisterCorsProxyRoutes(r *gin.Engine, cfg config.ProxyConfig, auth gin.HandlerFunc) {
+	maxBodySize = int64(cfg.MaxBodyMB) << 20
+	r.POST("/cors-proxy", auth, HandleCurlProxy)
+	registerAuthenticatedRoutes(r, auth)
+	r.Group(passthroughPrefix, CorsProxyMiddleware(), auth).Any("/*url", HandleProxyPassthrough)
 }
@@ -0,0 +1,370 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
+	"github.com/lf-web-tools/gin-web-server/workspace"
+)
+
+// 运行结果中单个请求的状态
+const (
+	runPassed  = "passed"
+	runFailed  = "failed"  // 断言未通过或变量提取失败
+	runError   = "error"   // 请求未能完成，如连接失败、命令无效、目标地址被禁止
+	runSkipped = "skipped" // 指定了stopOnFailure，前面的请求未通过
+)
+
+// RunRequest 运行集合的参数
+type RunRequest struct {
+	Collection       string                     `json:"collection"` // 集合的ID或名称，/collections/:id/run 时忽略
+	Requests         []workspace.CollectionItem `json:"requests"`   // 不使用保存的集合时直接提交的请求
+	Environment      string                     `json:"environment"`
+	Variables        map[string]string          `json:"variables"` // 覆盖环境中的同名变量，如CI中的密钥
+	StopOnFailure    bool                       `json:"stopOnFailure"`
+	IncludeResponses bool                       `json:"includeResponses"` // 在结果中附带每个请求的完整响应
+}
+
+// RunReport 运行集合的报告
+type RunReport struct {
+	Collection  string      `json:"collection"`
+	Environment string      `json:"environment,omitempty"`
+	Passed      bool        `json:"passed"`
+	Total       int         `json:"total"`
+	PassedCount int         `json:"passedCount"`
+	Failed      int         `json:"failed"`
+	Errors      int         `json:"errors"`
+	Skipped     int         `json:"skipped"`
+	DurationMs  int64       `json:"durationMs"`
+	StartedAt   time.Time   `json:"startedAt"`
+	Results     []RunResult `json:"results"`
+}
+
+// RunResult 运行集合时一个请求的结果
+type RunResult struct {
+	Name       string                      `json:"name"`
+	Target     string                      `json:"target,omitempty"` // 请求方法和去掉查询参数的地址
+	Status     string                      `json:"status"`           // passed、failed、error或skipped
+	StatusCode int                         `json:"statusCode,omitempty"`
+	DurationMs int64                       `json:"durationMs"`
+	Error      string                      `json:"error,omitempty"`
+	Warnings   []string                    `json:"warnings,omitempty"`
+	Assertions []workspace.AssertionResult `json:"assertions"`
+	Extracted  []string                    `json:"extracted,omitempty"` // 提取成功的变量名，变量值可能是令牌，不在报告中返回
+	Response   *CurlResponse               `json:"response,omitempty"`
+}
+
+// runCollection 按顺序执行请求，检查断言，并把提取的变量用于后面的请求
+func runCollection(c *gin.Context, name string, items []workspace.CollectionItem, env *workspace.Environment, req RunRequest) *RunReport {
+	vars := make(map[string]string)
+	if env != nil {
+		for key, value := range env.Variables {
+			vars[key] = value
+		}
+	}
+	for key, value := range req.Variables {
+		vars[key] = value
+	}
+
+	report := &RunReport{Collection: name, Environment: req.Environment, StartedAt: time.Now(), Results: make([]RunResult, 0, len(items))}
+	stopped := false
+	for _, item := range items {
+		result := RunResult{Name: item.Name, Assertions: []workspace.AssertionResult{}}
+		if stopped {
+			result.Status = runSkipped
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		// 提取的变量来自上游响应，Expand只在拆分后的参数内替换，值中的引号和空格不会变成新的选项
+		curlCmd, undefined, err := curlcmd.Expand(item.CurlParam, vars)
+		if err != nil {
+			result.Status, result.Error = runError, "解析命令失败: "+err.Error()
+			report.Errors++
+			stopped = req.StopOnFailure
+			report.Results = append(report.Results, result)
+			continue
+		}
+		var warnings []string
+		for _, v := range undefined {
+			warnings = append(warnings, fmt.Sprintf("未定义变量{{%s}}，已保持原样", v))
+		}
+		startTime := time.Now()
+		response := execCurl(c, curlCmd, warnings)
+		elapsed := time.Since(startTime)
+
+		result.Target = proxyAuditTarget(curlCmd)
+		result.StatusCode = response.StatusCode
+		result.DurationMs = elapsed.Milliseconds()
+		result.Warnings = response.Warnings
+		if req.IncludeResponses {
+			result.Response = response
+		}
+
+		if response.Error != "" {
+			result.Status, result.Error = runError, response.Error
+		} else {
+			exchange := &workspace.Exchange{
+				StatusCode: response.StatusCode,
+				Headers:    response.ResponseHeaders,
+				Body:       responseBytes(response),
+				Latency:    elapsed,
+			}
+			result.Status = runPassed
+			for _, assertion := range item.Assertions {
+				checked := assertion.Check(exchange)
+				if !checked.Passed {
+					result.Status = runFailed
+				}
+				result.Assertions = append(result.Assertions, checked)
+			}
+			for _, extraction := range item.Extract {
+				value, err := extraction.Extract(exchange)
+				if err != nil {
+					result.Status = runFailed
+					result.Assertions = append(result.Assertions, workspace.AssertionResult{
+						Type:    "extract",
+						Target:  extraction.Variable,
+						Message: fmt.Sprintf("提取变量%s失败: %v", extraction.Variable, err),
+					})
+					continue
+				}
+				vars[extraction.Variable] = value
+				result.Extracted = append(result.Extracted, extraction.Variable)
+			}
+		}
+
+		switch result.Status {
+		case runPassed:
+			report.PassedCount++
+		case runFailed:
+			report.Failed++
+		case runError:
+			report.Errors++
+		}
+		if result.Status != runPassed && req.StopOnFailure {
+			stopped = true
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	report.Total = len(items)
+	report.Skipped = report.Total - report.PassedCount - report.Failed - report.Errors
+	report.Passed = report.PassedCount == report.Total
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	return report
+}
+
+// responseBytes 断言和提取变量使用的响应体，base64编码的二进制响应体先解码
+func responseBytes(response *CurlResponse) []byte {
+	if response.BodyEncoding == "base64" {
+		if data, err := base64.StdEncoding.DecodeString(response.ResponseBody); err == nil {
+			return data
+		}
+	}
+	return []byte(response.ResponseBody)
+}
+
+// junitTestSuites 等JUnit XML报告的结构，字段按Jenkins、GitLab等CI识别的格式定义
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// JUnitXML 把报告转换为JUnit XML，集合对应一个testsuite，每个请求对应一个testcase
+func (r *RunReport) JUnitXML() ([]byte, error) {
+	seconds := func(ms int64) string { return fmt.Sprintf("%.3f", float64(ms)/1000) }
+	suiteName := r.Collection
+	if r.Environment != "" {
+		suiteName += " (" + r.Environment + ")"
+	}
+	suite := junitTestSuite{
+		Name:      suiteName,
+		Tests:     r.Total,
+		Failures:  r.Failed,
+		Errors:    r.Errors,
+		Skipped:   r.Skipped,
+		Time:      seconds(r.DurationMs),
+		Timestamp: r.StartedAt.Format("2006-01-02T15:04:05"),
+	}
+	for _, result := range r.Results {
+		tc := junitTestCase{Name: result.Name, ClassName: suiteName, Time: seconds(result.DurationMs)}
+		var lines []string
+		if result.Target != "" {
+			lines = append(lines, fmt.Sprintf("%s -> HTTP %d", result.Target, result.StatusCode))
+		}
+		var failed []string
+		for _, a := range result.Assertions {
+			mark := "PASS"
+			if !a.Passed {
+				mark = "FAIL"
+				failed = append(failed, a.Message)
+			}
+			lines = append(lines, fmt.Sprintf("[%s] %s: %s", mark, a.Type, a.Message))
+		}
+		lines = append(lines, result.Warnings...)
+		tc.SystemOut = strings.Join(lines, "\n")
+
+		switch result.Status {
+		case runFailed:
+			tc.Failure = &junitMessage{Message: strings.Join(failed, "; "), Type: "AssertionError"}
+		case runError:
+			tc.Error = &junitMessage{Message: result.Error, Type: "RequestError"}
+		case runSkipped:
+			tc.Skipped = &junitMessage{Message: "前面的请求未通过，已跳过"}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{
+		Name:     suiteName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// writeRunReport 按format返回JSON或JUnit XML报告，有请求未通过时状态码为422，便于CI直接判断结果
+func writeRunReport(c *gin.Context, report *RunReport, format string) {
+	status := http.StatusOK
+	if !report.Passed {
+		status = http.StatusUnprocessableEntity
+	}
+	if format == "junit" {
+		data, err := report.JUnitXML()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成报告失败"})
+			return
+		}
+		c.Data(status, "application/xml; charset=utf-8", data)
+		return
+	}
+	c.JSON(status, gin.H{"success": true, "report": report})
+}
+
+// registerRunnerRoutes 注册运行集合的接口，CI中可以使用带proxy权限的API密钥调用
+func registerRunnerRoutes(g *gin.RouterGroup) {
+	run := func(c *gin.Context, col *workspace.Collection, req RunRequest) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "junit" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format须为json或junit"})
+			return
+		}
+		for key := range req.Variables {
+			if !curlcmd.ValidVariableName(key) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "变量名无效: " + key})
+				return
+			}
+		}
+		env, err := findEnvironment(c, req.Environment)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		fmt.Printf("[CORS-PROXY] 运行集合 %s，共%d个请求，环境: %s\n", col.Name, len(col.Requests), req.Environment)
+		report := runCollection(c, col.Name, col.Requests, env, req)
+		fmt.Printf("[CORS-PROXY] 集合 %s 运行完成: 通过%d，失败%d，错误%d，跳过%d\n",
+			col.Name, report.PassedCount, report.Failed, report.Errors, report.Skipped)
+		writeRunReport(c, report, format)
+	}
+
+	bindRunRequest := func(c *gin.Context) (RunRequest, bool) {
+		var req RunRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+				return req, false
+			}
+		}
+		return req, true
+	}
+
+	// 运行保存的集合（collection为ID或名称），或直接运行requests中提交的请求
+	g.POST("/run", func(c *gin.Context) {
+		req, ok := bindRunRequest(c)
+		if !ok {
+			return
+		}
+		var col *workspace.Collection
+		switch {
+		case len(req.Requests) > 0:
+			if err := workspace.NormalizeRequests(req.Requests); err != nil {
+				workspaceError(c, err, "运行集合")
+				return
+			}
+			col = &workspace.Collection{Name: strings.TrimSpace(req.Collection), Requests: req.Requests}
+			if col.Name == "" {
+				col.Name = "curl"
+			}
+		case req.Collection != "":
+			var err error
+			if col, err = workspace.FindCollection(currentUser(c), req.Collection); err != nil {
+				workspaceError(c, err, "读取集合")
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "需要collection或requests"})
+			return
+		}
+		run(c, col, req)
+	})
+
+	g.POST("/collections/:id/run", func(c *gin.Context) {
+		req, ok := bindRunRequest(c)
+		if !ok {
+			return
+		}
+		col, err := workspace.GetCollection(currentUser(c), c.Param("id"))
+		if err != nil {
+			workspaceError(c, err, "读取集合")
+			return
+		}
+		run(c, col, req)
+	})
+}
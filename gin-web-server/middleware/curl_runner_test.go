@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
+	"github.com/lf-web-tools/gin-web-server/workspace"
+)
+
+// allowPrivateDestinations 测试服务器监听在回环地址，测试期间允许访问内网地址
+func allowPrivateDestinations(t *testing.T) {
+	policy, err := netpolicy.New(config.DestinationConfig{DestinationRule: config.DestinationRule{AllowPrivate: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := destinationPolicy
+	SetDestinationPolicy(policy)
+	t.Cleanup(func() { SetDestinationPolicy(old) })
+}
+
+func testContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/cors-proxy/run", nil)
+	return c
+}
+
+// TestRunRoutesRequireUser 运行集合的接口与集合、环境接口挂在同一个需要登录的路由组下，匿名请求不会发出任何请求
+func TestRunRoutesRequireUser(t *testing.T) {
+	allowPrivateDestinations(t)
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerAuthenticatedRoutes(r, noAuth)
+	body := `{"requests":[{"name":"a","curlParam":"curl ` + upstream.URL + `"}]}`
+	for _, path := range []string{"/cors-proxy/run", "/cors-proxy/collections/c1/run"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("POST %s status = %d, want 401", path, w.Code)
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 0 {
+		t.Errorf("upstream received %d requests", n)
+	}
+}
+
+func TestRunCollectionExtractedValueCannotInjectOptions(t *testing.T) {
+	allowPrivateDestinations(t)
+
+	var attackerHits int32
+	attacker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attackerHits, 1)
+	}))
+	defer attacker.Close()
+
+	token := "x' -x " + attacker.URL + ` 'y "z"`
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/login":
+			json.NewEncoder(w).Encode(map[string]string{"token": token})
+		case "/me":
+			gotAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	defer upstream.Close()
+
+	items := []workspace.CollectionItem{
+		{
+			Name:      "login",
+			CurlParam: "curl {{base}}/login",
+			Extract:   []workspace.Extraction{{Variable: "token", Type: workspace.CheckJSONPath, Path: "$.token"}},
+		},
+		{
+			Name:       "me",
+			CurlParam:  `curl -H "Authorization: Bearer {{token}}" {{base}}/me`,
+			Assertions: []workspace.Assertion{{Type: workspace.CheckStatus, Value: json.RawMessage("200")}},
+		},
+	}
+	if err := workspace.NormalizeRequests(items); err != nil {
+		t.Fatal(err)
+	}
+	report := runCollection(testContext(), "smoke", items, nil, RunRequest{Variables: map[string]string{"base": upstream.URL}})
+
+	if !report.Passed {
+		t.Fatalf("report not passed: %+v", report.Results)
+	}
+	if n := atomic.LoadInt32(&attackerHits); n != 0 {
+		t.Errorf("extracted value redirected %d requests to another proxy", n)
+	}
+	if gotAuth != "Bearer "+token {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer "+token)
+	}
+}
+
+func TestRunCollectionReport(t *testing.T) {
+	allowPrivateDestinations(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"id":7}}`))
+	}))
+	defer upstream.Close()
+
+	items := []workspace.CollectionItem{
+		{CurlParam: "curl " + upstream.URL, Assertions: []workspace.Assertion{
+			{Type: workspace.CheckJSONPath, Path: "$.data.id", Value: json.RawMessage("7")},
+			{Type: workspace.CheckHeader, Name: "content-type", Value: json.RawMessage(`"application/json"`)},
+		}},
+		{CurlParam: "curl " + upstream.URL, Assertions: []workspace.Assertion{
+			{Type: workspace.CheckStatus, Value: json.RawMessage(`"4xx"`)},
+		}},
+		{CurlParam: "curl " + upstream.URL},
+	}
+	if err := workspace.NormalizeRequests(items); err != nil {
+		t.Fatal(err)
+	}
+	report := runCollection(testContext(), "smoke", items, nil, RunRequest{StopOnFailure: true})
+
+	if report.Passed || report.PassedCount != 1 || report.Failed != 1 || report.Skipped != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	data, err := report.JUnitXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`tests="3"`, `failures="1"`, `skipped="1"`, `<failure message="期望状态码4xx，实际为200"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JUnit XML missing %s:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/workspace"
+)
+
+const (
+	historyDefaultPageSize = 20
+	historyMaxPageSize     = 200
+)
+
+// CollectionResult 重放集合时一个请求的执行结果
+type CollectionResult struct {
+	Name      string        `json:"name"`
+	CurlParam string        `json:"curlParam"`
+	Response  *CurlResponse `json:"response"`
+}
+
+// findEnvironment 按名称读取当前用户的环境，没有指定环境时返回nil
+func findEnvironment(c *gin.Context, environment string) (*workspace.Environment, error) {
+	if environment == "" {
+		return nil, nil
+	}
+	env, err := workspace.FindEnvironment(currentUser(c), environment)
+	if err != nil {
+		if errors.Is(err, workspace.ErrNotFound) {
+			return nil, fmt.Errorf("环境不存在: %s", environment)
+		}
+		return nil, fmt.Errorf("读取环境失败: %v", err)
+	}
+	return env, nil
+}
+
+// expandCommand 指定了环境时把命令中的{{name}}替换为该环境的变量，未定义的变量作为警告返回
+func expandCommand(c *gin.Context, curlParam, environment string) (string, []string, error) {
+	env, err := findEnvironment(c, environment)
+	if err != nil || env == nil {
+		return curlParam, nil, err
+	}
+
+	expanded, undefined, err := curlcmd.Expand(curlParam, env.Variables)
+	if err != nil {
+		return "", nil, fmt.Errorf("解析命令失败: %v", err)
+	}
+	var warnings []string
+	for _, name := range undefined {
+		warnings = append(warnings, fmt.Sprintf("环境%s中未定义变量{{%s}}，已保持原样", env.Name, name))
+	}
+	return expanded, warnings, nil
+}
+
+// runCurl 替换环境变量后执行命令，用于重放历史记录和集合；命令中不能引用文件
+func runCurl(c *gin.Context, curlParam, environment string) (*CurlResponse, string, error) {
+	curlCmd, warnings, err := expandCommand(c, curlParam, environment)
+	if err != nil {
+		return nil, "", err
+	}
+	return execCurl(c, curlCmd, warnings), curlCmd, nil
+}
+
+// execCurl 执行已替换变量的命令并记录审计日志，warnings放在响应的警告之前
+func execCurl(c *gin.Context, curlCmd string, warnings []string) *CurlResponse {
+	var response CurlResponse
+	startTime := time.Now()
+	err := executeCurlAsHTTP(curlCmd, nil, destinationRule(c), &response)
+	response.ExecutionTime = time.Since(startTime).String()
+	response.Warnings = append(warnings, response.Warnings...)
+	if err != nil {
+		response.Error = err.Error()
+		audit.Log(c, auditProxyRequest, proxyAuditTarget(curlCmd), failureResult(err), err.Error())
+	} else {
+		audit.Log(c, auditProxyRequest, proxyAuditTarget(curlCmd), audit.ResultSuccess, fmt.Sprintf("HTTP %d", response.StatusCode))
+	}
+	return &response
+}
+
+// recordHistory 把执行过的请求和响应保存到当前用户的历史记录，记录的ID写入response.HistoryID
+func recordHistory(c *gin.Context, curlParam, environment, curlCmd string, response *CurlResponse) {
+	username := currentUser(c)
+	if username == "" {
+		return
+	}
+	data, err := json.Marshal(historyResponse(*response))
+	if err != nil {
+		fmt.Printf("[CORS-PROXY] 保存历史记录失败: %v\n", err)
+		return
+	}
+	entry := &workspace.HistoryEntry{
+		Username:    username,
+		CurlParam:   curlParam,
+		Environment: environment,
+		Target:      proxyAuditTarget(curlCmd),
+		StatusCode:  response.StatusCode,
+		Error:       response.Error,
+		Response:    data,
+	}
+	if err := workspace.AddHistory(entry); err != nil {
+		fmt.Printf("[CORS-PROXY] 保存历史记录失败: %v\n", err)
+		return
+	}
+	response.HistoryID = entry.ID
+}
+
+// historyResponse 保存到历史记录的响应：最终地址和重定向地址隐藏查询参数，响应头隐藏Cookie等凭据，
+// 响应体超过proxy.historyBodyKB时截断
+func historyResponse(response CurlResponse) CurlResponse {
+	response.Result = response.Result.Redacted()
+	limit := workspace.HistoryBodyLimit()
+	if len(response.ResponseBody) <= limit {
+		return response
+	}
+	body := response.ResponseBody[:limit]
+	if response.BodyEncoding == "base64" {
+		body = body[:len(body)/4*4]
+	} else {
+		for len(body) > 0 && !utf8.ValidString(body) {
+			body = body[:len(body)-1]
+		}
+	}
+	response.ResponseBody = body
+	response.Truncated = true
+	return response
+}
+
+// workspaceError 把workspace包返回的错误转换为响应
+func workspaceError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, workspace.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, workspace.ErrNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, workspace.ErrInvalid), errors.Is(err, workspace.ErrLimit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("[CORS-PROXY] %s失败: %v\n", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": action + "失败"})
+	}
+}
+
+// requireWorkspaceUser 没有登录用户时返回401，避免匿名请求共用同一份历史记录、集合和环境
+func requireWorkspaceUser(c *gin.Context) {
+	if currentUser(c) == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "请先登录"})
+		return
+	}
+	c.Next()
+}
+
+// registerWorkspaceRoutes 注册当前用户的请求历史、集合和环境接口，g须挂有登录和权限校验中间件
+func registerWorkspaceRoutes(g *gin.RouterGroup) {
+
+	g.GET("/history", func(c *gin.Context) {
+		filter := workspace.HistoryFilter{Query: strings.TrimSpace(c.Query("q"))}
+		if value := c.Query("pinned"); value != "" {
+			pinned, err := strconv.ParseBool(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "pinned须为true或false"})
+				return
+			}
+			filter.Pinned = &pinned
+		}
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page无效"})
+			return
+		}
+		pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(historyDefaultPageSize)))
+		if err != nil || pageSize < 1 || pageSize > historyMaxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pageSize须在1到" + strconv.Itoa(historyMaxPageSize) + "之间"})
+			return
+		}
+		// 页码过大时(page-1)*pageSize会溢出为负数
+		if page > math.MaxInt/pageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page无效"})
+			return
+		}
+		filter.Offset = (page - 1) * pageSize
+		filter.Limit = pageSize
+
+		entries, total, err := workspace.ListHistory(currentUser(c), filter)
+		if err != nil {
+			workspaceError(c, err, "读取历史记录")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"history":  entries,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+		})
+	})
+
+	g.GET("/history/:id", func(c *gin.Context) {
+		entry, err := workspace.GetHistory(currentUser(c), c.Param("id"))
+		if err != nil {
+			workspaceError(c, err, "读取历史记录")
+			return
+		}
+		entry.Username = ""
+		c.JSON(http.StatusOK, gin.H{"success": true, "entry": entry})
+	})
+
+	g.PATCH("/history/:id", func(c *gin.Context) {
+		var req struct {
+			Pinned *bool `json:"pinned"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Pinned == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效，需要pinned"})
+			return
+		}
+		entry, err := workspace.PinHistory(currentUser(c), c.Param("id"), *req.Pinned)
+		if err != nil {
+			workspaceError(c, err, "更新历史记录")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "entry": entry.Summary()})
+	})
+
+	g.DELETE("/history/:id", func(c *gin.Context) {
+		if err := workspace.DeleteHistory(currentUser(c), c.Param("id")); err != nil {
+			workspaceError(c, err, "删除历史记录")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	// 清空历史记录，默认保留置顶的记录，?all=true时一并删除
+	g.DELETE("/history", func(c *gin.Context) {
+		deleted, err := workspace.ClearHistory(currentUser(c), c.Query("all") == "true")
+		if err != nil {
+			workspaceError(c, err, "清空历史记录")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "deleted": deleted})
+	})
+
+	// 重新执行历史记录中的命令，可以指定另一个环境，结果记为新的历史记录
+	g.POST("/history/:id/replay", func(c *gin.Context) {
+		entry, err := workspace.GetHistory(currentUser(c), c.Param("id"))
+		if err != nil {
+			workspaceError(c, err, "读取历史记录")
+			return
+		}
+		var req struct {
+			Environment *string `json:"environment"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+				return
+			}
+		}
+		environment := entry.Environment
+		if req.Environment != nil {
+			environment = *req.Environment
+		}
+
+		response, curlCmd, err := runCurl(c, entry.CurlParam, environment)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		recordHistory(c, entry.CurlParam, environment, curlCmd, response)
+		c.JSON(http.StatusOK, response)
+	})
+
+	g.GET("/collections", func(c *gin.Context) {
+		collections, err := workspace.ListCollections(currentUser(c))
+		if err != nil {
+			workspaceError(c, err, "读取集合")
+			return
+		}
+		items := make([]gin.H, 0, len(collections))
+		for _, col := range collections {
+			items = append(items, gin.H{
+				"id":           col.ID,
+				"name":         col.Name,
+				"description":  col.Description,
+				"requestCount": len(col.Requests),
+				"updatedAt":    col.UpdatedAt.Format(time.RFC3339),
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "collections": items})
+	})
+
+	g.GET("/collections/:id", func(c *gin.Context) {
+		col, err := workspace.GetCollection(currentUser(c), c.Param("id"))
+		if err != nil {
+			workspaceError(c, err, "读取集合")
+			return
+		}
+		col.Username = ""
+		c.JSON(http.StatusOK, gin.H{"success": true, "collection": col})
+	})
+
+	saveCollection := func(c *gin.Context, id string) {
+		var col workspace.Collection
+		if err := c.ShouldBindJSON(&col); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+		col.ID = id
+		if err := workspace.SaveCollection(currentUser(c), &col); err != nil {
+			workspaceError(c, err, "保存集合")
+			return
+		}
+		col.Username = ""
+		c.JSON(http.StatusOK, gin.H{"success": true, "collection": col})
+	}
+	g.POST("/collections", func(c *gin.Context) { saveCollection(c, "") })
+	g.PUT("/collections/:id", func(c *gin.Context) { saveCollection(c, c.Param("id")) })
+
+	g.DELETE("/collections/:id", func(c *gin.Context) {
+		if err := workspace.DeleteCollection(currentUser(c), c.Param("id")); err != nil {
+			workspaceError(c, err, "删除集合")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	// 按顺序执行集合中的请求，指定环境即可把同一组请求重放到开发、测试、生产等不同环境
+	g.POST("/collections/:id/replay", func(c *gin.Context) {
+		col, err := workspace.GetCollection(currentUser(c), c.Param("id"))
+		if err != nil {
+			workspaceError(c, err, "读取集合")
+			return
+		}
+		var req struct {
+			Environment string `json:"environment"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+				return
+			}
+		}
+		if req.Environment != "" {
+			if _, err := workspace.FindEnvironment(currentUser(c), req.Environment); err != nil {
+				workspaceError(c, err, "读取环境")
+				return
+			}
+		}
+
+		fmt.Printf("[CORS-PROXY] 重放集合 %s，共%d个请求，环境: %s\n", col.Name, len(col.Requests), req.Environment)
+		results := make([]CollectionResult, 0, len(col.Requests))
+		for _, item := range col.Requests {
+			response, _, err := runCurl(c, item.CurlParam, req.Environment)
+			if err != nil {
+				response = &CurlResponse{Error: err.Error()}
+			}
+			results = append(results, CollectionResult{Name: item.Name, CurlParam: item.CurlParam, Response: response})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":     true,
+			"collection":  col.Name,
+			"environment": req.Environment,
+			"results":     results,
+		})
+	})
+
+	g.GET("/environments", func(c *gin.Context) {
+		envs, err := workspace.ListEnvironments(currentUser(c))
+		if err != nil {
+			workspaceError(c, err, "读取环境")
+			return
+		}
+		for i := range envs {
+			envs[i].Username = ""
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "environments": envs})
+	})
+
+	saveEnvironment := func(c *gin.Context, id string) {
+		var env workspace.Environment
+		if err := c.ShouldBindJSON(&env); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+		env.ID = id
+		if err := workspace.SaveEnvironment(currentUser(c), &env); err != nil {
+			workspaceError(c, err, "保存环境")
+			return
+		}
+		env.Username = ""
+		c.JSON(http.StatusOK, gin.H{"success": true, "environment": env})
+	}
+	g.POST("/environments", func(c *gin.Context) { saveEnvironment(c, "") })
+	g.PUT("/environments/:id", func(c *gin.Context) { saveEnvironment(c, c.Param("id")) })
+
+	g.DELETE("/environments/:id", func(c *gin.Context) {
+		if err := workspace.DeleteEnvironment(currentUser(c), c.Param("id")); err != nil {
+			workspaceError(c, err, "删除环境")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/workspace"
+)
+
+// noAuth 测试中代替登录和权限校验中间件
+func noAuth(c *gin.Context) { c.Next() }
+
+func TestHistoryListPageBounds(t *testing.T) {
+	oldUser := currentUser
+	currentUser = func(*gin.Context) string { return "history-pages" }
+	t.Cleanup(func() { currentUser = oldUser })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerAuthenticatedRoutes(r, noAuth)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"page=1&pageSize=20", http.StatusOK},
+		{"page=100000", http.StatusOK},
+		{"page=0", http.StatusBadRequest},
+		{"page=" + strconv.Itoa(math.MaxInt) + "&pageSize=100", http.StatusBadRequest},
+		{"page=" + strconv.Itoa(math.MaxInt/2) + "&pageSize=3", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cors-proxy/history?"+tt.query, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
+
+// TestWorkspaceRequiresUser 认证中间件拒绝或没有登录用户时，匿名请求不能读写共用的空用户数据
+func TestWorkspaceRequiresUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deny := func(c *gin.Context) { c.AbortWithStatus(http.StatusForbidden) }
+
+	tests := []struct {
+		name string
+		auth gin.HandlerFunc
+		want int
+	}{
+		{"auth middleware applies", deny, http.StatusForbidden},
+		{"anonymous user", noAuth, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		registerAuthenticatedRoutes(r, tt.auth)
+		for _, path := range []string{"/cors-proxy/history", "/cors-proxy/collections", "/cors-proxy/environments"} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != tt.want {
+				t.Errorf("%s: GET %s status = %d, want %d", tt.name, path, w.Code, tt.want)
+			}
+		}
+	}
+}
+
+// TestHistoryRedactsCredentials 环境变量替换后的令牌不随最终地址、重定向和响应头保存到历史记录
+func TestHistoryRedactsCredentials(t *testing.T) {
+	allowPrivateDestinations(t)
+	oldUser := currentUser
+	currentUser = func(*gin.Context) string { return "history-redact" }
+	t.Cleanup(func() { currentUser = oldUser })
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.SetCookie(w, &http.Cookie{Name: "step", Value: "secret-step"})
+			http.Redirect(w, r, "/final?code=secret-code", http.StatusFound)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	c := testContext()
+	curlParam := "curl -L -H 'Authorization: Bearer {{token}}' '" + upstream.URL + "/start?token={{token}}'"
+	curlCmd := strings.ReplaceAll(curlParam, "{{token}}", "secret-token")
+	var response CurlResponse
+	if err := executeCurlAsHTTP(curlCmd, nil, destinationRule(c), &response); err != nil {
+		t.Fatal(err)
+	}
+	recordHistory(c, curlParam, "", curlCmd, &response)
+	if response.HistoryID == "" {
+		t.Fatal("history was not recorded")
+	}
+	// 返回给前端的结果保持原样
+	if !strings.Contains(response.EffectiveURL, "secret-code") {
+		t.Errorf("effectiveUrl = %q", response.EffectiveURL)
+	}
+
+	entry, err := workspace.GetHistory("history-redact", response.HistoryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := string(entry.Response); strings.Contains(stored, "secret") {
+		t.Errorf("stored response contains credentials: %s", stored)
+	}
+	if strings.Contains(entry.Target, "secret") {
+		t.Errorf("stored target = %q", entry.Target)
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
+)
+
+// destinationPolicy 代理和端口扫描的目标地址策略，由SetDestinationPolicy按配置设置，默认禁止内网地址
+var destinationPolicy, _ = netpolicy.New(config.DestinationConfig{})
+
+// SetDestinationPolicy 设置目标地址策略，须在注册路由前调用
+func SetDestinationPolicy(policy *netpolicy.Policy) {
+	destinationPolicy = policy
+}
+
+// destinationRule 当前请求使用的目标地址规则，按登录用户的角色选择
+func destinationRule(c *gin.Context) *netpolicy.Rule {
+	return destinationPolicy.ForRole(currentRole(c))
+}
+
+// failureResult 请求失败时审计日志记录的结果，被目标地址策略拒绝的记为denied
+func failureResult(err error) string {
+	if errors.Is(err, netpolicy.ErrDenied) {
+		return audit.ResultDenied
+	}
+	return audit.ResultFailure
+}
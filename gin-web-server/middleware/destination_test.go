@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
+)
+
+// redirectServers 返回一个允许访问的服务器和一个端口不在允许范围内的服务器，前者重定向到后者
+func redirectServers(t *testing.T) (*httptest.Server, *httptest.Server, *netpolicy.Rule) {
+	t.Helper()
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect to a denied port was followed")
+	}))
+	t.Cleanup(blocked.Close)
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, blocked.URL+"/secret", http.StatusFound)
+	}))
+	t.Cleanup(allowed.Close)
+
+	target, _ := url.Parse(allowed.URL)
+	policy, err := netpolicy.New(config.DestinationConfig{DestinationRule: config.DestinationRule{
+		AllowPrivate: true,
+		Ports:        []string{target.Port()},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return allowed, blocked, policy.ForRole("")
+}
+
+func TestPassthroughRechecksRedirects(t *testing.T) {
+	allowed, _, rule := redirectServers(t)
+	client := passthroughClient(rule)
+	if client.Transport.(*http.Transport).Proxy != nil {
+		t.Error("passthrough client uses HTTP_PROXY from the environment")
+	}
+
+	resp, err := client.Get(allowed.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, netpolicy.ErrDenied) {
+		t.Fatalf("error = %v, want ErrDenied", err)
+	}
+}
+
+func TestCurlRechecksRedirects(t *testing.T) {
+	allowed, _, rule := redirectServers(t)
+	err := executeCurlAsHTTP("curl -L "+allowed.URL, nil, rule, &CurlResponse{})
+	if !errors.Is(err, netpolicy.ErrDenied) {
+		t.Fatalf("error = %v, want ErrDenied", err)
+	}
+}
+
+func TestCurlRejectsProxyWithDestinations(t *testing.T) {
+	allowed, _, rule := redirectServers(t)
+	err := executeCurlAsHTTP("curl -x "+allowed.URL+" http://example.com/", nil, rule, &CurlResponse{})
+	if err == nil || !strings.Contains(err.Error(), "--proxy") {
+		t.Fatalf("error = %v, want proxy rejected", err)
+	}
+}
+
+func TestCurlRejectsResolveWithDestinations(t *testing.T) {
+	policy, err := netpolicy.New(config.DestinationConfig{DestinationRule: config.DestinationRule{
+		Allow: []string{"allowed.example.com"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = executeCurlAsHTTP("curl --resolve allowed.example.com:80:169.254.169.254 http://allowed.example.com/latest/meta-data/",
+		nil, policy.ForRole(""), &CurlResponse{})
+	if err == nil || !strings.Contains(err.Error(), "--resolve") {
+		t.Fatalf("error = %v, want --resolve rejected", err)
+	}
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
+)
+
+// passthroughPrefix 透传代理的路由前缀，目标地址紧跟其后，如 /proxy/https://api.example.com/path?a=1
+const passthroughPrefix = "/proxy/"
+
+// passthroughClients 每条目标地址规则一个透传代理客户端。连接池按规则隔离，
+// 避免按宽松规则建立的内网连接被其他角色的请求复用而绕过检查
+var passthroughClients sync.Map // *netpolicy.Rule -> *http.Client
+
+// passthroughClient 返回规则对应的透传代理客户端。不设置总超时，以便转发大文件和流式响应；
+// 不自动解压，上游的Content-Encoding原样交给浏览器处理；重定向地址和实际连接的IP都按规则检查。
+// 不使用HTTP_PROXY等环境变量中的代理，否则实际连接的是代理服务器，目标IP的检查会失效
+func passthroughClient(rule *netpolicy.Rule) *http.Client {
+	if client, ok := passthroughClients.Load(rule); ok {
+		return client.(*http.Client)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, _, _ := net.SplitHostPort(addr)
+				return rule.Dialer(dialer, host).DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 60 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			DisableCompression:    true,
+			ForceAttemptHTTP2:     true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return rule.CheckURL(req.URL)
+		},
+	}
+	actual, _ := passthroughClients.LoadOrStore(rule, client)
+	return actual.(*http.Client)
+}
+
+// skippedRequestHeaders 透传时不转发给上游的请求头：逐跳头、本站的登录凭据和Cookie，以及浏览器的Origin
+var skippedRequestHeaders = map[string]bool{
+	"X-Auth-Token":        true,
+	"X-Api-Key":           true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Cookie":              true,
+	"Origin":              true,
+}
+
+// HandleProxyPassthrough 透传代理：把 /proxy/{url} 收到的请求按原方法、请求头和请求体转发到url，
+// 跟随重定向后原样返回上游的状态码、响应头和响应体。前端只需把请求地址的前缀换成 /proxy/ 即可跨域访问。
+// 访问本站的令牌用X-Auth-Token或X-API-Key传递时，Authorization会转发给上游；用Authorization传递时不转发
+func HandleProxyPassthrough(c *gin.Context) {
+	requestID := fmt.Sprintf("%d", time.Now().UnixNano())
+	target, err := passthroughTarget(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	auditTarget := c.Request.Method + " " + passthroughAuditTarget(target)
+	fmt.Printf("[CORS-PROXY] [%s] 透传请求 - 客户端IP: %s, %s %s\n", requestID, c.ClientIP(), c.Request.Method, curlcmd.RedactURL(target))
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, target, c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("创建HTTP请求失败: %v", err)})
+		return
+	}
+	rule := destinationRule(c)
+	if err := rule.CheckURL(req.URL); err != nil {
+		fmt.Printf("[CORS-PROXY] [%s] 透传请求被拒绝: %v\n", requestID, err)
+		audit.Log(c, auditProxyRequest, auditTarget, audit.ResultDenied, err.Error())
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	req.ContentLength = c.Request.ContentLength
+	for key, values := range c.Request.Header {
+		if !skippedRequestHeaders[key] {
+			req.Header[key] = values
+		}
+	}
+	if c.GetHeader("X-Auth-Token") == "" && c.GetHeader("X-API-Key") == "" {
+		req.Header.Del("Authorization")
+	}
+
+	startTime := time.Now()
+	resp, err := passthroughClient(rule).Do(req)
+	if err != nil {
+		err = curlcmd.RedactError(err)
+		fmt.Printf("[CORS-PROXY] [%s] 透传请求失败: %v, 耗时: %v\n", requestID, err, time.Since(startTime))
+		audit.Log(c, auditProxyRequest, auditTarget, failureResult(err), err.Error())
+		status := http.StatusBadGateway
+		if errors.Is(err, netpolicy.ErrDenied) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": fmt.Sprintf("执行HTTP请求失败: %v", err)})
+		return
+	}
+	defer resp.Body.Close()
+	audit.Log(c, auditProxyRequest, auditTarget, audit.ResultSuccess, fmt.Sprintf("HTTP %d (passthrough)", resp.StatusCode))
+
+	curlcmd.CopyUpstreamHeaders(c.Writer.Header(), resp.Header)
+	c.Status(resp.StatusCode)
+	written, err := copyFlushing(c.Writer, resp.Body)
+	if err != nil {
+		fmt.Printf("[CORS-PROXY] [%s] 转发响应体中断: %v\n", requestID, err)
+	}
+	fmt.Printf("[CORS-PROXY] [%s] 透传完成: 状态码=%d, 响应体大小=%d字节, 耗时=%v\n",
+		requestID, resp.StatusCode, written, time.Since(startTime))
+}
+
+// passthroughAuditTarget 审计日志中记录的透传目标地址，去掉用户信息和查询参数
+func passthroughAuditTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return u.String()
+}
+
+// passthroughTarget 从请求路径中取出目标地址，并带上原请求的查询参数
+func passthroughTarget(r *http.Request) (string, error) {
+	raw := strings.TrimPrefix(r.URL.EscapedPath(), passthroughPrefix)
+	// 部分客户端和反向代理会把路径中的//合并为/
+	for _, scheme := range []string{"http:/", "https:/"} {
+		if strings.HasPrefix(raw, scheme) && !strings.HasPrefix(raw, scheme+"/") {
+			raw = scheme + "/" + raw[len(scheme):]
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("目标地址无效，格式为 %shttps://example.com/path", passthroughPrefix)
+	}
+	u.RawQuery = r.URL.RawQuery
+	return u.String(), nil
+}
+
+// copyFlushing 转发响应体，每次写入后立即刷新，使Server-Sent Events等流式响应能实时到达浏览器
+func copyFlushing(w gin.ResponseWriter, body io.Reader) (int64, error) {
+	var written int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			m, writeErr := w.Write(buf[:n])
+			written += int64(m)
+			if writeErr != nil {
+				return written, writeErr
+			}
+			w.Flush()
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
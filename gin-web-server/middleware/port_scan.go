@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
 )
 
 // 端口状态常量
@@ -49,23 +52,33 @@ type PortScanResponse struct {
 	EndTime      string          `json:"endTime"`
 }
 
-// 检测单个端口
-func checkPort(host string, port int, timeout time.Duration) PortScanResult {
+// 检测单个端口，端口和实际连接的IP须通过目标地址规则检查
+func checkPort(host string, port int, timeout time.Duration, rule *netpolicy.Rule) PortScanResult {
 	result := PortScanResult{
 		Port:   port,
 		Status: PortStatusClosed,
 	}
 
-	address := fmt.Sprintf("%s:%d", host, port)
+	if err := rule.CheckHost(host, port); err != nil {
+		result.Status = PortStatusError
+		result.Error = err.Error()
+		return result
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
 	fmt.Printf("[PORT-SCAN] 正在检测端口: %s\n", address)
 	
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := rule.Dialer(&net.Dialer{Timeout: timeout}, host).Dial("tcp", address)
 
 	if err != nil {
 		errStr := strings.ToLower(err.Error())
 		fmt.Printf("[PORT-SCAN] 端口 %d 连接失败: %v\n", port, err)
 		
-		if strings.Contains(errStr, "timeout") || strings.Contains(errStr, "i/o timeout") {
+		var opErr *net.OpError
+		if errors.Is(err, netpolicy.ErrDenied) && errors.As(err, &opErr) {
+			result.Status = PortStatusError
+			result.Error = opErr.Err.Error()
+		} else if strings.Contains(errStr, "timeout") || strings.Contains(errStr, "i/o timeout") {
 			result.Status = PortStatusTimeout
 			result.Error = "连接超时"
 		} else if strings.Contains(errStr, "refused") || strings.Contains(errStr, "connection refused") {
@@ -175,7 +188,7 @@ func parsePorts(portsStr string) ([]int, error) {
 }
 
 // 批量扫描端口
-func batchScanPorts(host string, ports []int, timeout time.Duration, batchSize int) []PortScanResult {
+func batchScanPorts(host string, ports []int, timeout time.Duration, batchSize int, rule *netpolicy.Rule) []PortScanResult {
 	if batchSize <= 0 {
 		batchSize = 100 // 默认批次大小
 	}
@@ -203,7 +216,7 @@ func batchScanPorts(host string, ports []int, timeout time.Duration, batchSize i
 				batchWg.Add(1)
 				go func(index int, portNum int) {
 					defer batchWg.Done()
-					batchResults[index] = checkPort(host, portNum, timeout)
+					batchResults[index] = checkPort(host, portNum, timeout, rule)
 				}(j, port)
 			}
 			batchWg.Wait()
@@ -219,6 +232,27 @@ func batchScanPorts(host string, ports []int, timeout time.Duration, batchSize i
 	return results
 }
 
+// 检查扫描的主机是否被目标地址规则禁止，域名按解析出的全部地址检查；
+// 解析失败时不在这里报错，由每个端口的检测结果给出
+func checkScanHost(host string, rule *netpolicy.Rule) error {
+	if err := rule.CheckHost(host, 0); err != nil {
+		return err
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if err := rule.CheckDial(host, ip, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // 获取所有端口
 func getAllPorts() []int {
 	ports := make([]int, 65535)
@@ -246,6 +280,17 @@ func HandlePortScan(c *gin.Context) {
 		req.BatchSize = 100 // 默认批次大小
 	}
 
+	// 检查目标主机是否允许扫描
+	rule := destinationRule(c)
+	if err := checkScanHost(req.Host, rule); err != nil {
+		fmt.Printf("[PORT-SCAN] 拒绝扫描主机 %s: %v\n", req.Host, err)
+		audit.Log(c, auditPortScan, req.Host, audit.ResultDenied, err.Error())
+		c.JSON(403, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// 记录开始时间
 	startTime := time.Now()
 	startTimeStr := startTime.Format("2006-01-02 15:04:05")
@@ -263,6 +308,7 @@ func HandlePortScan(c *gin.Context) {
 		fmt.Printf("[PORT-SCAN] [%d] 扫描指定端口: %s\n", startTime.UnixNano(), req.Ports)
 		ports, err = parsePorts(req.Ports)
 		if err != nil {
+			audit.Log(c, auditPortScan, req.Host, audit.ResultFailure, "端口解析错误: "+err.Error())
 			c.JSON(400, gin.H{
 				"error": "端口解析错误: " + err.Error(),
 			})
@@ -282,7 +328,7 @@ func HandlePortScan(c *gin.Context) {
 
 	// 执行端口扫描
 	timeout := time.Duration(req.Timeout) * time.Millisecond
-	results := batchScanPorts(req.Host, ports, timeout, req.BatchSize)
+	results := batchScanPorts(req.Host, ports, timeout, req.BatchSize, rule)
 
 	// 处理结果
 	endTime := time.Now()
@@ -311,6 +357,9 @@ func HandlePortScan(c *gin.Context) {
 	fmt.Printf("[PORT-SCAN] [%d] 开放端口: %d 个, 关闭端口: %d 个, 超时端口: %d 个, 错误端口: %d 个\n", 
 		startTime.UnixNano(), len(openPorts), len(closedPorts), len(timeoutPorts), len(errorPorts))
 
+	audit.Log(c, auditPortScan, req.Host, audit.ResultSuccess,
+		fmt.Sprintf("扫描%d个端口，开放%d个", len(ports), len(openPorts)))
+
 	response := PortScanResponse{
 		Host:         req.Host,
 		TotalScanned: len(ports),
@@ -327,7 +376,7 @@ func HandlePortScan(c *gin.Context) {
 	c.JSON(200, response)
 }
 
-// 注册端口扫描路由
-func RegisterPortScanRoutes(r *gin.Engine) {
-	r.POST("/port-scan", HandlePortScan)
+// 注册端口扫描路由，auth是登录和权限校验中间件，不依赖protectedRoutes配置
+func RegisterPortScanRoutes(r *gin.Engine, auth gin.HandlerFunc) {
+	r.POST("/port-scan", auth, HandlePortScan)
 }
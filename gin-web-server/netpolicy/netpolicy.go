@@ -0,0 +1,305 @@
+// Package netpolicy 代理和端口扫描的目标地址策略，按IP、CIDR、域名和端口允许或禁止访问，
+// 默认禁止内网地址，防止借助本服务访问内网或云平台元数据接口（SSRF）。
+package netpolicy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+// ErrDenied 目标地址被策略禁止，具体原因附在错误信息后面
+var ErrDenied = errors.New("目标地址被安全策略禁止")
+
+// reservedNets net.IP的方法没有覆盖、但同样不应从外部访问的地址段
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8",      // 本网络，Linux上0.0.0.0等同于本机
+	"100.64.0.0/10",  // 运营商级NAT
+	"192.0.0.0/24",   // IETF协议分配
+	"198.18.0.0/15",  // 网络基准测试
+	"240.0.0.0/4",    // 保留地址和广播地址
+	"64:ff9b::/96",   // NAT64，可以内嵌任意IPv4地址
+	"64:ff9b:1::/48", // 本地NAT64
+	"2002::/16",      // 6to4，可以内嵌任意IPv4地址
+)
+
+// Policy 默认规则和按角色覆盖的规则
+type Policy struct {
+	defaultRule *Rule
+	roles       map[string]*Rule
+}
+
+// Rule 编译后的目标地址规则，实现curlcmd.DestinationChecker
+type Rule struct {
+	allowPrivate bool
+	allow        matcher
+	deny         matcher
+	ports        []portRange
+	denyPorts    []portRange
+}
+
+// matcher 一组IP、CIDR和域名
+type matcher struct {
+	nets     []*net.IPNet
+	names    map[string]bool
+	suffixes []string // *.example.com 保存为 .example.com
+}
+
+// portRange 闭区间的端口范围
+type portRange struct {
+	from, to int
+}
+
+// New 按配置编译策略，配置中有无效的IP、CIDR或端口时返回错误
+func New(cfg config.DestinationConfig) (*Policy, error) {
+	defaultRule, err := compile(cfg.DestinationRule)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{defaultRule: defaultRule, roles: make(map[string]*Rule)}
+	for role, rc := range cfg.Roles {
+		rule, err := compile(rc)
+		if err != nil {
+			return nil, fmt.Errorf("角色%s: %v", role, err)
+		}
+		p.roles[role] = rule
+	}
+	return p, nil
+}
+
+// ForRole 返回角色使用的规则，没有单独配置的角色使用默认规则
+func (p *Policy) ForRole(role string) *Rule {
+	if rule, ok := p.roles[role]; ok {
+		return rule
+	}
+	return p.defaultRule
+}
+
+// compile 解析一条规则配置
+func compile(rc config.DestinationRule) (*Rule, error) {
+	r := &Rule{allowPrivate: rc.AllowPrivate}
+	var err error
+	if r.allow, err = parseMatcher(rc.Allow); err != nil {
+		return nil, err
+	}
+	if r.deny, err = parseMatcher(rc.Deny); err != nil {
+		return nil, err
+	}
+	if r.ports, err = parsePortRanges(rc.Ports); err != nil {
+		return nil, err
+	}
+	if r.denyPorts, err = parsePortRanges(rc.DenyPorts); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CheckHost 检查请求地址中的主机和端口，port为0时不检查端口。
+// host是IP时完整检查；是域名时只检查端口和域名规则，解析出的IP在连接时由CheckDial检查
+func (r *Rule) CheckHost(host string, port int) error {
+	host = normalizeHost(host)
+	if ip := net.ParseIP(host); ip != nil {
+		return r.CheckDial(ip.String(), ip, port)
+	}
+	if err := r.checkPort(port); err != nil {
+		return err
+	}
+	if r.deny.matchName(host) {
+		return denied("%s在禁止列表中", host)
+	}
+	return nil
+}
+
+// CheckDial 检查实际连接的IP和端口，host是DNS解析前的主机名，port为0时不检查端口
+func (r *Rule) CheckDial(host string, ip net.IP, port int) error {
+	host = normalizeHost(host)
+	if ip == nil {
+		return denied("%s的地址无效", host)
+	}
+	if err := r.checkPort(port); err != nil {
+		return err
+	}
+
+	display := ip.String()
+	if host != "" && host != display {
+		display = fmt.Sprintf("%s(%s)", host, ip)
+	}
+	if r.deny.matchName(host) || r.deny.matchIP(ip) {
+		return denied("%s在禁止列表中", display)
+	}
+	if r.allow.matchName(host) || r.allow.matchIP(ip) {
+		return nil
+	}
+	if !r.allowPrivate && isPrivate(ip) {
+		return denied("%s是内网地址", display)
+	}
+	return nil
+}
+
+// CheckURL 检查URL的主机和端口，未写端口时按协议取默认端口
+func (r *Rule) CheckURL(u *url.URL) error {
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		port = 80
+		if u.Scheme == "https" {
+			port = 443
+		}
+	}
+	return r.CheckHost(u.Hostname(), port)
+}
+
+// Dialer 返回base的副本，在DNS解析之后、建立连接之前按规则检查实际连接的地址，防止DNS重绑定
+func (r *Rule) Dialer(base *net.Dialer, host string) *net.Dialer {
+	d := *base
+	d.Control = func(network, address string, _ syscall.RawConn) error {
+		ipStr, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		port, _ := strconv.Atoi(portStr)
+		return r.CheckDial(host, net.ParseIP(ipStr), port)
+	}
+	return &d
+}
+
+// checkPort 检查端口，DenyPorts优先于Ports
+func (r *Rule) checkPort(port int) error {
+	if port == 0 {
+		return nil
+	}
+	if inPortRanges(r.denyPorts, port) {
+		return denied("端口%d被禁止", port)
+	}
+	if len(r.ports) > 0 && !inPortRanges(r.ports, port) {
+		return denied("端口%d不在允许范围内", port)
+	}
+	return nil
+}
+
+// isPrivate 是否为回环、私有、链路本地、组播、未指定等不应从外部访问的地址
+func isPrivate(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMatcher 解析Allow、Deny列表
+func parseMatcher(entries []string) (matcher, error) {
+	m := matcher{names: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = normalizeHost(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case strings.Contains(entry, "/"):
+			_, n, err := net.ParseCIDR(entry)
+			if err != nil {
+				return m, fmt.Errorf("无效的CIDR: %s", entry)
+			}
+			m.nets = append(m.nets, n)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			m.nets = append(m.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		case strings.HasPrefix(entry, "*."):
+			m.suffixes = append(m.suffixes, entry[1:])
+		default:
+			m.names[entry] = true
+		}
+	}
+	return m, nil
+}
+
+// matchName 域名是否命中，*.example.com不匹配example.com本身
+func (m matcher) matchName(host string) bool {
+	if host == "" {
+		return false
+	}
+	if m.names[host] {
+		return true
+	}
+	for _, suffix := range m.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchIP IP是否落在列表中的某个地址段
+func (m matcher) matchIP(ip net.IP) bool {
+	for _, n := range m.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePortRanges 解析"80"、"8000-9000"形式的端口列表
+func parsePortRanges(entries []string) ([]portRange, error) {
+	var ranges []portRange
+	for _, entry := range entries {
+		from, to, found := strings.Cut(strings.TrimSpace(entry), "-")
+		start, err1 := strconv.Atoi(strings.TrimSpace(from))
+		end, err2 := start, error(nil)
+		if found {
+			end, err2 = strconv.Atoi(strings.TrimSpace(to))
+		}
+		if err1 != nil || err2 != nil || start < 1 || end > 65535 || start > end {
+			return nil, fmt.Errorf("无效的端口范围: %s", entry)
+		}
+		ranges = append(ranges, portRange{from: start, to: end})
+	}
+	return ranges, nil
+}
+
+// inPortRanges 端口是否在某个范围内
+func inPortRanges(ranges []portRange, port int) bool {
+	for _, pr := range ranges {
+		if port >= pr.from && port <= pr.to {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost 统一主机名的格式：小写，去掉末尾的点和IPv6地址的方括号
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// denied 返回包装了ErrDenied的错误
+func denied(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrDenied, fmt.Sprintf(format, args...))
+}
+
+// mustParseCIDRs 解析内置的地址段
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
@@ -0,0 +1,297 @@
+package netpolicy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+func TestIsPrivate(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"::", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"2002:7f00:1::", true},
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"172.32.0.1", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := isPrivate(net.ParseIP(tt.ip)); got != tt.private {
+			t.Errorf("isPrivate(%s) = %v, want %v", tt.ip, got, tt.private)
+		}
+	}
+}
+
+func TestCheckDial(t *testing.T) {
+	policy, err := New(config.DestinationConfig{DestinationRule: config.DestinationRule{
+		Allow:     []string{"10.0.0.0/24", "internal.example.com", "*.svc.example.com", "192.168.1.10"},
+		Deny:      []string{"10.0.0.5", "evil.example.com", "*.blocked.example.com", "203.0.113.0/24"},
+		Ports:     []string{"80", "443", "8000-9000"},
+		DenyPorts: []string{"8500"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := policy.ForRole("user")
+
+	tests := []struct {
+		name    string
+		host    string
+		ip      string
+		port    int
+		allowed bool
+	}{
+		{"public address", "example.com", "93.184.216.34", 443, true},
+		{"private address", "example.com", "10.1.0.1", 443, false},
+		{"loopback by IP", "", "127.0.0.1", 80, false},
+		{"allowed CIDR", "", "10.0.0.7", 80, true},
+		{"denied IP inside allowed CIDR", "", "10.0.0.5", 80, false},
+		{"allowed single IP", "", "192.168.1.10", 80, true},
+		{"other IP in same subnet", "", "192.168.1.11", 80, false},
+		{"allowed host resolving to private IP", "internal.example.com", "10.9.9.9", 80, true},
+		{"allowed host is case insensitive", "Internal.Example.COM.", "10.9.9.9", 80, true},
+		{"allowed wildcard", "api.svc.example.com", "10.9.9.9", 443, true},
+		{"wildcard does not match apex", "svc.example.com", "10.9.9.9", 443, false},
+		{"denied host", "evil.example.com", "93.184.216.34", 443, false},
+		{"denied wildcard", "a.b.blocked.example.com", "93.184.216.34", 443, false},
+		{"denied CIDR", "example.com", "203.0.113.9", 443, false},
+		{"port in range", "example.com", "93.184.216.34", 8080, true},
+		{"port not allowed", "example.com", "93.184.216.34", 22, false},
+		{"denied port inside range", "example.com", "93.184.216.34", 8500, false},
+		{"port not checked", "example.com", "93.184.216.34", 0, true},
+		{"missing IP", "example.com", "", 443, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rule.CheckDial(tt.host, net.ParseIP(tt.ip), tt.port)
+			if tt.allowed && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrDenied) {
+				t.Fatalf("error = %v, want ErrDenied", err)
+			}
+		})
+	}
+}
+
+func TestCheckHostAndURL(t *testing.T) {
+	policy, err := New(config.DestinationConfig{DestinationRule: config.DestinationRule{
+		Deny:  []string{"*.internal"},
+		Ports: []string{"443"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := policy.ForRole("")
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/a", true},
+		{"http://example.com/a", false}, // 默认端口80不在允许范围内
+		{"https://example.com:8443/", false},
+		{"https://db.internal/", false},
+		{"https://127.0.0.1/", false},
+		{"https://[::1]/", false},
+		{"https://93.184.216.34/", true},
+		// 域名在请求前只检查域名规则，解析出的IP在连接时检查
+		{"https://localhost.example.com/", true},
+	}
+	for _, tt := range tests {
+		err := rule.CheckURL(mustParse(t, tt.url))
+		if tt.allowed != (err == nil) {
+			t.Errorf("CheckURL(%s) = %v, allowed want %v", tt.url, err, tt.allowed)
+		}
+	}
+}
+
+func TestRoleOverrides(t *testing.T) {
+	policy, err := New(config.DestinationConfig{
+		DestinationRule: config.DestinationRule{DenyPorts: []string{"25"}},
+		Roles: map[string]config.DestinationRule{
+			"admin": {AllowPrivate: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loopback := net.ParseIP("127.0.0.1")
+	if err := policy.ForRole("user").CheckDial("", loopback, 80); err == nil {
+		t.Error("default rule allowed a private address")
+	}
+	if err := policy.ForRole("admin").CheckDial("", loopback, 80); err != nil {
+		t.Errorf("admin rule: %v", err)
+	}
+	// 角色的规则完全替代默认规则，不继承默认规则的DenyPorts
+	if err := policy.ForRole("admin").CheckDial("", net.ParseIP("93.184.216.34"), 25); err != nil {
+		t.Errorf("admin rule inherited denyPorts: %v", err)
+	}
+	if err := policy.ForRole("user").CheckDial("", net.ParseIP("93.184.216.34"), 25); err == nil {
+		t.Error("default rule allowed a denied port")
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	configs := []config.DestinationConfig{
+		{DestinationRule: config.DestinationRule{Allow: []string{"10.0.0.0/33"}}},
+		{DestinationRule: config.DestinationRule{Ports: []string{"0"}}},
+		{DestinationRule: config.DestinationRule{Ports: []string{"9000-8000"}}},
+		{DestinationRule: config.DestinationRule{DenyPorts: []string{"http"}}},
+		{Roles: map[string]config.DestinationRule{"user": {Deny: []string{"1.2.3.4/x"}}}},
+	}
+	for _, cfg := range configs {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) accepted an invalid config", cfg)
+		}
+	}
+}
+
+// TestDialerPreventsRebinding 域名第一次解析为公网地址、连接时解析为内网地址（DNS重绑定），
+// 请求前的检查和第一次解析都能通过，连接时按实际的IP检查被禁止
+func TestDialerPreventsRebinding(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+	addr := net.JoinHostPort("rebind.example.com.", strconv.Itoa(port))
+
+	strict, _ := New(config.DestinationConfig{})
+	rule := strict.ForRole("")
+	if err := rule.CheckHost("rebind.example.com", port); err != nil {
+		t.Fatalf("CheckHost: %v", err)
+	}
+
+	resolver := newRebindingResolver(t, "93.184.216.34", "127.0.0.1", "127.0.0.1")
+	ips, err := resolver.LookupIP(context.Background(), "ip4", "rebind.example.com.")
+	if err != nil || len(ips) != 1 {
+		t.Fatalf("LookupIP = %v, %v", ips, err)
+	}
+	if err := rule.CheckDial("rebind.example.com", ips[0], port); err != nil {
+		t.Fatalf("first resolution was not public: %v", err)
+	}
+
+	base := &net.Dialer{Timeout: 5 * time.Second, Resolver: resolver}
+	_, err = rule.Dialer(base, "rebind.example.com").DialContext(context.Background(), "tcp4", addr)
+	if !errors.Is(err, ErrDenied) {
+		t.Fatalf("dial after rebinding: %v, want ErrDenied", err)
+	}
+
+	// 允许内网地址时同一个解析器可以正常连接，说明连接的确实是重新解析出的地址
+	lenient, _ := New(config.DestinationConfig{DestinationRule: config.DestinationRule{AllowPrivate: true}})
+	conn, err := lenient.ForRole("").Dialer(base, "rebind.example.com").DialContext(context.Background(), "tcp4", addr)
+	if err != nil {
+		t.Fatalf("dial with allowPrivate: %v", err)
+	}
+	conn.Close()
+}
+
+// newRebindingResolver 返回使用本地DNS服务器的解析器，对A记录查询依次返回answers中的地址，用完后重复最后一个
+func newRebindingResolver(t *testing.T, answers ...string) *net.Resolver {
+	t.Helper()
+	server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	var mu sync.Mutex
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			answer := answers[0]
+			if len(answers) > 1 {
+				answers = answers[1:]
+			}
+			mu.Unlock()
+			if reply := dnsReply(buf[:n], net.ParseIP(answer).To4()); reply != nil {
+				server.WriteTo(reply, from)
+			}
+		}
+	}()
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp4", server.LocalAddr().String())
+		},
+	}
+}
+
+// dnsReply 按查询报文构造只含一条A记录的应答，查询格式不对时返回nil
+func dnsReply(query []byte, ip net.IP) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	// 跳过问题中的域名，后面是查询类型和类
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+	reply := append([]byte(nil), query[:end]...)
+	binary.BigEndian.PutUint16(reply[2:], 0x8180) // 应答、期望递归、可递归、无错误
+	binary.BigEndian.PutUint16(reply[6:], 1)      // 一条回答
+	binary.BigEndian.PutUint16(reply[8:], 0)
+	binary.BigEndian.PutUint16(reply[10:], 0)
+	reply = append(reply, 0xc0, 0x0c) // 指向问题中的域名
+	reply = binary.BigEndian.AppendUint16(reply, 1)
+	reply = binary.BigEndian.AppendUint16(reply, 1)
+	reply = binary.BigEndian.AppendUint32(reply, 0)
+	reply = binary.BigEndian.AppendUint16(reply, 4)
+	return append(reply, ip...)
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
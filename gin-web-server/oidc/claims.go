@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Claims 已校验的ID令牌声明，IdP的自定义声明（如分组）按名称读取
+type Claims struct {
+	Raw map[string]interface{}
+}
+
+// Subject IdP中用户的唯一标识
+func (c *Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Email 邮箱，EmailVerified 为IdP声明该邮箱已验证
+func (c *Claims) Email() string {
+	return c.String("email")
+}
+
+func (c *Claims) EmailVerified() bool {
+	switch v := c.Raw["email_verified"].(type) {
+	case bool:
+		return v
+	case string:
+		// 部分IdP以字符串形式返回
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// String 读取字符串声明，不存在或类型不符时返回空字符串
+func (c *Claims) String(name string) string {
+	if v, ok := c.Raw[name].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Strings 读取字符串数组声明，单个字符串视为只有一个元素的数组
+func (c *Claims) Strings(name string) []string {
+	switch v := c.Raw[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			} else {
+				values = append(values, fmt.Sprint(item))
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// RandomString 生成state、nonce和PKCE校验码使用的随机串
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CodeChallenge 按PKCE S256方法由校验码计算challenge
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,247 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/jwt"
+)
+
+const (
+	httpTimeout = 10 * time.Second
+	// jwksMinRefresh 遇到未知kid时重新拉取JWKS的最小间隔，防止伪造的kid触发大量请求
+	jwksMinRefresh = 30 * time.Second
+	// maxResponseSize IdP响应的大小上限
+	maxResponseSize = 1 << 20
+)
+
+var (
+	ErrInvalidIssuer   = errors.New("ID令牌的签发者不匹配")
+	ErrInvalidAudience = errors.New("ID令牌的受众不匹配")
+	ErrInvalidNonce    = errors.New("ID令牌的nonce不匹配")
+)
+
+// Metadata IdP发现文档中用到的字段
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider 一个OpenID Connect身份提供方，发现文档和JWKS在首次使用时获取并缓存
+type Provider struct {
+	cfg    config.OIDCConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	keys        *jwt.KeySet
+	keysFetched time.Time
+}
+
+// New 创建身份提供方，不在启动时访问IdP，IdP暂时不可用不影响服务启动
+func New(cfg config.OIDCConfig) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("单点登录需要配置issuer和clientId")
+	}
+	if cfg.RedirectURL == "" {
+		return nil, errors.New("单点登录需要配置redirectURL")
+	}
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: httpTimeout}}, nil
+}
+
+// discover 获取并缓存发现文档，失败时下次调用重试
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var meta Metadata
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &meta); err != nil {
+		return nil, fmt.Errorf("获取IdP发现文档失败: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("发现文档的issuer %q 与配置不一致", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("发现文档缺少必需的端点")
+	}
+	p.metadata = &meta
+	return p.metadata, nil
+}
+
+// keySet 返回IdP的验签公钥，refresh为true时在最小间隔外重新拉取
+func (p *Provider) keySet(ctx context.Context, meta *Metadata, refresh bool) (*jwt.KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && (!refresh || time.Since(p.keysFetched) < jwksMinRefresh) {
+		return p.keys, nil
+	}
+
+	var set jwt.JWKS
+	if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("获取IdP公钥失败: %w", err)
+	}
+	keys, err := jwt.KeySetFromJWKS(set)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+	return keys, nil
+}
+
+// AuthCodeURL 生成跳转到IdP的授权地址
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("授权端点无效: %w", err)
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange 用授权码和PKCE校验码换取ID令牌，校验签名、签发者、受众和nonce后返回声明
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("换取令牌失败: %w", err)
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("换取令牌失败: HTTP %d %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("令牌响应中没有id_token")
+	}
+	return p.verifyIDToken(ctx, meta, token.IDToken, nonce)
+}
+
+// verifyIDToken 校验ID令牌，遇到未知kid时刷新一次JWKS以支持IdP密钥轮换
+func (p *Provider) verifyIDToken(ctx context.Context, meta *Metadata, idToken, nonce string) (*Claims, error) {
+	keys, err := p.keySet(ctx, meta, false)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	err = keys.Verify(idToken, &raw)
+	if errors.Is(err, jwt.ErrUnknownKey) {
+		if keys, err = p.keySet(ctx, meta, true); err != nil {
+			return nil, err
+		}
+		err = keys.Verify(idToken, &raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{Raw: raw}
+	if strings.TrimSuffix(claims.String("iss"), "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, ErrInvalidIssuer
+	}
+	if !containsString(claims.Strings("aud"), p.cfg.ClientID) {
+		return nil, ErrInvalidAudience
+	}
+	if claims.String("nonce") != nonce {
+		return nil, ErrInvalidNonce
+	}
+	if claims.Subject() == "" {
+		return nil, errors.New("ID令牌缺少sub")
+	}
+	return claims, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	status, err := p.doJSON(req, v)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("HTTP %d", status)
+	}
+	return nil
+}
+
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("响应不是有效的JSON: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/jwt"
+	"github.com/lf-web-tools/gin-web-server/oidc/oidctest"
+)
+
+const testClientID = "lf-web-tools"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	t.Helper()
+	idp := oidctest.NewServer(testClientID)
+	t.Cleanup(idp.Close)
+	p, err := New(config.OIDCConfig{
+		Issuer:      idp.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, idp
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	p, idp := newTestProvider(t)
+	idp.Claims = map[string]interface{}{"sub": "u-1", "email": "a@example.com", "email_verified": true}
+	ctx := context.Background()
+
+	verifier, _ := RandomString()
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", CodeChallenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := mustParse(t, authURL).Query()
+	if query.Get("scope") != "openid email" || query.Get("state") != "state-1" || query.Get("redirect_uri") == "" {
+		t.Fatalf("unexpected authorization URL: %s", authURL)
+	}
+
+	callback, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.Exchange(ctx, callback.Query().Get("code"), verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject() != "u-1" || claims.Email() != "a@example.com" || !claims.EmailVerified() {
+		t.Errorf("unexpected claims: %v", claims.Raw)
+	}
+
+	// 授权码只能使用一次
+	if _, err := p.Exchange(ctx, callback.Query().Get("code"), verifier, "nonce-1"); err == nil {
+		t.Error("reused authorization code was accepted")
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	p, idp := newTestProvider(t)
+	idp.Claims = map[string]interface{}{"sub": "u-1"}
+	ctx := context.Background()
+
+	verifier, _ := RandomString()
+	authURL, err := p.AuthCodeURL(ctx, "s", "n", CodeChallenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(ctx, callback.Query().Get("code"), "other-verifier", "n"); err == nil {
+		t.Error("exchange with wrong code_verifier succeeded")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	p, idp := newTestProvider(t)
+	ctx := context.Background()
+	meta, err := p.discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	forgerKey, _ := jwt.NewSigningKey("key-1", otherKey)
+	forger, _ := jwt.NewKeySet("key-1", forgerKey)
+	forged, _ := forger.Sign(map[string]interface{}{"iss": idp.URL, "aud": testClientID, "sub": "u-1", "nonce": "n"})
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		token  string
+		nonce  string
+		want   error
+	}{
+		{name: "valid", claims: map[string]interface{}{"sub": "u-1", "nonce": "n"}, nonce: "n"},
+		{name: "audience list", claims: map[string]interface{}{"sub": "u-1", "nonce": "n", "aud": []string{"other", testClientID}}, nonce: "n"},
+		{name: "issuer with trailing slash", claims: map[string]interface{}{"sub": "u-1", "nonce": "n", "iss": idp.URL + "/"}, nonce: "n"},
+		{name: "wrong issuer", claims: map[string]interface{}{"sub": "u-1", "nonce": "n", "iss": "https://evil.example.com"}, nonce: "n", want: ErrInvalidIssuer},
+		{name: "wrong audience", claims: map[string]interface{}{"sub": "u-1", "nonce": "n", "aud": "other-client"}, nonce: "n", want: ErrInvalidAudience},
+		{name: "missing audience", claims: map[string]interface{}{"sub": "u-1", "nonce": "n", "aud": nil}, nonce: "n", want: ErrInvalidAudience},
+		{name: "wrong nonce", claims: map[string]interface{}{"sub": "u-1", "nonce": "other"}, nonce: "n", want: ErrInvalidNonce},
+		{name: "missing nonce", claims: map[string]interface{}{"sub": "u-1"}, nonce: "n", want: ErrInvalidNonce},
+		{name: "expired", claims: map[string]interface{}{"sub": "u-1", "nonce": "n", "exp": time.Now().Add(-time.Minute).Unix()}, nonce: "n", want: jwt.ErrExpired},
+		{name: "forged signature with known kid", token: forged, nonce: "n", want: jwt.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.token
+			if token == "" {
+				if token, err = idp.SignIDToken(tt.claims); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := p.verifyIDToken(ctx, meta, token, tt.nonce)
+			if tt.want == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	token, _ := idp.SignIDToken(map[string]interface{}{"nonce": "n"})
+	if _, err := p.verifyIDToken(ctx, meta, token, "n"); err == nil {
+		t.Error("token without sub was accepted")
+	}
+}
+
+func TestVerifyIDTokenRefreshesJWKSOnUnknownKid(t *testing.T) {
+	p, idp := newTestProvider(t)
+	ctx := context.Background()
+	meta, err := p.discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _ := idp.SignIDToken(map[string]interface{}{"sub": "u-1", "nonce": "n"})
+	if _, err := p.verifyIDToken(ctx, meta, token, "n"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&idp.JWKSRequests); n != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", n)
+	}
+
+	// IdP轮换密钥后，最小刷新间隔内不重新拉取JWKS，防止伪造的kid触发大量请求
+	if err := idp.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := idp.SignIDToken(map[string]interface{}{"sub": "u-1", "nonce": "n"})
+	if _, err := p.verifyIDToken(ctx, meta, rotated, "n"); !errors.Is(err, jwt.ErrUnknownKey) {
+		t.Fatalf("error = %v, want ErrUnknownKey within refresh interval", err)
+	}
+	if n := atomic.LoadInt32(&idp.JWKSRequests); n != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", n)
+	}
+
+	// 超过最小间隔后遇到未知kid刷新一次JWKS
+	p.mu.Lock()
+	p.keysFetched = time.Now().Add(-jwksMinRefresh)
+	p.mu.Unlock()
+	if _, err := p.verifyIDToken(ctx, meta, rotated, "n"); err != nil {
+		t.Fatalf("token signed with rotated key: %v", err)
+	}
+	if n := atomic.LoadInt32(&idp.JWKSRequests); n != 2 {
+		t.Fatalf("JWKS requests = %d, want 2", n)
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -0,0 +1,201 @@
+// Package oidctest 用于测试的本地OpenID Connect身份提供方：提供发现文档、JWKS和令牌端点，
+// 用Ed25519密钥签发ID令牌，并按PKCE S256校验授权码。
+package oidctest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/jwt"
+)
+
+// Server 模拟的IdP，Claims为下一次签发的ID令牌中附加的声明（如sub、email、groups）
+type Server struct {
+	*httptest.Server
+	ClientID string
+
+	mu       sync.Mutex
+	keys     *jwt.KeySet
+	keyCount int
+	codes    map[string]authorization
+	Claims   map[string]interface{}
+
+	// JWKSRequests JWKS端点被请求的次数
+	JWKSRequests int32
+}
+
+// authorization 用户在IdP同意授权后生成的授权码
+type authorization struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+	claims      map[string]interface{}
+}
+
+// NewServer 启动模拟的IdP，测试结束时须调用Close
+func NewServer(clientID string) *Server {
+	s := &Server{ClientID: clientID, codes: make(map[string]authorization), Claims: map[string]interface{}{}}
+	if err := s.RotateKey(); err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 s.URL,
+			"authorization_endpoint": s.URL + "/authorize",
+			"token_endpoint":         s.URL + "/token",
+			"jwks_uri":               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.JWKSRequests, 1)
+		s.mu.Lock()
+		set := s.keys.JWKS()
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, set)
+	})
+	mux.HandleFunc("/token", s.handleToken)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// RotateKey 换用新的签名密钥，JWKS中只公开新密钥，用于测试未知kid时刷新JWKS
+func (s *Server) RotateKey() error {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyCount++
+	kid := fmt.Sprintf("key-%d", s.keyCount)
+	key, err := jwt.NewSigningKey(kid, private)
+	if err != nil {
+		return err
+	}
+	s.keys, err = jwt.NewKeySet(kid, key)
+	return err
+}
+
+// SignIDToken 用当前密钥签发ID令牌，默认填好iss、aud、iat和exp，claims中的同名声明覆盖默认值
+func (s *Server) SignIDToken(claims map[string]interface{}) (string, error) {
+	now := time.Now()
+	payload := map[string]interface{}{
+		"iss": s.URL,
+		"aud": s.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	for key, value := range claims {
+		if value == nil {
+			delete(payload, key)
+			continue
+		}
+		payload[key] = value
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys.Sign(payload)
+}
+
+// Authorize 模拟用户在IdP登录并同意授权：校验授权地址的参数，返回带code和state的回调地址。
+// 签发的ID令牌包含当时的Claims
+func (s *Server) Authorize(authURL string) (*url.URL, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	if u.Path != "/authorize" || query.Get("response_type") != "code" || query.Get("client_id") != s.ClientID {
+		return nil, fmt.Errorf("授权请求无效: %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" || query.Get("nonce") == "" {
+		return nil, errors.New("授权请求缺少PKCE或nonce")
+	}
+
+	code := randomString()
+	claims := make(map[string]interface{}, len(s.Claims))
+	s.mu.Lock()
+	for key, value := range s.Claims {
+		claims[key] = value
+	}
+	s.codes[code] = authorization{
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		redirectURI: query.Get("redirect_uri"),
+		claims:      claims,
+	}
+	s.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		return nil, err
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	return redirect, nil
+}
+
+// handleToken 令牌端点：授权码只能使用一次，code_verifier、client_id和redirect_uri须与授权时一致
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	s.mu.Lock()
+	auth, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !ok:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown code"})
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	case r.PostForm.Get("client_id") != s.ClientID || r.PostForm.Get("redirect_uri") != auth.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	claims := map[string]interface{}{"nonce": auth.nonce}
+	for key, value := range auth.claims {
+		claims[key] = value
+	}
+	idToken, err := s.SignIDToken(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"token_type": "Bearer", "access_token": randomString(), "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,260 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// userView 对外返回的用户信息，不包含密码哈希
+type userView struct {
+	Username         string `json:"username"`
+	Email            string `json:"email"`
+	Phone            string `json:"phone"`
+	UUID             string `json:"uuid"`
+	Role             string `json:"role"`
+	Disabled         bool   `json:"disabled"`
+	EmailVerified    bool   `json:"emailVerified"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
+	SSOLinked        bool   `json:"ssoLinked"`
+	HasPassword      bool   `json:"hasPassword"`
+	CreatedAt        string `json:"createdAt"`
+	UpdatedAt        string `json:"updatedAt"`
+}
+
+func (u userRecord) view() userView {
+	return userView{
+		Username:         u.Username,
+		Email:            u.Email,
+		Phone:            u.Phone,
+		UUID:             u.UUID,
+		Role:             u.roleName(),
+		Disabled:         u.Disabled,
+		EmailVerified:    u.EmailVerified,
+		TwoFactorEnabled: u.TOTPEnabled,
+		SSOLinked:        u.OIDCSubject != "",
+		HasPassword:      u.PasswordHash != "",
+		CreatedAt:        u.CreatedAt,
+		UpdatedAt:        u.UpdatedAt,
+	}
+}
+
+// setupAdminUserRoutes 管理员用户管理接口：列表、创建、修改角色/禁用、删除、重置密码
+func setupAdminUserRoutes(admin *gin.RouterGroup) {
+	admin.GET("/users", func(c *gin.Context) {
+		users := make([]userView, 0)
+		err := dataStore.ForEach(store.BucketUsers, func(key string, raw []byte) error {
+			var record userRecord
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return err
+			}
+			users = append(users, record.view())
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取用户列表失败"})
+			return
+		}
+		sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+		c.JSON(http.StatusOK, gin.H{"success": true, "users": users})
+	})
+
+	admin.POST("/users", func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Email    string `json:"email"`
+			Phone    string `json:"phone"`
+			Role     string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+		if len(req.Username) < 3 || len(req.Password) < 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "用户名或密码长度不符合要求"})
+			return
+		}
+		if req.Role == "" {
+			req.Role = RoleUser
+		}
+		if !roleExists(req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在"})
+			return
+		}
+		if req.Email != "" && !validEmail(req.Email) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱格式不正确"})
+			return
+		}
+
+		passwordHash, err := hashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存用户失败"})
+			return
+		}
+		uuid, err := generateUUID()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存用户失败"})
+			return
+		}
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		record := userRecord{
+			Username:     req.Username,
+			PasswordHash: passwordHash,
+			Email:        req.Email,
+			Phone:        req.Phone,
+			UUID:         uuid,
+			Role:         req.Role,
+			// 管理员创建的账号视为邮箱已验证
+			EmailVerified: req.Email != "",
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := dataStore.Create(store.BucketUsers, req.Username, record); err != nil {
+			if errors.Is(err, store.ErrExists) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存用户失败"})
+			return
+		}
+		audit.Log(c, auditUserCreate, record.Username, audit.ResultSuccess, "角色: "+record.Role)
+		c.JSON(http.StatusOK, gin.H{"success": true, "user": record.view()})
+	})
+
+	admin.PATCH("/users/:username", func(c *gin.Context) {
+		var req struct {
+			Role     *string `json:"role"`
+			Disabled *bool   `json:"disabled"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		username := c.Param("username")
+		if username == CurrentUser(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能修改自己的角色或状态"})
+			return
+		}
+		if req.Role != nil && !roleExists(*req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在"})
+			return
+		}
+
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		var changes []string
+		if req.Role != nil {
+			changes = append(changes, fmt.Sprintf("角色: %s -> %s", record.roleName(), *req.Role))
+			record.Role = *req.Role
+		}
+		if req.Disabled != nil {
+			changes = append(changes, fmt.Sprintf("禁用: %v -> %v", record.Disabled, *req.Disabled))
+			record.Disabled = *req.Disabled
+		}
+		record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+		if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存用户失败"})
+			return
+		}
+		// JWT载荷中的角色在令牌过期前不会更新，调整角色时同样吊销已有会话
+		if record.Disabled || (req.Role != nil && jwtKeys != nil) {
+			_ = deleteUserTokens(username)
+		}
+		audit.Log(c, auditUserUpdate, username, audit.ResultSuccess, strings.Join(changes, ", "))
+		c.JSON(http.StatusOK, gin.H{"success": true, "user": record.view()})
+	})
+
+	admin.DELETE("/users/:username", func(c *gin.Context) {
+		username := c.Param("username")
+		if username == CurrentUser(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能删除自己"})
+			return
+		}
+
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		if _, exists := getUser(username); !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		if err := deleteUserData(username); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
+			return
+		}
+		audit.Log(c, auditUserDelete, username, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	admin.POST("/users/:username/reset-password", func(c *gin.Context) {
+		var req struct {
+			NewPassword string `json:"newPassword"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		// 未指定新密码时生成随机密码返回给管理员
+		generated := req.NewPassword == ""
+		if generated {
+			password, err := randomString(12)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密码失败"})
+				return
+			}
+			req.NewPassword = password
+		}
+		if len(req.NewPassword) < 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "新密码长度不能少于6位"})
+			return
+		}
+
+		passwordHash, err := hashPassword(req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存新密码失败"})
+			return
+		}
+
+		username := c.Param("username")
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		record.PasswordHash = passwordHash
+		record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+		if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存新密码失败"})
+			return
+		}
+		_ = deleteUserTokens(username)
+		audit.Log(c, auditUserResetPassword, username, audit.ResultSuccess, "")
+
+		response := gin.H{"success": true, "message": "密码已重置，该用户需重新登录"}
+		if generated {
+			response["password"] = req.NewPassword
+		}
+		c.JSON(http.StatusOK, response)
+	})
+}
@@ -3,25 +3,27 @@ package routes
 import (
 	"bytes"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"image"
 	"image/color"
 	"image/draw"
 	_ "image/gif"
 	"image/png"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/captcha"
+	"github.com/lf-web-tools/gin-web-server/config"
+	mailer "github.com/lf-web-tools/gin-web-server/mail"
+	"github.com/lf-web-tools/gin-web-server/store"
 	"github.com/skip2/go-qrcode"
 )
 
@@ -39,13 +41,9 @@ type QRCodeRequest struct {
 }
 
 type captchaItem struct {
-	code      string
-	expiresAt time.Time
-}
-
-type authToken struct {
-	username  string
-	expiresAt time.Time
+	Type      string    `json:"type"`
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 type userRecord struct {
@@ -54,69 +52,117 @@ type userRecord struct {
 	Email        string `json:"email"`
 	Phone        string `json:"phone"`
 	UUID         string `json:"uuid"`
-	CreatedAt    string `json:"createdAt"`
-	UpdatedAt    string `json:"updatedAt"`
+	Role         string `json:"role,omitempty"`
+	Disabled     bool   `json:"disabled,omitempty"`
+	// EmailVerified 邮箱是否已通过验证邮件确认
+	EmailVerified bool   `json:"emailVerified,omitempty"`
+	CreatedAt     string `json:"createdAt"`
+	UpdatedAt     string `json:"updatedAt"`
+
+	// 两步验证：TOTPPendingSecret为已生成但尚未用动态码确认的密钥，
+	// TOTPLastStep为最近一次通过校验的时间步，RecoveryCodes为恢复码的SHA-256摘要
+	TOTPSecret        string   `json:"totpSecret,omitempty"`
+	TOTPEnabled       bool     `json:"totpEnabled,omitempty"`
+	TOTPPendingSecret string   `json:"totpPendingSecret,omitempty"`
+	TOTPLastStep      int64    `json:"totpLastStep,omitempty"`
+	RecoveryCodes     []string `json:"recoveryCodes,omitempty"`
+
+	// 单点登录：关联的IdP签发者和用户标识(sub)，通过单点登录创建的用户没有本地密码
+	OIDCIssuer  string `json:"oidcIssuer,omitempty"`
+	OIDCSubject string `json:"oidcSubject,omitempty"`
 }
 
+const bucketCaptchas = "captchas"
+
 var (
-	captchaStore = struct {
-		sync.Mutex
-		data map[string]captchaItem
-	}{
-		data: make(map[string]captchaItem),
-	}
-	userStore = struct {
-		sync.Mutex
-		data map[string]userRecord
-	}{
-		data: map[string]userRecord{
-			"admin": {
-				Username:     "admin",
-				PasswordHash: hashPassword("admin123"),
-				Email:        "",
-				Phone:        "",
-				UUID:         "",
-				CreatedAt:    "",
-				UpdatedAt:    "",
-			},
-		},
-	}
-	tokenStore = struct {
-		sync.Mutex
-		data map[string]authToken
-	}{
-		data: make(map[string]authToken),
-	}
-	tokenTTL      = 24 * time.Hour
-	captchaTTL    = 5 * time.Minute
-	userDataFile  = "data/users.json"
-	loadUsersOnce sync.Once
+	// dataStore 用户和令牌的持久化存储，由SetupAPIRoutes注入
+	dataStore store.Store = store.NewMemoryStore()
+	// captchaStore 验证码生命周期短，始终保存在内存中
+	captchaStore store.Store = store.NewMemoryStore()
+	// userMu 串行化用户记录的读-改-写
+	userMu     sync.Mutex
+	captchaMu  sync.Mutex
+	captchaTTL = 5 * time.Minute
+	// captchaProvider 当前的验证方式，由配置决定
+	captchaProvider, _ = captcha.New(captcha.TypeText, captcha.Medium)
+	// challengeMu 串行化登录挑战的读-改-写，保证尝试次数计数准确
+	challengeMu sync.Mutex
 )
 
 // SetupAPIRoutes 设置API相关的路由
-func SetupAPIRoutes(r *gin.Engine) {
-	loadUsersOnce.Do(loadUsersFromFile)
+func SetupAPIRoutes(r *gin.Engine, cfg *config.Config, st store.Store) {
+	passwordParams = cfg.Auth.Password
+	rolePermissions = cfg.Auth.Roles
+	accessTokenTTL = time.Duration(cfg.Auth.AccessTokenTTL)
+	refreshTokenTTL = time.Duration(cfg.Auth.RefreshTokenTTL)
+	lockoutConfig = cfg.Auth.Lockout
+	provider, err := captcha.New(cfg.Captcha.Provider, captcha.Difficulty(cfg.Captcha.Difficulty))
+	if err != nil {
+		log.Fatalf("验证码配置无效: %v", err)
+	}
+	captchaProvider = provider
+	captchaTTL = time.Duration(cfg.Captcha.TTL)
+	sender, err := mailer.New(cfg.Mail)
+	if err != nil {
+		log.Fatalf("邮件配置无效: %v", err)
+	}
+	mailSender = sender
+	mailBaseURL = cfg.Mail.BaseURL
+	resetTokenTTL = time.Duration(cfg.Auth.ResetTokenTTL)
+	verifyTokenTTL = time.Duration(cfg.Auth.VerifyTokenTTL)
+	requireEmailVerification = cfg.Auth.RequireEmailVerification
+	if err := initOIDC(cfg.Auth.OIDC); err != nil {
+		log.Fatalf("单点登录配置无效: %v", err)
+	}
+	if cfg.Auth.TokenMode == config.TokenModeJWT {
+		keys, err := loadJWTKeys(cfg.Auth.JWT)
+		if err != nil {
+			log.Fatalf("加载JWT密钥失败: %v", err)
+		}
+		jwtKeys = keys
+		jwtIssuer = cfg.Auth.JWT.Issuer
+		if accessTokenTTL > jwtMaxAccessTTL {
+			log.Printf("JWT访问令牌无法单独吊销，有效期由 %v 缩短为 %v", accessTokenTTL, jwtMaxAccessTTL)
+			accessTokenTTL = jwtMaxAccessTTL
+		}
+	}
+	dataStore = st
+	if err := initDefaultUsers(); err != nil {
+		log.Printf("初始化默认用户失败: %v", err)
+	}
+	startTokenSweeper(time.Duration(cfg.Auth.SweepInterval))
+
+	r.GET("/.well-known/jwks.json", handleJWKS)
 
 	api := r.Group("/api")
 	{
 		auth := api.Group("/auth")
 		{
 			auth.GET("/captcha", func(c *gin.Context) {
-				captchaID, imageData, err := generateCaptcha()
+				captchaID, challenge, err := generateCaptcha()
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{
 						"error": "生成验证码失败",
 					})
 					return
 				}
-				c.JSON(http.StatusOK, gin.H{
+				response := gin.H{
 					"captchaId":   captchaID,
-					"captchaData": imageData,
+					"captchaType": challenge.Type,
+					"captchaData": challenge.Image,
+					"width":       challenge.Width,
+					"height":      challenge.Height,
 					"expiresIn":   int(captchaTTL.Seconds()),
-				})
+				}
+				// 滑块验证码：客户端把拼图块拖到缺口处，提交拼图块左边缘的横坐标作为captchaCode
+				if challenge.Type == captcha.TypeSlider {
+					response["pieceData"] = challenge.Piece
+					response["pieceY"] = challenge.PieceY
+				}
+				c.JSON(http.StatusOK, response)
 			})
 
-			auth.POST("/register", func(c *gin.Context) {
+			auth.POST("/register", requirePasswordLogin(), func(c *gin.Context) {
 				var req struct {
 					Username    string `json:"username"`
 					Password    string `json:"password"`
@@ -144,35 +190,49 @@ func SetupAPIRoutes(r *gin.Engine) {
 					c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱和手机号不能为空"})
 					return
 				}
+				if !validEmail(req.Email) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱格式不正确"})
+					return
+				}
 
-				userStore.Lock()
-				defer userStore.Unlock()
-				if _, exists := userStore.data[req.Username]; exists {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+				passwordHash, err := hashPassword(req.Password)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "保存用户失败"})
+					return
+				}
+				uuid, err := generateUUID()
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "保存用户失败"})
 					return
 				}
 
 				now := time.Now().Format("2006-01-02 15:04:05")
 				record := userRecord{
 					Username:     req.Username,
-					PasswordHash: hashPassword(req.Password),
+					PasswordHash: passwordHash,
 					Email:        req.Email,
 					Phone:        req.Phone,
-					UUID:         generateUUID(),
+					UUID:         uuid,
+					Role:         RoleUser,
 					CreatedAt:    now,
 					UpdatedAt:    now,
 				}
 
-				userStore.data[req.Username] = record
-				if err := persistUsersLocked(); err != nil {
-					delete(userStore.data, req.Username)
+				if err := dataStore.Create(store.BucketUsers, req.Username, record); err != nil {
+					if errors.Is(err, store.ErrExists) {
+						audit.LogAs(c, req.Username, auditRegister, req.Username, audit.ResultFailure, "用户名已存在")
+						c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+						return
+					}
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "保存用户失败"})
 					return
 				}
-				c.JSON(http.StatusOK, gin.H{"success": true, "message": "注册成功"})
+				audit.LogAs(c, req.Username, auditRegister, req.Username, audit.ResultSuccess, "")
+				sendMailAsync("邮箱验证", record, sendVerificationEmail)
+				c.JSON(http.StatusOK, gin.H{"success": true, "message": "注册成功，验证邮件已发送到您的邮箱"})
 			})
 
-			auth.POST("/login", func(c *gin.Context) {
+			auth.POST("/login", requirePasswordLogin(), func(c *gin.Context) {
 				var req struct {
 					Username    string `json:"username"`
 					Password    string `json:"password"`
@@ -184,42 +244,55 @@ func SetupAPIRoutes(r *gin.Engine) {
 					return
 				}
 
+				// 锁定检查放在验证码之前，锁定期间不消耗验证码也不校验密码
+				clientIP := c.ClientIP()
+				if wait := checkLoginLockout(req.Username, clientIP); wait > 0 {
+					audit.LogAs(c, req.Username, auditLogin, req.Username, audit.ResultDenied, "登录已锁定")
+					abortLockedOut(c, wait)
+					return
+				}
+
 				if !validateCaptcha(req.CaptchaID, req.CaptchaCode) {
+					recordLoginFailure("", clientIP)
+					audit.LogAs(c, req.Username, auditLogin, req.Username, audit.ResultFailure, "验证码错误")
 					c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误或已过期"})
 					return
 				}
 
-				userStore.Lock()
-				record, exists := userStore.data[req.Username]
-				userStore.Unlock()
-				if !exists || !verifyPassword(req.Password, record.PasswordHash) {
+				record, exists := getUser(req.Username)
+				if !checkUserPassword(record, exists, req.Password) {
+					recordLoginFailure(req.Username, clientIP)
+					audit.LogAs(c, req.Username, auditLogin, req.Username, audit.ResultFailure, "账号或密码错误")
 					c.JSON(http.StatusUnauthorized, gin.H{"error": "账号或密码错误"})
 					return
 				}
+				if record.Disabled {
+					audit.LogAs(c, req.Username, auditLogin, req.Username, audit.ResultDenied, "账号已禁用")
+					c.JSON(http.StatusForbidden, gin.H{"error": "账号已被禁用"})
+					return
+				}
+				// 没有邮箱的账号（如默认管理员）不受邮箱验证限制
+				if requireEmailVerification && record.Email != "" && !record.EmailVerified {
+					audit.LogAs(c, req.Username, auditLogin, req.Username, audit.ResultDenied, "邮箱未验证")
+					c.JSON(http.StatusForbidden, gin.H{"error": "邮箱未验证，请先点击验证邮件中的链接", "emailVerificationRequired": true})
+					return
+				}
 
-				token := generateToken()
-				saveToken(token, req.Username)
+				// 旧算法或旧参数的哈希在登录成功后透明升级
+				if passwordNeedsRehash(record.PasswordHash) {
+					rehashUserPassword(req.Username, req.Password, record.PasswordHash)
+				}
 
-				c.JSON(http.StatusOK, gin.H{
-					"success": true,
-					"token":   token,
-					"user":    req.Username,
-					"expires": time.Now().Add(tokenTTL).Format(time.RFC3339),
-				})
+				finishLogin(c, record, auditLogin)
 			})
 
-			auth.GET("/profile", func(c *gin.Context) {
-				token := extractToken(c)
-				username, ok := getTokenOwner(token)
-				if !ok {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-					return
-				}
-				c.JSON(http.StatusOK, gin.H{
-					"success":  true,
-					"username": username,
-				})
-			})
+			authed := auth.Group("", AuthRequired())
+			setupSessionRoutes(auth, authed)
+			setupTwoFactorRoutes(auth, authed)
+			setupRecoveryRoutes(auth)
+			setupOIDCRoutes(auth)
+			setupProfileRoutes(authed)
+			setupAPIKeyRoutes(authed)
 
 			auth.POST("/logout", func(c *gin.Context) {
 				token := extractToken(c)
@@ -227,17 +300,21 @@ func SetupAPIRoutes(r *gin.Engine) {
 					c.JSON(http.StatusBadRequest, gin.H{"error": "未提供令牌"})
 					return
 				}
-				deleteToken(token)
+				// 退出时吊销整个会话，刷新令牌随之失效
+				item, ok := lookupToken(token)
+				if ok {
+					audit.LogAs(c, item.Username, auditLogout, item.Username, audit.ResultSuccess, "")
+				}
+				if ok && item.SessionID != "" {
+					_ = revokeSession(item.SessionID)
+				} else {
+					_ = dataStore.Delete(store.BucketTokens, tokenKey(token))
+				}
 				c.JSON(http.StatusOK, gin.H{"success": true})
 			})
 
-			auth.POST("/change-password", func(c *gin.Context) {
-				token := extractToken(c)
-				username, ok := getTokenOwner(token)
-				if !ok {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-					return
-				}
+			authed.POST("/change-password", func(c *gin.Context) {
+				username := CurrentUser(c)
 
 				var req struct {
 					OldPassword string `json:"oldPassword"`
@@ -260,22 +337,30 @@ func SetupAPIRoutes(r *gin.Engine) {
 					return
 				}
 
-				userStore.Lock()
-				defer userStore.Unlock()
+				userMu.Lock()
+				defer userMu.Unlock()
 
-				record, exists := userStore.data[username]
+				record, exists := getUser(username)
 				if !exists || !verifyPassword(req.OldPassword, record.PasswordHash) {
+					audit.Log(c, auditPasswordChange, username, audit.ResultFailure, "原密码错误")
 					c.JSON(http.StatusUnauthorized, gin.H{"error": "原密码错误"})
 					return
 				}
 
-				record.PasswordHash = hashPassword(req.NewPassword)
+				passwordHash, err := hashPassword(req.NewPassword)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "保存新密码失败"})
+					return
+				}
+
+				record.PasswordHash = passwordHash
 				record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
-				userStore.data[username] = record
-				if err := persistUsersLocked(); err != nil {
+				if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "保存新密码失败"})
 					return
 				}
+				_ = deleteUserTokens(username)
+				audit.Log(c, auditPasswordChange, username, audit.ResultSuccess, "")
 
 				c.JSON(http.StatusOK, gin.H{
 					"success": true,
@@ -284,6 +369,14 @@ func SetupAPIRoutes(r *gin.Engine) {
 			})
 		}
 
+		// 管理员接口
+		admin := api.Group("/admin", AuthRequired(), RequirePermission(PermManageUsers))
+		setupAdminUserRoutes(admin)
+		setupAdminSettingsRoutes(admin)
+		setupAdminTwoFactorRoutes(admin)
+		setupAdminLockoutRoutes(admin)
+		setupAdminAuditRoutes(api)
+
 		// 获取服务器时间
 		api.GET("/time", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
@@ -492,97 +585,95 @@ func parseUint(s string, base int) uint64 {
 	return val
 }
 
-func ensureUserFile() error {
-	dir := filepath.Dir(userDataFile)
-	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return err
-		}
+// getUser 读取用户记录
+func getUser(username string) (userRecord, bool) {
+	var record userRecord
+	exists, err := dataStore.Get(store.BucketUsers, username, &record)
+	if err != nil {
+		log.Printf("读取用户 %s 失败: %v", username, err)
+		return userRecord{}, false
 	}
-	return nil
+	return record, exists
 }
 
-func loadUsersFromFile() {
-	if err := ensureUserFile(); err != nil {
-		return
-	}
-
-	userStore.Lock()
-	defer userStore.Unlock()
-
-	data, err := os.ReadFile(userDataFile)
+// initDefaultUsers 存储中没有任何用户时写入默认管理员账号；
+// 旧版本的admin账号没有角色字段，这里补上管理员角色
+func initDefaultUsers() error {
+	userMu.Lock()
+	defer userMu.Unlock()
+
+	empty := true
+	err := dataStore.ForEach(store.BucketUsers, func(key string, raw []byte) error {
+		empty = false
+		return nil
+	})
 	if err != nil {
-		// 初始化写入默认用户
-		_ = persistUsersLocked()
-		return
-	}
-
-	var users map[string]userRecord
-	if err := json.Unmarshal(data, &users); err != nil || len(users) == 0 {
-		_ = persistUsersLocked()
-		return
+		return err
 	}
 
-	userStore.data = users
-}
-
-func persistUsersLocked() error {
-	snapshot := make(map[string]userRecord, len(userStore.data))
-	for k, v := range userStore.data {
-		snapshot[k] = v
+	if !empty {
+		record, exists := getUser("admin")
+		if !exists || record.Role != "" {
+			return nil
+		}
+		record.Role = RoleAdmin
+		return dataStore.Put(store.BucketUsers, "admin", record)
 	}
 
-	bytesData, err := json.MarshalIndent(snapshot, "", "  ")
+	passwordHash, err := hashPassword("admin123")
 	if err != nil {
 		return err
 	}
+	return dataStore.Create(store.BucketUsers, "admin", userRecord{
+		Username:     "admin",
+		PasswordHash: passwordHash,
+		Role:         RoleAdmin,
+	})
+}
 
-	if err := ensureUserFile(); err != nil {
-		return err
+// rehashUserPassword 用当前算法重新计算用户密码哈希并保存。verifiedHash是登录时校验通过的哈希，
+// 期间密码被修改或重置时保留新密码，不用旧密码覆盖
+func rehashUserPassword(username, password, verifiedHash string) {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return
 	}
 
-	tmpFile := userDataFile + ".tmp"
-	if err := os.WriteFile(tmpFile, bytesData, 0644); err != nil {
-		return err
+	userMu.Lock()
+	defer userMu.Unlock()
+
+	record, exists := getUser(username)
+	if !exists || record.PasswordHash != verifiedHash {
+		return
+	}
+	record.PasswordHash = passwordHash
+	if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+		log.Printf("更新用户 %s 的密码哈希失败: %v", username, err)
 	}
-	return os.Rename(tmpFile, userDataFile)
 }
 
-func generateCaptcha() (string, string, error) {
-	code, err := randomString(4)
+// generateCaptcha 用配置的验证方式生成验证码，答案保存在服务端
+func generateCaptcha() (string, *captcha.Challenge, error) {
+	challenge, err := captchaProvider.Generate()
 	if err != nil {
-		return "", "", err
+		return "", nil, err
 	}
 
 	captchaID, err := randomString(16)
 	if err != nil {
-		return "", "", err
+		return "", nil, err
 	}
 
-	svg := fmt.Sprintf(`
-<svg xmlns="http://www.w3.org/2000/svg" width="120" height="40">
-	<defs>
-		<linearGradient id="g" x1="0" y1="0" x2="1" y2="1">
-			<stop offset="0%%" stop-color="#7f5af0"/>
-			<stop offset="100%%" stop-color="#2cb67d"/>
-		</linearGradient>
-	</defs>
-	<rect width="120" height="40" rx="6" fill="url(#g)"/>
-	<text x="50%%" y="55%%" font-family="Arial, sans-serif" font-size="20" fill="#fff" text-anchor="middle" letter-spacing="4" font-weight="700">%s</text>
-	<line x1="10" y1="10" x2="110" y2="12" stroke="#0ea5e9" stroke-width="2" opacity="0.6"/>
-	<line x1="15" y1="30" x2="105" y2="26" stroke="#ef4444" stroke-width="2" opacity="0.6"/>
-</svg>`, code)
-
-	imageData := "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg))
-
-	captchaStore.Lock()
-	captchaStore.data[captchaID] = captchaItem{
-		code:      strings.ToUpper(code),
-		expiresAt: time.Now().Add(captchaTTL),
+	err = captchaStore.Put(bucketCaptchas, captchaID, captchaItem{
+		Type:      challenge.Type,
+		Code:      challenge.Answer,
+		ExpiresAt: time.Now().Add(captchaTTL),
+	})
+	if err != nil {
+		return "", nil, err
 	}
-	captchaStore.Unlock()
 
-	return captchaID, imageData, nil
+	return captchaID, challenge, nil
 }
 
 func randomString(length int) (string, error) {
@@ -603,90 +694,55 @@ func validateCaptcha(id, code string) bool {
 		return false
 	}
 
-	captchaStore.Lock()
-	defer captchaStore.Unlock()
+	// 验证码只能使用一次，读取和删除需要原子完成
+	captchaMu.Lock()
+	defer captchaMu.Unlock()
 
-	item, exists := captchaStore.data[id]
-	if !exists {
+	var item captchaItem
+	exists, err := captchaStore.Get(bucketCaptchas, id, &item)
+	if err != nil || !exists {
 		return false
 	}
-	delete(captchaStore.data, id)
-
-	if time.Now().After(item.expiresAt) {
+	if err := captchaStore.Delete(bucketCaptchas, id); err != nil {
 		return false
 	}
 
-	return strings.EqualFold(item.code, code)
-}
-
-func hashPassword(password string) string {
-	sum := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(sum[:])
-}
-
-func verifyPassword(raw, hashed string) bool {
-	return hashPassword(raw) == hashed
-}
-
-func generateToken() string {
-	token, err := randomString(32)
-	if err != nil {
-		return fmt.Sprintf("%d", time.Now().UnixNano())
+	// 切换验证方式后，之前签发的验证码作废
+	if time.Now().After(item.ExpiresAt) || item.Type != captchaProvider.Type() {
+		return false
 	}
-	return token
-}
 
-func generateUUID() string {
-	u, err := randomString(32)
-	if err != nil {
-		return fmt.Sprintf("%d", time.Now().UnixNano())
-	}
-	return u
+	return captchaProvider.Verify(item.Code, code)
 }
 
-func saveToken(token, username string) {
-	tokenStore.Lock()
-	defer tokenStore.Unlock()
-
-	tokenStore.data[token] = authToken{
-		username:  username,
-		expiresAt: time.Now().Add(tokenTTL),
-	}
+// generateToken 生成访问令牌和刷新令牌，随机数不可用时返回错误，不退回到可猜测的值
+func generateToken() (string, error) {
+	return randomString(32)
 }
 
-func deleteToken(token string) {
-	tokenStore.Lock()
-	defer tokenStore.Unlock()
-	delete(tokenStore.data, token)
+// generateUUID 生成用户的UUID
+func generateUUID() (string, error) {
+	return randomString(32)
 }
 
-func getTokenOwner(token string) (string, bool) {
-	if token == "" {
-		return "", false
-	}
-
-	tokenStore.Lock()
-	defer tokenStore.Unlock()
-
-	item, exists := tokenStore.data[token]
-	if !exists {
-		return "", false
+// extractToken 读取请求携带的令牌。X-Auth-Token和X-API-Key优先于Authorization，
+// 这样透传代理可以把Authorization原样转发给上游
+func extractToken(c *gin.Context) string {
+	token := c.GetHeader("X-Auth-Token")
+	if token != "" {
+		return token
 	}
-	if time.Now().After(item.expiresAt) {
-		delete(tokenStore.data, token)
-		return "", false
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
 	}
-	return item.username, true
-}
-
-func extractToken(c *gin.Context) string {
 	authHeader := c.GetHeader("Authorization")
 	if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
 		return strings.TrimSpace(authHeader[7:])
 	}
-	token := c.GetHeader("X-Auth-Token")
-	if token != "" {
-		return token
+	// 浏览器的WebSocket无法自定义请求头，握手时从子协议中读取令牌；
+	// 不接受查询参数中的令牌，避免令牌被写入访问日志
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		return websocketToken(c.Request)
 	}
 	return ""
 }
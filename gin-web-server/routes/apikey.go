@@ -0,0 +1,313 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+)
+
+// 个人API密钥：供脚本和CI调用受保护的工具接口，按作用域限制可访问的功能，不能访问账号和管理接口。
+// 密钥只在创建时返回一次，存储中以SHA-256摘要为键，另存前缀便于用户辨认。
+const (
+	bucketAPIKeys = "api_keys"
+
+	apiKeyPrefix     = "lfk_"
+	apiKeyMaxPerUser = 20
+
+	// contextAPIKeyScopes API密钥的作用域在gin.Context中的键，会话令牌登录时不存在
+	contextAPIKeyScopes = "authAPIKeyScopes"
+)
+
+// apiKeyScopes API密钥可申请的作用域，与权限名称一致
+var apiKeyScopes = []string{PermProxy, PermPortScan, PermQRCode}
+
+var apiKeyMu sync.Mutex
+
+// apiKey 已创建的API密钥
+type apiKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Username   string     `json:"username"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`  // 为nil时永不过期
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"` // 为nil时从未使用
+}
+
+// expiresAt 密钥的过期时间，永不过期时返回false。旧版本把永不过期保存为零值时间，同样视为永不过期
+func (k apiKey) expiresAt() (time.Time, bool) {
+	if k.ExpiresAt == nil || k.ExpiresAt.IsZero() {
+		return time.Time{}, false
+	}
+	return *k.ExpiresAt, true
+}
+
+func (k apiKey) expired(now time.Time) bool {
+	at, ok := k.expiresAt()
+	return ok && now.After(at)
+}
+
+func (k apiKey) view() gin.H {
+	item := gin.H{
+		"id":         k.ID,
+		"name":       k.Name,
+		"prefix":     k.Prefix,
+		"scopes":     k.Scopes,
+		"createdAt":  k.CreatedAt.Format(time.RFC3339),
+		"expiresAt":  nil,
+		"lastUsedAt": nil,
+		"expired":    k.expired(time.Now()),
+	}
+	if at, ok := k.expiresAt(); ok {
+		item["expiresAt"] = at.Format(time.RFC3339)
+	}
+	if k.LastUsedAt != nil && !k.LastUsedAt.IsZero() {
+		item["lastUsedAt"] = k.LastUsedAt.Format(time.RFC3339)
+	}
+	return item
+}
+
+// apiKeyViews 用户的全部API密钥，按创建时间从新到旧排列，不含密钥本身和哈希
+func apiKeyViews(username string) ([]gin.H, error) {
+	keys, err := listAPIKeys(username)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]apiKey, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+
+	items := make([]gin.H, 0, len(sorted))
+	for _, key := range sorted {
+		items = append(items, key.view())
+	}
+	return items, nil
+}
+
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// lookupAPIKey 校验API密钥，并按间隔更新最近使用时间
+func lookupAPIKey(token string) (authToken, bool) {
+	apiKeyMu.Lock()
+	defer apiKeyMu.Unlock()
+
+	var key apiKey
+	exists, err := dataStore.Get(bucketAPIKeys, tokenKey(token), &key)
+	now := time.Now()
+	if err != nil || !exists || key.expired(now) {
+		return authToken{}, false
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= sessionTouchInterval {
+		key.LastUsedAt = &now
+		if err := dataStore.Put(bucketAPIKeys, tokenKey(token), key); err != nil {
+			log.Printf("[AUTH] 更新API密钥 %s 使用时间失败: %v", key.ID, err)
+		}
+	}
+
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return authToken{Username: key.Username, scopes: scopes}, true
+}
+
+// listAPIKeys 列出用户的API密钥，返回存储键到密钥的映射
+func listAPIKeys(username string) (map[string]apiKey, error) {
+	keys := make(map[string]apiKey)
+	err := dataStore.ForEach(bucketAPIKeys, func(storeKey string, raw []byte) error {
+		var key apiKey
+		if json.Unmarshal(raw, &key) == nil && key.Username == username {
+			keys[storeKey] = key
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// deleteUserAPIKeys 删除用户的全部API密钥
+func deleteUserAPIKeys(username string) error {
+	apiKeyMu.Lock()
+	defer apiKeyMu.Unlock()
+
+	keys, err := listAPIKeys(username)
+	if err != nil {
+		return err
+	}
+	for storeKey := range keys {
+		if err := dataStore.Delete(bucketAPIKeys, storeKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apiKeyScopeAllowed 判断API密钥是否具备指定作用域，会话令牌不受作用域限制
+func apiKeyScopeAllowed(c *gin.Context, perm string) bool {
+	value, ok := c.Get(contextAPIKeyScopes)
+	if !ok {
+		return true
+	}
+	for _, scope := range value.([]string) {
+		if scope == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func usingAPIKey(c *gin.Context) bool {
+	_, ok := c.Get(contextAPIKeyScopes)
+	return ok
+}
+
+// setupAPIKeyRoutes 当前用户的API密钥管理接口，只能用登录会话访问
+func setupAPIKeyRoutes(authed *gin.RouterGroup) {
+	authed.GET("/api-keys", func(c *gin.Context) {
+		items, err := apiKeyViews(CurrentUser(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取API密钥失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "apiKeys": items, "availableScopes": apiKeyScopes})
+	})
+
+	authed.POST("/api-keys", func(c *gin.Context) {
+		var req struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+			// ExpiresIn 有效期，如"720h"；为空表示永不过期
+			ExpiresIn string `json:"expiresIn"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len([]rune(req.Name)) > 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "名称不能为空且不超过64个字符"})
+			return
+		}
+		if len(req.Scopes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "至少选择一个作用域"})
+			return
+		}
+		role := CurrentRole(c)
+		scopes := make([]string, 0, len(req.Scopes))
+		for _, scope := range req.Scopes {
+			if !containsString(apiKeyScopes, scope) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的作用域: " + scope})
+				return
+			}
+			// 密钥的权限不能超出用户角色的权限
+			if !hasPermission(role, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "当前角色没有权限: " + scope})
+				return
+			}
+			if !containsString(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+
+		now := time.Now()
+		key := apiKey{
+			Name:      req.Name,
+			Username:  CurrentUser(c),
+			Scopes:    scopes,
+			CreatedAt: now,
+		}
+		if req.ExpiresIn != "" {
+			ttl, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || ttl <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "有效期格式无效，例如 720h"})
+				return
+			}
+			expiresAt := now.Add(ttl)
+			key.ExpiresAt = &expiresAt
+		}
+
+		id, err := randomString(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建API密钥失败"})
+			return
+		}
+		// 长期有效的凭据，随机数不可用时直接失败，不能退回到可猜测的值
+		token, err := randomString(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建API密钥失败"})
+			return
+		}
+		secret := apiKeyPrefix + token
+		key.ID = id
+		key.Prefix = secret[:len(apiKeyPrefix)+6]
+
+		apiKeyMu.Lock()
+		defer apiKeyMu.Unlock()
+
+		existing, err := listAPIKeys(key.Username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建API密钥失败"})
+			return
+		}
+		if len(existing) >= apiKeyMaxPerUser {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API密钥数量已达上限，请先删除不用的密钥"})
+			return
+		}
+		if err := dataStore.Put(bucketAPIKeys, tokenKey(secret), key); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建API密钥失败"})
+			return
+		}
+
+		log.Printf("[AUTH] 用户 %s 创建了API密钥 %s(%s)", key.Username, key.Name, key.Prefix)
+		audit.Log(c, auditAPIKeyCreate, key.Prefix, audit.ResultSuccess, key.Name+" "+strings.Join(key.Scopes, ","))
+		response := key.view()
+		response["key"] = secret
+		c.JSON(http.StatusOK, gin.H{"success": true, "apiKey": response})
+	})
+
+	authed.DELETE("/api-keys/:id", func(c *gin.Context) {
+		apiKeyMu.Lock()
+		defer apiKeyMu.Unlock()
+
+		keys, err := listAPIKeys(CurrentUser(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取API密钥失败"})
+			return
+		}
+		for storeKey, key := range keys {
+			if key.ID != c.Param("id") {
+				continue
+			}
+			if err := dataStore.Delete(bucketAPIKeys, storeKey); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "删除API密钥失败"})
+				return
+			}
+			audit.Log(c, auditAPIKeyDelete, key.Prefix, audit.ResultSuccess, key.Name)
+			c.JSON(http.StatusOK, gin.H{"success": true})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "API密钥不存在"})
+	})
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// createAPIKey 用登录令牌创建指定作用域的API密钥，返回完整密钥
+func createAPIKey(t *testing.T, token string, scopes ...string) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"name": "ci", "scopes": scopes})
+	w := serve(http.MethodPost, "/api/auth/api-keys", string(body), bearer(token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create API key status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		APIKey struct {
+			Key string `json:"key"`
+		} `json:"apiKey"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.APIKey.Key == "" {
+		t.Fatalf("create API key response: %s", w.Body.String())
+	}
+	return resp.APIKey.Key
+}
+
+func TestAPIKeyQRCodeScope(t *testing.T) {
+	token := loginAs(t, "qrcode-user", RoleUser)
+	qrcodeKey := createAPIKey(t, token, PermQRCode)
+	proxyKey := createAPIKey(t, token, PermProxy)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"session", bearer(token), http.StatusOK},
+		{"qrcode scope", http.Header{"X-Api-Key": {qrcodeKey}}, http.StatusOK},
+		{"qrcode scope as bearer", bearer(qrcodeKey), http.StatusOK},
+		{"other scope", http.Header{"X-Api-Key": {proxyKey}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(http.MethodGet, "/api/qrcode?text=hello", "", tt.header); w.Code != tt.want {
+				t.Errorf("GET /api/qrcode status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if w := serve(http.MethodPost, "/api/generate-qrcode", `{"text":"hello"}`, tt.header); w.Code != tt.want {
+				t.Errorf("POST /api/generate-qrcode status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// 账号接口只接受登录会话
+	if w := serve(http.MethodGet, "/api/auth/profile", "", http.Header{"X-Api-Key": {qrcodeKey}}); w.Code != http.StatusForbidden {
+		t.Errorf("profile with API key status = %d, want 403", w.Code)
+	}
+}
+
+// TestAPIKeyNeverExpires 没有有效期的密钥返回expiresAt为null，存储中也不保存零值时间
+func TestAPIKeyNeverExpires(t *testing.T) {
+	token := loginAs(t, "apikey-expiry", RoleUser)
+	createAPIKey(t, token, PermProxy)
+	w := serve(http.MethodPost, "/api/auth/api-keys", `{"name":"temp","scopes":["proxy"],"expiresIn":"1h"}`, bearer(token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create API key status = %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve(http.MethodGet, "/api/auth/api-keys", "", bearer(token))
+	var resp struct {
+		APIKeys []map[string]interface{} `json:"apiKeys"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.APIKeys) != 2 {
+		t.Fatalf("list API keys: %s", w.Body.String())
+	}
+	for _, key := range resp.APIKeys {
+		expiresAt, ok := key["expiresAt"]
+		switch {
+		case !ok:
+			t.Errorf("%s has no expiresAt", key["name"])
+		case key["name"] == "ci" && expiresAt != nil:
+			t.Errorf("expiresAt of a key without expiry = %v, want null", expiresAt)
+		case key["name"] == "temp" && expiresAt == nil:
+			t.Error("expiresAt of a key with expiry is null")
+		}
+	}
+
+	err := dataStore.ForEach(bucketAPIKeys, func(key string, raw []byte) error {
+		if strings.Contains(string(raw), "0001-01-01") {
+			t.Errorf("stored API key has a zero time: %s", raw)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
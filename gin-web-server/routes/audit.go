@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+)
+
+// 审计日志的操作类型，按"模块.操作"命名，查询时可按前缀过滤，如action=auth.
+const (
+	auditLogin            = "auth.login"
+	auditLogin2FA         = "auth.login.2fa"
+	auditLogout           = "auth.logout"
+	auditRegister         = "auth.register"
+	auditPasswordChange   = "auth.password.change"
+	auditPasswordForgot   = "auth.password.forgot"
+	auditPasswordReset    = "auth.password.reset"
+	auditEmailVerify      = "auth.email.verify"
+	auditEmailResend      = "auth.email.resend"
+	auditTwoFactorEnable  = "auth.2fa.enable"
+	auditTwoFactorDisable = "auth.2fa.disable"
+	auditRecoveryCodes    = "auth.2fa.recovery-codes"
+	auditSSOLogin         = "auth.sso"
+	auditSessionRevoke    = "auth.session.revoke"
+
+	auditProfileUpdate = "account.profile.update"
+	auditProfileExport = "account.export"
+	auditAccountDelete = "account.delete"
+	auditAPIKeyCreate  = "account.apikey.create"
+	auditAPIKeyDelete  = "account.apikey.delete"
+
+	auditUserCreate        = "admin.user.create"
+	auditUserUpdate        = "admin.user.update"
+	auditUserDelete        = "admin.user.delete"
+	auditUserResetPassword = "admin.user.reset-password"
+	auditUserReset2FA      = "admin.user.reset-2fa"
+	auditLockoutClear      = "admin.lockout.clear"
+	auditSettingsUpdate    = "admin.settings.update"
+
+	auditAccessDenied = "access.denied"
+)
+
+const (
+	auditDefaultPageSize = 50
+	auditMaxPageSize     = 500
+)
+
+// setupAdminAuditRoutes 审计日志查询接口，需要audit:read权限
+func setupAdminAuditRoutes(api *gin.RouterGroup) {
+	api.GET("/admin/audit", AuthRequired(), RequirePermission(PermAuditRead), func(c *gin.Context) {
+		filter := audit.Filter{
+			Actor:  c.Query("actor"),
+			Action: c.Query("action"),
+			Target: c.Query("target"),
+			IP:     c.Query("ip"),
+			Result: c.Query("result"),
+		}
+		for param, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + "须为RFC3339格式的时间，如2024-01-02T15:04:05+08:00"})
+				return
+			}
+			*dest = t
+		}
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page无效"})
+			return
+		}
+		pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(auditDefaultPageSize)))
+		if err != nil || pageSize < 1 || pageSize > auditMaxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pageSize须在1到" + strconv.Itoa(auditMaxPageSize) + "之间"})
+			return
+		}
+		// 页码过大时(page-1)*pageSize会溢出为负数
+		if page > math.MaxInt/pageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page无效"})
+			return
+		}
+		filter.Offset = (page - 1) * pageSize
+		filter.Limit = pageSize
+
+		events, total, err := audit.Query(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取审计日志失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"events":   events,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+		})
+	})
+}
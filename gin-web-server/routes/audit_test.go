@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAuditQueryPageBounds(t *testing.T) {
+	token := loginAs(t, "audit-admin", RoleAdmin)
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"page=1&pageSize=10", http.StatusOK},
+		{"page=100000", http.StatusOK},
+		{"page=0", http.StatusBadRequest},
+		{"page=-1", http.StatusBadRequest},
+		{"page=abc", http.StatusBadRequest},
+		{"page=" + strconv.Itoa(math.MaxInt) + "&pageSize=500", http.StatusBadRequest},
+		{"page=" + strconv.Itoa(math.MaxInt/2) + "&pageSize=3", http.StatusBadRequest},
+		{"pageSize=501", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := serve(http.MethodGet, "/api/admin/audit?"+tt.query, "", bearer(token)); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+// 登录信息在gin.Context中的键
+const (
+	contextUserKey    = "authUser"
+	contextSessionKey = "authSession"
+)
+
+// AuthRequired 返回登录校验中间件，可挂载到任意路由组上；只接受登录会话，API密钥不能访问账号和管理接口
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c) {
+			abortUnauthorized(c)
+			return
+		}
+		if usingAPIKey(c) {
+			audit.Log(c, auditAccessDenied, c.Request.Method+" "+c.Request.URL.Path, audit.ResultDenied, "API密钥不能访问账号和管理接口")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API密钥不能访问该接口"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAccess 返回登录和权限校验中间件，接受登录会话和包含perm作用域的API密钥。
+// 用于在代码中保护必须登录的路由组，不依赖可被配置覆盖的protectedRoutes
+func RequireAccess(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// ProtectRoutes已校验过时不再重复查找令牌
+		if CurrentUser(c) == "" && !authenticate(c) {
+			abortUnauthorized(c)
+			return
+		}
+		if !permitted(c, perm) {
+			abortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ProtectRoutes 返回按路由前缀校验登录和权限的中间件，用于保护在引擎上直接注册的路由
+func ProtectRoutes(cfg config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !matchRoutePrefix(path, cfg.ProtectedRoutes) {
+			c.Next()
+			return
+		}
+		// CORS预检请求不携带凭据，交给路由自己的CORS中间件应答（如透传代理），没有处理的路由返回404
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Next()
+			return
+		}
+		if !authenticate(c) {
+			abortUnauthorized(c)
+			return
+		}
+		scoped := false
+		for prefix, perm := range cfg.RoutePermissions {
+			if !matchRoutePrefix(path, []string{prefix}) {
+				continue
+			}
+			if !permitted(c, perm) {
+				abortForbidden(c)
+				return
+			}
+			scoped = true
+		}
+		// API密钥只能访问配置了权限的路由
+		if usingAPIKey(c) && !scoped {
+			abortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate 校验请求携带的令牌，成功时把用户名和角色写入上下文
+func authenticate(c *gin.Context) bool {
+	item, ok := lookupToken(extractToken(c))
+	if !ok {
+		return false
+	}
+	// JWT的用户和角色取自载荷，不查用户记录；禁用和删除用户会吊销其会话
+	role := item.role
+	if role == "" {
+		// 用户被删除或禁用后，已签发的令牌立即失效；角色取自用户记录，调整角色后立即生效
+		record, exists := getUser(item.Username)
+		if !exists || record.Disabled {
+			return false
+		}
+		role = record.roleName()
+	}
+	c.Set(contextUserKey, item.Username)
+	c.Set(contextSessionKey, item.SessionID)
+	c.Set(contextRoleKey, role)
+	audit.SetActor(c, item.Username)
+	if item.scopes != nil {
+		c.Set(contextAPIKeyScopes, item.scopes)
+	}
+	return true
+}
+
+// CurrentUser 获取AuthRequired写入的登录用户名
+func CurrentUser(c *gin.Context) string {
+	return c.GetString(contextUserKey)
+}
+
+// abortUnauthorized 统一的未登录响应
+func abortUnauthorized(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录或登录已过期"})
+}
+
+func matchRoutePrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix == "" {
+			continue
+		}
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/middleware"
+	"github.com/lf-web-tools/gin-web-server/netpolicy"
+)
+
+// TestProtectRoutesPassthroughCORS 透传代理的401和403响应带有CORS头，浏览器中的页面才能读到错误信息
+func TestProtectRoutesPassthroughCORS(t *testing.T) {
+	// 与main.go相同的挂载顺序，透传代理的处理函数替换为直接返回200
+	r := gin.New()
+	r.Use(middleware.PassthroughCORSHeaders(), ProtectRoutes(testConfig.Auth))
+	r.Group("/proxy/", middleware.CorsProxyMiddleware()).Any("/*url", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.POST("/cors-proxy", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	token := loginAs(t, "cors-user", RoleUser)
+	qrcodeKey := createAPIKey(t, token, PermQRCode)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header http.Header
+		want   int
+		cors   bool
+	}{
+		{"anonymous", http.MethodGet, "/proxy/https://example.com/", nil, http.StatusUnauthorized, true},
+		{"other scope", http.MethodGet, "/proxy/https://example.com/", http.Header{"X-Api-Key": {qrcodeKey}}, http.StatusForbidden, true},
+		{"session", http.MethodGet, "/proxy/https://example.com/", bearer(token), http.StatusOK, true},
+		{"preflight", http.MethodOptions, "/proxy/https://example.com/", http.Header{"Access-Control-Request-Method": {"POST"}}, http.StatusNoContent, true},
+		{"other route", http.MethodPost, "/cors-proxy", nil, http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			req.Header.Set("Origin", "https://app.example.com")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin") == "*"; got != tt.cors {
+				t.Errorf("Access-Control-Allow-Origin = %q, want CORS headers %v", w.Header().Get("Access-Control-Allow-Origin"), tt.cors)
+			}
+		})
+	}
+}
+
+// TestPassthroughRequiresLoginWithoutProtectedRoutes 配置的protectedRoutes中没有/proxy时，透传代理仍须登录，
+// 转发的上游响应禁止类型猜测并以沙箱方式加载
+func TestPassthroughRequiresLoginWithoutProtectedRoutes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Security-Policy", "default-src *")
+		w.Write([]byte("<script>alert(1)</script>"))
+	}))
+	defer upstream.Close()
+	policy, err := netpolicy.New(config.DestinationConfig{DestinationRule: config.DestinationRule{AllowPrivate: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	middleware.SetDestinationPolicy(policy)
+	middleware.SetAuthContext(CurrentUser, CurrentRole)
+
+	auth := testConfig.Auth
+	auth.ProtectedRoutes = []string{"/port-scan"}
+	r := gin.New()
+	r.Use(middleware.PassthroughCORSHeaders(), ProtectRoutes(auth))
+	middleware.RegisterCorsProxyRoutes(r, testConfig.Proxy, RequireAccess(PermProxy))
+
+	token := loginAs(t, "passthrough-user", RoleUser)
+	qrcodeKey := createAPIKey(t, token, PermQRCode)
+	proxyKey := createAPIKey(t, token, PermProxy)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"other scope", http.Header{"X-Api-Key": {qrcodeKey}}, http.StatusForbidden},
+		{"proxy scope", http.Header{"X-Api-Key": {proxyKey}}, http.StatusOK},
+		{"session", bearer(token), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/proxy/"+upstream.URL+"/page", nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+				t.Error("response has no CORS headers")
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options = %q", got)
+			}
+			if got := w.Header().Values("Content-Security-Policy"); len(got) != 1 || got[0] != "sandbox" {
+				t.Errorf("Content-Security-Policy = %q", got)
+			}
+		})
+	}
+}
+
+// TestPortScanRequiresLoginWithoutProtectedRoutes 配置的protectedRoutes中没有/port-scan时，端口扫描仍须登录和portscan权限
+func TestPortScanRequiresLoginWithoutProtectedRoutes(t *testing.T) {
+	auth := testConfig.Auth
+	auth.ProtectedRoutes = []string{"/proxy"}
+	r := gin.New()
+	r.Use(ProtectRoutes(auth))
+	middleware.RegisterPortScanRoutes(r, RequireAccess(PermPortScan))
+
+	token := loginAs(t, "portscan-user", RoleUser)
+	qrcodeKey := createAPIKey(t, token, PermQRCode)
+	scanKey := createAPIKey(t, token, PermPortScan)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"other scope", http.Header{"X-Api-Key": {qrcodeKey}}, http.StatusForbidden},
+		// 通过校验后由处理函数拒绝无效的请求体
+		{"portscan scope", http.Header{"X-Api-Key": {scanKey}}, http.StatusBadRequest},
+		{"session", bearer(token), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/port-scan", strings.NewReader("{"))
+			req.Header.Set("Content-Type", "application/json")
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestCurlProxyRequiresLoginWithoutProtectedRoutes protectedRoutes为空时，CURL代理仍须登录和proxy权限
+func TestCurlProxyRequiresLoginWithoutProtectedRoutes(t *testing.T) {
+	auth := testConfig.Auth
+	auth.ProtectedRoutes = nil
+	r := gin.New()
+	r.Use(ProtectRoutes(auth))
+	middleware.RegisterCorsProxyRoutes(r, testConfig.Proxy, RequireAccess(PermProxy))
+
+	token := loginAs(t, "curl-proxy-user", RoleUser)
+	qrcodeKey := createAPIKey(t, token, PermQRCode)
+	proxyKey := createAPIKey(t, token, PermProxy)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"other scope", http.Header{"X-Api-Key": {qrcodeKey}}, http.StatusForbidden},
+		// 通过校验后由处理函数拒绝无效的请求体
+		{"proxy scope", http.Header{"X-Api-Key": {proxyKey}}, http.StatusBadRequest},
+		{"session", bearer(token), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/cors-proxy", strings.NewReader("{"))
+			req.Header.Set("Content-Type", "application/json")
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/jwt"
+)
+
+// JWT模式下访问令牌为自包含的签名令牌，不写入令牌存储：校验签名、kid、iss和exp后，用户和角色直接取自载荷，
+// 使用相同密钥的其他实例不需要共享存储即可校验。访问令牌有效期不超过jwtMaxAccessTTL，
+// 吊销会话时会话ID记入吊销列表，列表在同一存储内立即生效，其他实例的令牌最迟在过期后失效；
+// 刷新令牌和会话仍保存在签发实例的存储中，刷新时重新读取用户记录中的角色。
+var (
+	jwtKeys   *jwt.KeySet
+	jwtIssuer string
+)
+
+// jwtMaxAccessTTL JWT访问令牌的最长有效期，配置的accessTokenTTL超过时按此值签发
+const jwtMaxAccessTTL = 15 * time.Minute
+
+// accessClaims JWT访问令牌的载荷
+type accessClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+// loadJWTKeys 按配置加载签名和验签密钥
+func loadJWTKeys(cfg config.JWTConfig) (*jwt.KeySet, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("JWT模式需要至少配置一个密钥")
+	}
+
+	keys := make([]*jwt.Key, 0, len(cfg.Keys))
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, errors.New("JWT密钥缺少kid")
+		}
+
+		switch {
+		case strings.EqualFold(kc.Algorithm, jwt.HS256):
+			if len(kc.Secret) < 32 {
+				return nil, fmt.Errorf("密钥 %s 的secret长度不能少于32字节", kc.ID)
+			}
+			keys = append(keys, jwt.NewHMACKey(kc.ID, []byte(kc.Secret)))
+		case kc.PrivateKeyFile != "":
+			private, err := jwt.LoadPrivateKeyFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("读取密钥 %s 失败: %v", kc.ID, err)
+			}
+			key, err := jwt.NewSigningKey(kc.ID, private)
+			if err != nil {
+				return nil, fmt.Errorf("密钥 %s 无效: %v", kc.ID, err)
+			}
+			keys = append(keys, key)
+		case kc.PublicKeyFile != "":
+			public, err := jwt.LoadPublicKeyFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("读取密钥 %s 失败: %v", kc.ID, err)
+			}
+			key, err := jwt.NewVerifyKey(kc.ID, public)
+			if err != nil {
+				return nil, fmt.Errorf("密钥 %s 无效: %v", kc.ID, err)
+			}
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("密钥 %s 缺少secret或密钥文件", kc.ID)
+		}
+	}
+
+	signingKid := cfg.SigningKey
+	if signingKid == "" {
+		signingKid = cfg.Keys[0].ID
+	}
+	return jwt.NewKeySet(signingKid, keys...)
+}
+
+// signAccessJWT 为会话签发JWT访问令牌
+func signAccessJWT(sess *session, role string, expiresAt time.Time) (string, error) {
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+	return jwtKeys.Sign(accessClaims{
+		Issuer:    jwtIssuer,
+		Subject:   sess.Username,
+		Role:      role,
+		SessionID: sess.ID,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expiresAt.Unix(),
+		ID:        jti,
+	})
+}
+
+// verifyAccessJWT 校验JWT访问令牌
+func verifyAccessJWT(token string) (authToken, bool) {
+	var claims accessClaims
+	if err := jwtKeys.Verify(token, &claims); err != nil {
+		return authToken{}, false
+	}
+	if claims.Issuer != jwtIssuer || claims.Subject == "" || claims.SessionID == "" || claims.Role == "" {
+		return authToken{}, false
+	}
+	return authToken{
+		Username:  claims.Subject,
+		SessionID: claims.SessionID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		role:      claims.Role,
+	}, true
+}
+
+// handleJWKS 公开当前的非对称验签公钥
+func handleJWKS(c *gin.Context) {
+	if jwtKeys == nil {
+		c.JSON(http.StatusOK, jwt.JWKS{Keys: []jwt.JWK{}})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwtKeys.JWKS())
+}
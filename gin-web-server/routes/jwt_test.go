@@ -0,0 +1,160 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/jwt"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// enableJWT 切换为JWT访问令牌，测试结束后恢复为随机令牌
+func enableJWT(t *testing.T) {
+	t.Helper()
+	keys, err := jwt.NewKeySet("test", jwt.NewHMACKey("test", []byte(strings.Repeat("k", 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtKeys, jwtIssuer = keys, "lf-web-tools-test"
+	t.Cleanup(func() { jwtKeys, jwtIssuer = nil, "" })
+}
+
+func TestJWTRevocation(t *testing.T) {
+	enableJWT(t)
+
+	tests := []struct {
+		name   string
+		revoke func(t *testing.T, username, token string)
+	}{
+		{"logout", func(t *testing.T, username, token string) {
+			if w := serve(http.MethodPost, "/api/auth/logout", "", bearer(token)); w.Code != http.StatusOK {
+				t.Fatalf("logout status = %d", w.Code)
+			}
+		}},
+		{"revoke all sessions", func(t *testing.T, username, token string) {
+			if err := deleteUserTokens(username); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"disable user", func(t *testing.T, username, token string) {
+			admin := loginAs(t, "jwt-revoker", RoleAdmin)
+			w := serve(http.MethodPatch, "/api/admin/users/"+username, `{"disabled":true}`, bearer(admin))
+			if w.Code != http.StatusOK {
+				t.Fatalf("disable status = %d: %s", w.Code, w.Body.String())
+			}
+		}},
+		{"delete user", func(t *testing.T, username, token string) {
+			admin := loginAs(t, "jwt-revoker", RoleAdmin)
+			if w := serve(http.MethodDelete, "/api/admin/users/"+username, "", bearer(admin)); w.Code != http.StatusOK {
+				t.Fatalf("delete status = %d: %s", w.Code, w.Body.String())
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username := "jwt-" + strings.ReplaceAll(tt.name, " ", "-")
+			token := loginAs(t, username, RoleUser)
+			if !jwt.LooksLikeJWT(token) {
+				t.Fatalf("access token is not a JWT: %s", token)
+			}
+			if w := serve(http.MethodGet, "/api/auth/profile", "", bearer(token)); w.Code != http.StatusOK {
+				t.Fatalf("profile status = %d: %s", w.Code, w.Body.String())
+			}
+			tt.revoke(t, username, token)
+			if w := serve(http.MethodGet, "/api/auth/profile", "", bearer(token)); w.Code != http.StatusUnauthorized {
+				t.Errorf("profile after %s status = %d, want 401", tt.name, w.Code)
+			}
+		})
+	}
+}
+
+func TestJWTRoleChangeRevokesSessions(t *testing.T) {
+	enableJWT(t)
+	token := loginAs(t, "jwt-demoted", RoleAdmin)
+	if w := serve(http.MethodGet, "/api/admin/users", "", bearer(token)); w.Code != http.StatusOK {
+		t.Fatalf("admin users status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// 令牌载荷中的role仍为admin，降级时吊销会话，旧令牌随即失效
+	admin := loginAs(t, "jwt-role-admin", RoleAdmin)
+	w := serve(http.MethodPatch, "/api/admin/users/jwt-demoted", `{"role":"user"}`, bearer(admin))
+	if w.Code != http.StatusOK {
+		t.Fatalf("demote status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/api/admin/users", "", bearer(token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("admin users after demotion status = %d, want 401", w.Code)
+	}
+}
+
+// TestJWTAcceptedByOtherInstance 两个实例各用自己的存储、共用签名密钥，一个实例签发的令牌在另一个实例上有效，
+// 用户和角色取自载荷；签发实例上吊销会话后令牌在签发实例上立即失效
+func TestJWTAcceptedByOtherInstance(t *testing.T) {
+	enableJWT(t)
+	token := loginAs(t, "jwt-instance-a", RoleAdmin)
+
+	// 实例B：空的存储，只有需要权限的路由
+	instanceB := gin.New()
+	instanceB.GET("/whoami", RequireAccess(PermManageUsers), func(c *gin.Context) {
+		c.String(http.StatusOK, CurrentUser(c)+" "+CurrentRole(c))
+	})
+	storeA := dataStore
+	serveB := func() *httptest.ResponseRecorder {
+		dataStore = store.NewMemoryStore()
+		defer func() { dataStore = storeA }()
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		instanceB.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serveB()
+	if w.Code != http.StatusOK || w.Body.String() != "jwt-instance-a admin" {
+		t.Fatalf("instance B status = %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(http.MethodPost, "/api/auth/logout", "", bearer(token)); w.Code != http.StatusOK {
+		t.Fatalf("logout status = %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/api/auth/profile", "", bearer(token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("instance A after logout status = %d, want 401", w.Code)
+	}
+}
+
+func TestJWTRequiresExpiry(t *testing.T) {
+	enableJWT(t)
+	valid := loginAs(t, "jwt-expiry", RoleUser)
+	item, ok := lookupToken(valid)
+	if !ok {
+		t.Fatal("valid JWT was rejected")
+	}
+
+	sess := &session{ID: item.SessionID, Username: "jwt-expiry"}
+	for name, expiresAt := range map[string]time.Time{"expired": time.Now().Add(-time.Second), "no exp": {}} {
+		claims := accessClaims{Issuer: jwtIssuer, Subject: sess.Username, SessionID: sess.ID, IssuedAt: time.Now().Unix()}
+		if !expiresAt.IsZero() {
+			claims.ExpiresAt = expiresAt.Unix()
+		}
+		token, err := jwtKeys.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := serve(http.MethodGet, "/api/auth/profile", "", bearer(token)); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s token status = %d, want 401", name, w.Code)
+		}
+	}
+
+	// 其他签发者的令牌不被接受
+	token, err := signAccessJWT(sess, RoleUser, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtIssuer = "other"
+	if w := serve(http.MethodGet, "/api/auth/profile", "", bearer(token)); w.Code != http.StatusUnauthorized {
+		t.Errorf("token from another issuer status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,232 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+// 登录防暴力破解：按账号和客户端IP分别记录失败次数，达到阈值后锁定并按指数退避延长锁定时长。
+// 账号计数在登录成功后清零；IP计数只随时间窗口过期，避免攻击者用自己的账号登录来重置计数。
+// 失败记录保存在存储中，重启后依然有效，管理员可查看和解除。
+const bucketLoginAttempts = "login_attempts"
+
+// 失败记录的主体类型，与值拼接为存储键，如 user:admin、ip:127.0.0.1
+const (
+	lockoutKindUser = "user"
+	lockoutKindIP   = "ip"
+)
+
+var (
+	lockoutConfig = config.Default().Auth.Lockout
+	lockoutMu     sync.Mutex
+)
+
+// loginAttempt 某个账号或IP的登录失败记录
+type loginAttempt struct {
+	Kind        string    `json:"kind"`
+	Value       string    `json:"value"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+func lockoutKey(kind, value string) string {
+	return kind + ":" + value
+}
+
+// expiresAt 记录可被清理的时间：锁定结束且计数窗口已过
+func (a loginAttempt) expiresAt() time.Time {
+	expires := a.LastFailure.Add(time.Duration(lockoutConfig.Window))
+	if a.LockedUntil.After(expires) {
+		return a.LockedUntil
+	}
+	return expires
+}
+
+func (a loginAttempt) retryAfter(now time.Time) time.Duration {
+	if now.Before(a.LockedUntil) {
+		return a.LockedUntil.Sub(now)
+	}
+	return 0
+}
+
+func (a loginAttempt) threshold() int {
+	if a.Kind == lockoutKindIP {
+		return lockoutConfig.IPThreshold
+	}
+	return lockoutConfig.UserThreshold
+}
+
+// lockoutDuration 达到阈值后第n次失败的锁定时长：base * 2^(n-1)，不超过上限
+func lockoutDuration(overThreshold int) time.Duration {
+	duration := time.Duration(lockoutConfig.BaseDuration)
+	limit := time.Duration(lockoutConfig.MaxDuration)
+	for i := 1; i < overThreshold && duration < limit; i++ {
+		duration *= 2
+	}
+	if duration > limit {
+		duration = limit
+	}
+	return duration
+}
+
+// loadLoginAttempt 读取失败记录，计数窗口已过的记录视为不存在
+func loadLoginAttempt(kind, value string, now time.Time) loginAttempt {
+	attempt := loginAttempt{Kind: kind, Value: value}
+	exists, err := dataStore.Get(bucketLoginAttempts, lockoutKey(kind, value), &attempt)
+	if err != nil {
+		log.Printf("[AUTH] 读取登录失败记录失败: %v", err)
+	}
+	if !exists || now.After(attempt.expiresAt()) {
+		return loginAttempt{Kind: kind, Value: value}
+	}
+	return attempt
+}
+
+// checkLoginLockout 返回账号或IP剩余的锁定时长，未锁定时返回0
+func checkLoginLockout(username, ip string) time.Duration {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+
+	now := time.Now()
+	wait := loadLoginAttempt(lockoutKindIP, ip, now).retryAfter(now)
+	if username != "" {
+		if userWait := loadLoginAttempt(lockoutKindUser, username, now).retryAfter(now); userWait > wait {
+			wait = userWait
+		}
+	}
+	return wait
+}
+
+// recordLoginFailure 记录一次失败，username为空时只计入IP（如验证码错误）
+func recordLoginFailure(username, ip string) {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+
+	now := time.Now()
+	record := func(kind, value string) {
+		attempt := loadLoginAttempt(kind, value, now)
+		attempt.Failures++
+		attempt.LastFailure = now
+		if over := attempt.Failures - attempt.threshold() + 1; over > 0 {
+			attempt.LockedUntil = now.Add(lockoutDuration(over))
+			log.Printf("[AUTH] 登录失败次数过多，锁定 %s %s 至 %s", kind, value, attempt.LockedUntil.Format(time.RFC3339))
+		}
+		if err := dataStore.Put(bucketLoginAttempts, lockoutKey(kind, value), attempt); err != nil {
+			log.Printf("[AUTH] 保存登录失败记录失败: %v", err)
+		}
+	}
+
+	record(lockoutKindIP, ip)
+	if username != "" {
+		record(lockoutKindUser, username)
+	}
+}
+
+// clearUserLockout 登录成功后清除账号的失败计数
+func clearUserLockout(username string) {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+	_ = dataStore.Delete(bucketLoginAttempts, lockoutKey(lockoutKindUser, username))
+}
+
+// abortLockedOut 统一的锁定响应，带Retry-After头
+func abortLockedOut(c *gin.Context, wait time.Duration) {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":      fmt.Sprintf("登录失败次数过多，请在%d秒后重试", seconds),
+		"retryAfter": seconds,
+	})
+}
+
+// sweepLoginAttempts 清理已过期的失败记录
+func sweepLoginAttempts() {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+
+	now := time.Now()
+	err := dataStore.ForEach(bucketLoginAttempts, func(key string, raw []byte) error {
+		var attempt loginAttempt
+		if json.Unmarshal(raw, &attempt) == nil && now.Before(attempt.expiresAt()) {
+			return nil
+		}
+		return dataStore.Delete(bucketLoginAttempts, key)
+	})
+	if err != nil {
+		log.Printf("[AUTH] 清理过期数据失败(%s): %v", bucketLoginAttempts, err)
+	}
+}
+
+// setupAdminLockoutRoutes 管理员查看和解除登录锁定
+func setupAdminLockoutRoutes(admin *gin.RouterGroup) {
+	admin.GET("/lockouts", func(c *gin.Context) {
+		type lockoutView struct {
+			loginAttempt
+			Locked     bool `json:"locked"`
+			RetryAfter int  `json:"retryAfter"`
+		}
+
+		now := time.Now()
+		items := make([]lockoutView, 0)
+		lockoutMu.Lock()
+		err := dataStore.ForEach(bucketLoginAttempts, func(key string, raw []byte) error {
+			var attempt loginAttempt
+			if err := json.Unmarshal(raw, &attempt); err != nil {
+				return err
+			}
+			if now.After(attempt.expiresAt()) {
+				return nil
+			}
+			wait := attempt.retryAfter(now)
+			items = append(items, lockoutView{
+				loginAttempt: attempt,
+				Locked:       wait > 0,
+				RetryAfter:   int(wait.Seconds()),
+			})
+			return nil
+		})
+		lockoutMu.Unlock()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取锁定列表失败"})
+			return
+		}
+
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].LastFailure.After(items[j].LastFailure)
+		})
+		c.JSON(http.StatusOK, gin.H{"success": true, "lockouts": items})
+	})
+
+	// DELETE /lockouts/user/admin 或 /lockouts/ip/127.0.0.1
+	admin.DELETE("/lockouts/:kind/*value", func(c *gin.Context) {
+		kind := c.Param("kind")
+		value := strings.TrimPrefix(c.Param("value"), "/")
+		if (kind != lockoutKindUser && kind != lockoutKindIP) || value == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		lockoutMu.Lock()
+		err := dataStore.Delete(bucketLoginAttempts, lockoutKey(kind, value))
+		lockoutMu.Unlock()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "解除锁定失败"})
+			return
+		}
+		log.Printf("[AUTH] 管理员 %s 解除了 %s %s 的登录锁定", CurrentUser(c), kind, value)
+		audit.Log(c, auditLockoutClear, kind+":"+value, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// withLockoutConfig 测试期间替换锁定配置
+func withLockoutConfig(t *testing.T, cfg config.LockoutConfig) {
+	t.Helper()
+	previous := lockoutConfig
+	lockoutConfig = cfg
+	t.Cleanup(func() { lockoutConfig = previous })
+	resetLockouts(t)
+}
+
+func TestLockoutDuration(t *testing.T) {
+	withLockoutConfig(t, config.Default().Auth.Lockout)
+	want := []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour, time.Hour,
+	}
+	for i, d := range want {
+		if got := lockoutDuration(i + 1); got != d {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", i+1, got, d)
+		}
+	}
+}
+
+// lockedFor 记录的剩余锁定时长，取整到分钟
+func lockedFor(kind, value string) time.Duration {
+	now := time.Now()
+	return loadLoginAttempt(kind, value, now).retryAfter(now).Round(time.Minute)
+}
+
+func TestUserLockoutBackoff(t *testing.T) {
+	withLockoutConfig(t, config.LockoutConfig{
+		UserThreshold: 3,
+		IPThreshold:   100,
+		BaseDuration:  config.Duration(time.Minute),
+		MaxDuration:   config.Duration(4 * time.Minute),
+		Window:        config.Duration(15 * time.Minute),
+	})
+
+	// 每次失败来自不同IP，只有账号计数达到阈值
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for i, d := range want {
+		recordLoginFailure("backoff-user", fmt.Sprintf("198.51.100.%d", i+1))
+		if got := lockedFor(lockoutKindUser, "backoff-user"); got != d {
+			t.Errorf("after %d failures locked for %v, want %v", i+1, got, d)
+		}
+	}
+	if wait := checkLoginLockout("backoff-user", "203.0.113.1"); wait <= 0 {
+		t.Error("locked account accepted from another IP")
+	}
+	if wait := checkLoginLockout("other-user", "198.51.100.1"); wait != 0 {
+		t.Errorf("other account locked for %v", wait)
+	}
+}
+
+func TestIPLockoutBackoff(t *testing.T) {
+	withLockoutConfig(t, config.LockoutConfig{
+		UserThreshold: 100,
+		IPThreshold:   2,
+		BaseDuration:  config.Duration(time.Minute),
+		MaxDuration:   config.Duration(time.Hour),
+		Window:        config.Duration(15 * time.Minute),
+	})
+
+	// 用户名为空表示只计入IP，如验证码错误
+	usernames := []string{"", "ip-a", "ip-b", "ip-c"}
+	want := []time.Duration{0, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, username := range usernames {
+		recordLoginFailure(username, "198.51.100.200")
+		if got := lockedFor(lockoutKindIP, "198.51.100.200"); got != want[i] {
+			t.Errorf("after %d failures IP locked for %v, want %v", i+1, got, want[i])
+		}
+	}
+	if wait := checkLoginLockout("ip-new", "198.51.100.200"); wait <= 0 {
+		t.Error("locked IP accepted with a new account")
+	}
+	if wait := checkLoginLockout("ip-new", "198.51.100.201"); wait != 0 {
+		t.Errorf("other IP locked for %v", wait)
+	}
+}
+
+func TestLockoutWindowExpires(t *testing.T) {
+	withLockoutConfig(t, config.Default().Auth.Lockout)
+	stale := loginAttempt{
+		Kind:        lockoutKindUser,
+		Value:       "window-user",
+		Failures:    4,
+		LastFailure: time.Now().Add(-time.Duration(lockoutConfig.Window) - time.Second),
+	}
+	if err := dataStore.Put(bucketLoginAttempts, lockoutKey(stale.Kind, stale.Value), stale); err != nil {
+		t.Fatal(err)
+	}
+	// 计数窗口已过，下一次失败重新从1开始计数
+	recordLoginFailure("window-user", "198.51.100.50")
+	if attempt := loadLoginAttempt(lockoutKindUser, "window-user", time.Now()); attempt.Failures != 1 || !attempt.LockedUntil.IsZero() {
+		t.Errorf("attempt = %+v, want 1 failure and no lock", attempt)
+	}
+}
+
+func TestLoginSuccessClearsUserLockout(t *testing.T) {
+	withLockoutConfig(t, config.LockoutConfig{
+		UserThreshold: 3,
+		IPThreshold:   100,
+		BaseDuration:  config.Duration(time.Minute),
+		MaxDuration:   config.Duration(time.Hour),
+		Window:        config.Duration(15 * time.Minute),
+	})
+	hash, err := hashPassword("Right-pass-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dataStore.Put(store.BucketUsers, "lockout-user", userRecord{Username: "lockout-user", PasswordHash: hash, Role: RoleUser}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := login(t, "lockout-user", "wrong-pass"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password status = %d", code)
+		}
+	}
+	if code := login(t, "lockout-user", "Right-pass-1"); code != http.StatusOK {
+		t.Fatalf("login status = %d", code)
+	}
+	if attempt := loadLoginAttempt(lockoutKindUser, "lockout-user", time.Now()); attempt.Failures != 0 {
+		t.Errorf("account failures after login = %d, want 0", attempt.Failures)
+	}
+	// IP计数不因登录成功清零，防止攻击者用自己的账号重置计数
+	if attempt := loadLoginAttempt(lockoutKindIP, "192.0.2.1", time.Now()); attempt.Failures != 2 {
+		t.Errorf("IP failures after login = %d, want 2", attempt.Failures)
+	}
+
+	// 锁定期间正确的密码也不能登录
+	for i := 0; i < 3; i++ {
+		login(t, "lockout-user", "wrong-pass")
+	}
+	if code := login(t, "lockout-user", "Right-pass-1"); code != http.StatusTooManyRequests {
+		t.Errorf("login while locked status = %d, want 429", code)
+	}
+}
+
+// TestSecondFactorRoutesLockout 令牌泄露时不能在重新生成恢复码和关闭两步验证的接口上暴力尝试动态码
+func TestSecondFactorRoutesLockout(t *testing.T) {
+	withLockoutConfig(t, config.LockoutConfig{
+		UserThreshold: 3,
+		IPThreshold:   100,
+		BaseDuration:  config.Duration(time.Minute),
+		MaxDuration:   config.Duration(time.Minute),
+		Window:        config.Duration(15 * time.Minute),
+	})
+
+	for _, path := range []string{"/api/auth/2fa/recovery-codes", "/api/auth/2fa/disable"} {
+		t.Run(path, func(t *testing.T) {
+			username := "lockout-2fa" + strings.ReplaceAll(path, "/", "-")
+			token := loginAs(t, username, RoleUser)
+			code := enableTOTPFor(t, username)
+			for i := 0; i < 3; i++ {
+				w := serve(http.MethodPost, path, `{"code":"000000"}`, bearer(token))
+				if w.Code == http.StatusOK || w.Code == http.StatusTooManyRequests {
+					t.Fatalf("wrong code %d status = %d", i+1, w.Code)
+				}
+			}
+			// 锁定后正确的动态码也被拒绝
+			if w := serve(http.MethodPost, path, `{"code":"`+code+`"}`, bearer(token)); w.Code != http.StatusTooManyRequests {
+				t.Errorf("correct code after lockout status = %d, want 429", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// testRouter 按默认配置注册了全部认证接口，数据保存在内存中
+var (
+	testRouter *gin.Engine
+	testConfig *config.Config
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	log.SetOutput(io.Discard)
+
+	dir, err := os.MkdirTemp("", "routes-test")
+	if err != nil {
+		panic(err)
+	}
+	testConfig = config.Default()
+	// 测试中使用最小的哈希参数，避免每次登录消耗64MiB内存
+	testConfig.Auth.Password = config.PasswordConfig{Memory: 1024, Iterations: 1, Parallelism: 1}
+	testConfig.Mail.Dir = filepath.Join(dir, "mail")
+
+	testRouter = gin.New()
+	testRouter.Use(ProtectRoutes(testConfig.Auth))
+	SetupAPIRoutes(testRouter, testConfig, store.NewMemoryStore())
+	SetupWebSocketRoutes(testRouter, []string{"https://allowed.example.com"})
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// serve 向testRouter发送请求，body非空时按JSON发送
+func serve(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+// loginAs 不经过验证码直接为用户创建会话（用户不存在时按角色创建），返回访问令牌
+func loginAs(t *testing.T, username, role string) string {
+	t.Helper()
+	if _, exists := getUser(username); !exists {
+		if err := dataStore.Put(store.BucketUsers, username, userRecord{Username: username, Role: role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	pair, err := createSession(c, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair.AccessToken
+}
+
+// bearer 携带令牌的请求头
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// resetLockouts 清空登录失败记录并在测试结束后再次清空，避免测试中的失败次数锁定后续测试的客户端IP
+func resetLockouts(t *testing.T) {
+	t.Helper()
+	deleteAll := func() {
+		_ = dataStore.ForEach(bucketLoginAttempts, func(key string, raw []byte) error {
+			return dataStore.Delete(bucketLoginAttempts, key)
+		})
+	}
+	deleteAll()
+	t.Cleanup(deleteAll)
+}
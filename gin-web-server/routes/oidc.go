@@ -0,0 +1,390 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/oidc"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// OpenID Connect单点登录：授权码+PKCE流程。浏览器从 /oidc/login 跳转到IdP，IdP回调 /oidc/callback，
+// 校验通过后跳回页面并携带一次性的ssoCode，页面用ssoCode调用 /oidc/exchange 换取普通的会话令牌。
+// 令牌不出现在地址栏中，换取时与密码登录一样遵守两步验证要求。
+const (
+	bucketOIDCStates = "oidc_states"
+	bucketOIDCLogins = "oidc_logins"
+
+	oidcStateTTL = 10 * time.Minute
+	oidcLoginTTL = time.Minute
+	oidcPagePath = "/static/index.html"
+	// oidcStateCookie 把state绑定到发起登录的浏览器，防止攻击者诱导用户完成攻击者发起的登录
+	oidcStateCookie = "oidc_state"
+)
+
+var (
+	oidcProvider *oidc.Provider
+	oidcConfig   config.OIDCConfig
+	// oidcStore 登录流程中的state和一次性ssoCode，生命周期短，保存在内存中
+	oidcStore store.Store = store.NewMemoryStore()
+
+	errOIDCNoGroup = errors.New("不在允许登录的分组中")
+
+	oidcUsernameInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// oidcState 跳转到IdP前保存的state，回调时校验并取出PKCE校验码和nonce
+type oidcState struct {
+	CodeVerifier string    `json:"codeVerifier"`
+	Nonce        string    `json:"nonce"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// oidcLogin 回调成功后等待页面换取令牌的登录
+type oidcLogin struct {
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// initOIDC 按配置创建身份提供方，未启用时单点登录接口返回404
+func initOIDC(cfg config.OIDCConfig) error {
+	oidcConfig = cfg
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = strings.TrimSuffix(mailBaseURL, "/") + "/api/auth/oidc/callback"
+	}
+	if _, ok := rolePermissions[cfg.DefaultRole]; !ok {
+		return errors.New("单点登录的默认角色不存在: " + cfg.DefaultRole)
+	}
+	for _, mapping := range cfg.GroupRoles {
+		if _, ok := rolePermissions[mapping.Role]; !ok {
+			return errors.New("单点登录分组映射的角色不存在: " + mapping.Role)
+		}
+	}
+	provider, err := oidc.New(cfg)
+	if err != nil {
+		return err
+	}
+	oidcProvider = provider
+	oidcConfig = cfg
+	return nil
+}
+
+// requirePasswordLogin 单点登录配置为唯一登录方式时，拒绝本地密码登录、注册和找回密码
+func requirePasswordLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if oidcConfig.Enabled && oidcConfig.DisablePasswordLogin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "已关闭密码登录，请使用" + oidcConfig.Name})
+			return
+		}
+		c.Next()
+	}
+}
+
+// oidcRedirect 跳回页面，结果通过查询参数传递
+func oidcRedirect(c *gin.Context, param, value string) {
+	c.Redirect(http.StatusFound, oidcPagePath+"?"+param+"="+url.QueryEscape(value))
+}
+
+// oidcRole 按分组映射计算角色，未配置映射时返回空字符串表示不修改
+func oidcRole(claims *oidc.Claims) (string, error) {
+	if len(oidcConfig.GroupRoles) == 0 {
+		if oidcConfig.RequireGroup {
+			return "", errOIDCNoGroup
+		}
+		return "", nil
+	}
+	groups := claims.Strings(oidcConfig.GroupsClaim)
+	for _, mapping := range oidcConfig.GroupRoles {
+		if containsString(groups, mapping.Group) {
+			return mapping.Role, nil
+		}
+	}
+	if oidcConfig.RequireGroup {
+		return "", errOIDCNoGroup
+	}
+	return oidcConfig.DefaultRole, nil
+}
+
+// oidcUsername 由IdP声明生成可用的本地用户名，与已有用户冲突时追加随机后缀
+func oidcUsername(claims *oidc.Claims) (string, error) {
+	name := claims.String(oidcConfig.UsernameClaim)
+	if name == "" {
+		name = strings.SplitN(claims.Email(), "@", 2)[0]
+	}
+	name = strings.Trim(oidcUsernameInvalid.ReplaceAllString(name, "-"), "-.")
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if len(name) < 3 {
+		name = "sso-" + name
+	}
+
+	candidate := name
+	for i := 0; i < 5; i++ {
+		if _, exists := getUser(candidate); !exists {
+			return candidate, nil
+		}
+		suffix, err := randomString(4)
+		if err != nil {
+			return "", err
+		}
+		candidate = name + "-" + strings.ToLower(suffix)
+	}
+	return "", errors.New("无法生成可用的用户名")
+}
+
+// resolveOIDCUser 按IdP的sub查找已关联的用户；首次登录时按邮箱关联已有用户或创建新用户，并同步分组角色
+func resolveOIDCUser(claims *oidc.Claims) (userRecord, error) {
+	role, err := oidcRole(claims)
+	if err != nil {
+		return userRecord{}, err
+	}
+	issuer, subject := claims.String("iss"), claims.Subject()
+
+	userMu.Lock()
+	defer userMu.Unlock()
+
+	var record userRecord
+	var found, linkable bool
+	err = dataStore.ForEach(store.BucketUsers, func(key string, raw []byte) error {
+		var item userRecord
+		if found || json.Unmarshal(raw, &item) != nil {
+			return nil
+		}
+		if item.OIDCIssuer == issuer && item.OIDCSubject == subject {
+			record, found, linkable = item, true, false
+			return nil
+		}
+		// 只关联尚未绑定其他IdP账号、且本地和IdP都验证过该邮箱的用户。
+		// 本地未验证的邮箱可能是他人抢先注册的，关联后双方将共用同一个账号
+		if oidcConfig.LinkByEmail && !linkable && item.OIDCSubject == "" && item.Email != "" && item.EmailVerified &&
+			claims.EmailVerified() && strings.EqualFold(item.Email, claims.Email()) {
+			record, linkable = item, true
+		}
+		return nil
+	})
+	if err != nil {
+		return userRecord{}, err
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	switch {
+	case found:
+	case linkable:
+		record.OIDCIssuer, record.OIDCSubject = issuer, subject
+		log.Printf("[AUTH] 用户 %s 通过邮箱关联了单点登录账号", record.Username)
+	default:
+		username, err := oidcUsername(claims)
+		if err != nil {
+			return userRecord{}, err
+		}
+		if role == "" {
+			role = oidcConfig.DefaultRole
+		}
+		uuid, err := generateUUID()
+		if err != nil {
+			return userRecord{}, err
+		}
+		record = userRecord{
+			Username:      username,
+			Email:         claims.Email(),
+			EmailVerified: claims.EmailVerified(),
+			UUID:          uuid,
+			Role:          role,
+			OIDCIssuer:    issuer,
+			OIDCSubject:   subject,
+			CreatedAt:     now,
+		}
+		log.Printf("[AUTH] 通过单点登录创建了用户 %s", username)
+	}
+
+	if role != "" && role != record.roleName() {
+		log.Printf("[AUTH] 按IdP分组将用户 %s 的角色从 %s 调整为 %s", record.Username, record.roleName(), role)
+		record.Role = role
+	} else if found {
+		return record, nil
+	}
+	record.UpdatedAt = now
+	if err := dataStore.Put(store.BucketUsers, record.Username, record); err != nil {
+		return userRecord{}, err
+	}
+	return record, nil
+}
+
+// setupOIDCRoutes 单点登录接口
+func setupOIDCRoutes(auth *gin.RouterGroup) {
+	// 页面据此决定是否显示单点登录按钮，未启用时也返回200
+	auth.GET("/oidc/config", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":       oidcConfig.Enabled,
+			"name":          oidcConfig.Name,
+			"passwordLogin": !(oidcConfig.Enabled && oidcConfig.DisablePasswordLogin),
+		})
+	})
+
+	sso := auth.Group("/oidc", func(c *gin.Context) {
+		if oidcProvider == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "未启用单点登录"})
+			return
+		}
+		c.Next()
+	})
+
+	sso.GET("/login", func(c *gin.Context) {
+		state, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+			return
+		}
+		nonce, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+			return
+		}
+		verifier, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+			return
+		}
+
+		authURL, err := oidcProvider.AuthCodeURL(c.Request.Context(), state, nonce, oidc.CodeChallenge(verifier))
+		if err != nil {
+			log.Printf("[AUTH] 发起单点登录失败: %v", err)
+			oidcRedirect(c, "ssoError", "身份提供方暂时不可用")
+			return
+		}
+		err = oidcStore.Put(bucketOIDCStates, tokenKey(state), oidcState{
+			CodeVerifier: verifier,
+			Nonce:        nonce,
+			ExpiresAt:    time.Now().Add(oidcStateTTL),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/api/auth/oidc", "", c.Request.TLS != nil, true)
+		c.Redirect(http.StatusFound, authURL)
+	})
+
+	sso.GET("/callback", func(c *gin.Context) {
+		state := c.Query("state")
+		cookie, _ := c.Cookie(oidcStateCookie)
+		c.SetCookie(oidcStateCookie, "", -1, "/api/auth/oidc", "", c.Request.TLS != nil, true)
+		var saved oidcState
+		exists, err := oidcStore.Get(bucketOIDCStates, tokenKey(state), &saved)
+		if state == "" || state != cookie || err != nil || !exists || time.Now().After(saved.ExpiresAt) {
+			oidcRedirect(c, "ssoError", "登录已过期，请重新登录")
+			return
+		}
+		// state只能使用一次
+		_ = oidcStore.Delete(bucketOIDCStates, tokenKey(state))
+
+		if idpError := c.Query("error"); idpError != "" {
+			log.Printf("[AUTH] 单点登录被IdP拒绝: %s %s", idpError, c.Query("error_description"))
+			audit.LogAs(c, "", auditSSOLogin, "", audit.ResultFailure, "IdP拒绝: "+idpError)
+			oidcRedirect(c, "ssoError", "身份提供方拒绝了登录")
+			return
+		}
+
+		claims, err := oidcProvider.Exchange(c.Request.Context(), c.Query("code"), saved.CodeVerifier, saved.Nonce)
+		if err != nil {
+			log.Printf("[AUTH] 单点登录校验失败: %v", err)
+			audit.LogAs(c, "", auditSSOLogin, "", audit.ResultFailure, err.Error())
+			oidcRedirect(c, "ssoError", "单点登录失败")
+			return
+		}
+
+		record, err := resolveOIDCUser(claims)
+		if errors.Is(err, errOIDCNoGroup) {
+			audit.LogAs(c, claims.String(oidcConfig.UsernameClaim), auditSSOLogin, claims.Subject(), audit.ResultDenied, "不在允许登录的分组中")
+			oidcRedirect(c, "ssoError", "您所在的分组没有登录权限")
+			return
+		}
+		if err != nil {
+			log.Printf("[AUTH] 单点登录创建或关联用户失败: %v", err)
+			oidcRedirect(c, "ssoError", "单点登录失败")
+			return
+		}
+		if record.Disabled {
+			audit.LogAs(c, record.Username, auditSSOLogin, record.Username, audit.ResultDenied, "账号已禁用")
+			oidcRedirect(c, "ssoError", "账号已被禁用")
+			return
+		}
+
+		// 一次性代码可以换取会话令牌，随机数不可用时直接失败
+		code, err := randomString(32)
+		if err != nil {
+			log.Printf("[AUTH] 生成单点登录代码失败: %v", err)
+			oidcRedirect(c, "ssoError", "单点登录失败")
+			return
+		}
+		err = oidcStore.Put(bucketOIDCLogins, tokenKey(code), oidcLogin{
+			Username:  record.Username,
+			ExpiresAt: time.Now().Add(oidcLoginTTL),
+		})
+		if err != nil {
+			oidcRedirect(c, "ssoError", "单点登录失败")
+			return
+		}
+		oidcRedirect(c, "ssoCode", code)
+	})
+
+	// 用一次性ssoCode换取会话令牌，响应与密码登录相同
+	sso.POST("/exchange", func(c *gin.Context) {
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		var login oidcLogin
+		exists, err := oidcStore.Get(bucketOIDCLogins, tokenKey(req.Code), &login)
+		if err != nil || !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新登录"})
+			return
+		}
+		_ = oidcStore.Delete(bucketOIDCLogins, tokenKey(req.Code))
+		if time.Now().After(login.ExpiresAt) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新登录"})
+			return
+		}
+
+		record, exists := getUser(login.Username)
+		if !exists || record.Disabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新登录"})
+			return
+		}
+		finishLogin(c, record, auditSSOLogin)
+	})
+}
+
+// sweepOIDCStates 清理过期的state和ssoCode
+func sweepOIDCStates() {
+	now := time.Now()
+	for _, bucket := range []string{bucketOIDCStates, bucketOIDCLogins} {
+		_ = oidcStore.ForEach(bucket, func(key string, raw []byte) error {
+			var item struct {
+				ExpiresAt time.Time `json:"expiresAt"`
+			}
+			if json.Unmarshal(raw, &item) == nil && now.Before(item.ExpiresAt) {
+				return nil
+			}
+			return oidcStore.Delete(bucket, key)
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/oidc/oidctest"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+const testOIDCClientID = "lf-web-tools"
+
+// enableOIDC 启动模拟的IdP并按配置启用单点登录，测试结束后恢复为未启用
+func enableOIDC(t *testing.T, modify func(cfg *config.OIDCConfig)) *oidctest.Server {
+	t.Helper()
+	idp := oidctest.NewServer(testOIDCClientID)
+	t.Cleanup(idp.Close)
+
+	cfg := testConfig.Auth.OIDC
+	cfg.Enabled = true
+	cfg.Issuer = idp.URL
+	cfg.ClientID = testOIDCClientID
+	if modify != nil {
+		modify(&cfg)
+	}
+	if err := initOIDC(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		oidcProvider = nil
+		oidcConfig = testConfig.Auth.OIDC
+	})
+	return idp
+}
+
+// startSSOLogin 发起单点登录，返回IdP授权地址和绑定state的Cookie
+func startSSOLogin(t *testing.T) (string, *http.Cookie) {
+	t.Helper()
+	w := serve(http.MethodGet, "/api/auth/oidc/login", "", nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d: %s", w.Code, w.Body.String())
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcStateCookie {
+			if !cookie.HttpOnly || cookie.Path != "/api/auth/oidc" {
+				t.Errorf("state cookie is not scoped: %+v", cookie)
+			}
+			return w.Header().Get("Location"), cookie
+		}
+	}
+	t.Fatal("login did not set the state cookie")
+	return "", nil
+}
+
+// ssoCallback 带着Cookie访问回调地址，返回跳回页面的查询参数
+func ssoCallback(t *testing.T, callback *url.URL, cookie *http.Cookie) url.Values {
+	t.Helper()
+	header := http.Header{}
+	if cookie != nil {
+		header.Set("Cookie", cookie.String())
+	}
+	w := serve(http.MethodGet, callback.RequestURI(), "", header)
+	if w.Code != http.StatusFound {
+		t.Fatalf("callback status = %d: %s", w.Code, w.Body.String())
+	}
+	location := mustParseURL(t, w.Header().Get("Location"))
+	if location.Path != oidcPagePath {
+		t.Fatalf("callback redirected to %s", location)
+	}
+	return location.Query()
+}
+
+// ssoLogin 以IdP当前的Claims完成一次完整的单点登录，返回回调跳回页面的查询参数
+func ssoLogin(t *testing.T, idp *oidctest.Server) url.Values {
+	t.Helper()
+	authURL, cookie := startSSOLogin(t)
+	callback, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ssoCallback(t, callback, cookie)
+}
+
+// exchangeSSOCode 用ssoCode换取令牌
+func exchangeSSOCode(t *testing.T, code string) map[string]interface{} {
+	t.Helper()
+	w := serve(http.MethodPost, "/api/auth/oidc/exchange", `{"code":"`+code+`"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("exchange status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// ssoUsername 完成单点登录并返回登录的本地用户名
+func ssoUsername(t *testing.T, idp *oidctest.Server) string {
+	t.Helper()
+	result := ssoLogin(t, idp)
+	if result.Get("ssoCode") == "" {
+		t.Fatalf("single sign-on failed: %s", result.Get("ssoError"))
+	}
+	username, _ := exchangeSSOCode(t, result.Get("ssoCode"))["user"].(string)
+	return username
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	idp := enableOIDC(t, nil)
+	idp.Claims = map[string]interface{}{
+		"sub":                "flow-subject",
+		"preferred_username": "flow user",
+		"email":              "flow@example.com",
+		"email_verified":     true,
+	}
+
+	result := ssoLogin(t, idp)
+	code := result.Get("ssoCode")
+	if code == "" {
+		t.Fatalf("single sign-on failed: %s", result.Get("ssoError"))
+	}
+	resp := exchangeSSOCode(t, code)
+	token, _ := resp["token"].(string)
+	if resp["success"] != true || resp["user"] != "flow-user" || token == "" {
+		t.Fatalf("unexpected exchange response: %v", resp)
+	}
+
+	w := serve(http.MethodGet, "/api/auth/profile", "", http.Header{"Authorization": {"Bearer " + token}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"username":"flow-user"`) {
+		t.Fatalf("profile status = %d: %s", w.Code, w.Body.String())
+	}
+	record, _ := getUser("flow-user")
+	if record.OIDCIssuer != idp.URL || record.OIDCSubject != "flow-subject" || !record.EmailVerified || record.Role != "user" {
+		t.Errorf("unexpected user record: %+v", record)
+	}
+
+	// ssoCode只能换取一次
+	if w := serve(http.MethodPost, "/api/auth/oidc/exchange", `{"code":"`+code+`"}`, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("reused ssoCode status = %d", w.Code)
+	}
+
+	// 同一个IdP账号再次登录使用已关联的用户
+	if username := ssoUsername(t, idp); username != "flow-user" {
+		t.Errorf("second login username = %s", username)
+	}
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	idp := enableOIDC(t, nil)
+	idp.Claims = map[string]interface{}{"sub": "cookie-subject", "preferred_username": "cookie-user"}
+
+	authURL, cookie := startSSOLogin(t)
+	callback, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有Cookie或Cookie与state不一致时，视为攻击者发起的登录
+	if result := ssoCallback(t, callback, nil); result.Get("ssoError") == "" {
+		t.Errorf("callback without cookie succeeded: %v", result)
+	}
+	other := &http.Cookie{Name: oidcStateCookie, Value: "attacker-state"}
+	if result := ssoCallback(t, callback, other); result.Get("ssoError") == "" {
+		t.Errorf("callback with mismatched cookie succeeded: %v", result)
+	}
+
+	if result := ssoCallback(t, callback, cookie); result.Get("ssoCode") == "" {
+		t.Fatalf("callback with state cookie failed: %v", result)
+	}
+	// state只能使用一次
+	if result := ssoCallback(t, callback, cookie); result.Get("ssoError") == "" {
+		t.Errorf("replayed callback succeeded: %v", result)
+	}
+}
+
+func TestOIDCCallbackRejectsIdPError(t *testing.T) {
+	enableOIDC(t, nil)
+	authURL, cookie := startSSOLogin(t)
+	state := mustParseURL(t, authURL).Query().Get("state")
+
+	callback := mustParseURL(t, "/api/auth/oidc/callback?error=access_denied&state="+url.QueryEscape(state))
+	if result := ssoCallback(t, callback, cookie); result.Get("ssoError") == "" {
+		t.Errorf("IdP error did not fail the login: %v", result)
+	}
+}
+
+func TestOIDCLinkByEmail(t *testing.T) {
+	idp := enableOIDC(t, func(cfg *config.OIDCConfig) { cfg.LinkByEmail = true })
+	err := dataStore.Put(store.BucketUsers, "linked", userRecord{Username: "linked", Email: "Linked@example.com", EmailVerified: true, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 本地未验证的邮箱可能是攻击者抢先注册的，不能关联
+	err = dataStore.Put(store.BucketUsers, "squatter", userRecord{Username: "squatter", Email: "victim@example.com", Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.Claims = map[string]interface{}{"sub": "victim-subject", "preferred_username": "victim", "email": "victim@example.com", "email_verified": true}
+	if username := ssoUsername(t, idp); username != "victim" {
+		t.Errorf("login with an unverified local email username = %s, want victim", username)
+	}
+	if record, _ := getUser("squatter"); record.OIDCSubject != "" || record.EmailVerified {
+		t.Errorf("unverified local user was linked: %+v", record)
+	}
+
+	// IdP没有声明邮箱已验证时不关联，创建新用户
+	idp.Claims = map[string]interface{}{"sub": "unverified-subject", "preferred_username": "linked", "email": "linked@example.com", "email_verified": false}
+	username := ssoUsername(t, idp)
+	if username == "linked" || !strings.HasPrefix(username, "linked-") {
+		t.Errorf("unverified email login username = %s", username)
+	}
+
+	idp.Claims = map[string]interface{}{"sub": "verified-subject", "preferred_username": "someone", "email": "linked@example.com", "email_verified": true}
+	if username := ssoUsername(t, idp); username != "linked" {
+		t.Fatalf("verified email login username = %s, want linked", username)
+	}
+	record, _ := getUser("linked")
+	if record.OIDCSubject != "verified-subject" || !record.EmailVerified {
+		t.Errorf("user was not linked: %+v", record)
+	}
+
+	// 已关联的用户不会再被其他IdP账号按邮箱关联
+	idp.Claims = map[string]interface{}{"sub": "another-subject", "preferred_username": "another", "email": "linked@example.com", "email_verified": true}
+	if username := ssoUsername(t, idp); username != "another" {
+		t.Errorf("second account login username = %s, want another", username)
+	}
+}
+
+func TestOIDCGroupRoles(t *testing.T) {
+	idp := enableOIDC(t, func(cfg *config.OIDCConfig) {
+		cfg.GroupRoles = []config.OIDCGroupRole{{Group: "ops", Role: "admin"}, {Group: "dev", Role: "user"}}
+	})
+
+	idp.Claims = map[string]interface{}{"sub": "group-subject", "preferred_username": "grouped", "groups": []string{"staff", "ops"}}
+	if username := ssoUsername(t, idp); username != "grouped" {
+		t.Fatalf("username = %s", username)
+	}
+	if record, _ := getUser("grouped"); record.Role != "admin" {
+		t.Errorf("role = %s, want admin", record.Role)
+	}
+
+	// 每次登录按分组同步角色，离开分组后回到默认角色
+	idp.Claims["groups"] = "staff"
+	ssoUsername(t, idp)
+	if record, _ := getUser("grouped"); record.Role != "user" {
+		t.Errorf("role after leaving group = %s, want user", record.Role)
+	}
+
+	oidcConfig.RequireGroup = true
+	if result := ssoLogin(t, idp); result.Get("ssoError") != "您所在的分组没有登录权限" {
+		t.Errorf("login without required group: %v", result)
+	}
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	if w := serve(http.MethodGet, "/api/auth/oidc/login", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("login status = %d, want 404", w.Code)
+	}
+	w := serve(http.MethodGet, "/api/auth/oidc/config", "", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"enabled":false`) {
+		t.Errorf("config: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRequirePasswordLogin(t *testing.T) {
+	enableOIDC(t, func(cfg *config.OIDCConfig) { cfg.DisablePasswordLogin = true })
+
+	for _, path := range []string{"/api/auth/login", "/api/auth/register", "/api/auth/forgot-password", "/api/auth/reset-password"} {
+		if w := serve(http.MethodPost, path, `{}`, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s status = %d, want 403", path, w.Code)
+		}
+	}
+	w := serve(http.MethodGet, "/api/auth/oidc/config", "", nil)
+	if !strings.Contains(w.Body.String(), `"passwordLogin":false`) {
+		t.Errorf("config: %s", w.Body.String())
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
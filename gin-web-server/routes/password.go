@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"golang.org/x/crypto/argon2"
+)
+
+// 密码哈希采用argon2id，存储为PHC格式：
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+// 算法与参数都编码在哈希串中，调整参数后旧哈希仍可校验，并会在下次登录时重新哈希。
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// passwordParams 当前使用的argon2id参数，由配置覆盖
+var passwordParams = config.Default().Auth.Password
+
+// dummyHash 用户不存在时参与校验的哈希，见checkUserPassword
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// hashPassword 使用argon2id计算带随机盐的密码哈希
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := passwordParams
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword 校验密码，兼容旧版未加盐的SHA-256哈希
+func verifyPassword(raw, hashed string) bool {
+	if isLegacyHash(hashed) {
+		sum := sha256.Sum256([]byte(raw))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hashed)) == 1
+	}
+
+	params, salt, key, err := decodeArgon2Hash(hashed)
+	if err != nil {
+		return false
+	}
+	computed := argon2.IDKey([]byte(raw), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1
+}
+
+// checkUserPassword 校验登录用户的密码。用户不存在或没有本地密码（如单点登录创建的用户）时，
+// 仍按当前参数对固定哈希做一次计算，使响应耗时与密码错误时相同，避免据此判断账号是否存在
+func checkUserPassword(record userRecord, exists bool, password string) bool {
+	if !exists || record.PasswordHash == "" {
+		verifyPassword(password, dummyPasswordHash())
+		return false
+	}
+	return verifyPassword(password, record.PasswordHash)
+}
+
+// dummyPasswordHash 按当前参数计算的固定哈希，首次使用时生成
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = hashPassword("lf-web-tools-dummy-password")
+	})
+	return dummyHash
+}
+
+// passwordNeedsRehash 判断哈希是否为旧算法或参数与当前配置不一致
+func passwordNeedsRehash(hashed string) bool {
+	if isLegacyHash(hashed) {
+		return true
+	}
+	params, _, _, err := decodeArgon2Hash(hashed)
+	if err != nil {
+		return true
+	}
+	return params != passwordParams
+}
+
+// isLegacyHash 旧版哈希为64位十六进制的SHA-256摘要
+func isLegacyHash(hashed string) bool {
+	if len(hashed) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hashed)
+	return err == nil
+}
+
+func decodeArgon2Hash(hashed string) (config.PasswordConfig, []byte, []byte, error) {
+	var params config.PasswordConfig
+
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("不支持的密码哈希格式")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, err
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("不支持的argon2版本: %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	return params, salt, key, nil
+}
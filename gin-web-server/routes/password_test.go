@@ -0,0 +1,187 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+var phcPattern = regexp.MustCompile(`^\$argon2id\$v=19\$m=\d+,t=\d+,p=\d+\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+
+func legacyHash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashPasswordPHC(t *testing.T) {
+	hashed, err := hashPassword("s3cret!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !phcPattern.MatchString(hashed) {
+		t.Fatalf("hash is not in PHC format: %s", hashed)
+	}
+	params, salt, key, err := decodeArgon2Hash(hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != passwordParams || len(salt) != argon2SaltLen || len(key) != argon2KeyLen {
+		t.Errorf("decoded params=%+v salt=%d key=%d", params, len(salt), len(key))
+	}
+	if !verifyPassword("s3cret!", hashed) || verifyPassword("s3cret", hashed) {
+		t.Error("verifyPassword does not match the hashed password")
+	}
+	if other, _ := hashPassword("s3cret!"); other == hashed {
+		t.Error("hashes of the same password share a salt")
+	}
+}
+
+func TestDecodeArgon2HashRejectsMalformed(t *testing.T) {
+	valid, _ := hashPassword("pw")
+	inputs := []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$!!!",
+		valid + "$extra",
+	}
+	for _, input := range inputs {
+		if _, _, _, err := decodeArgon2Hash(input); err == nil {
+			t.Errorf("decodeArgon2Hash(%q) accepted a malformed hash", input)
+		}
+		if verifyPassword("pw", input) {
+			t.Errorf("verifyPassword accepted malformed hash %q", input)
+		}
+	}
+}
+
+func TestLegacyHash(t *testing.T) {
+	legacy := legacyHash("admin123")
+	if !isLegacyHash(legacy) {
+		t.Fatal("SHA-256 hex digest not detected as legacy")
+	}
+	if !verifyPassword("admin123", legacy) || verifyPassword("admin1234", legacy) {
+		t.Error("legacy hash verification is wrong")
+	}
+	for _, hashed := range []string{legacy[:63], legacy + "0", "zz" + legacy[2:]} {
+		if isLegacyHash(hashed) {
+			t.Errorf("isLegacyHash(%q) = true", hashed)
+		}
+	}
+	if current, _ := hashPassword("admin123"); isLegacyHash(current) {
+		t.Error("argon2id hash detected as legacy")
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	current, _ := hashPassword("pw")
+	if passwordNeedsRehash(current) {
+		t.Error("hash with current params needs rehash")
+	}
+	if !passwordNeedsRehash(legacyHash("pw")) {
+		t.Error("legacy hash does not need rehash")
+	}
+	if !passwordNeedsRehash("garbage") {
+		t.Error("malformed hash does not need rehash")
+	}
+
+	old := passwordParams
+	passwordParams = config.PasswordConfig{Memory: old.Memory * 2, Iterations: old.Iterations, Parallelism: old.Parallelism}
+	defer func() { passwordParams = old }()
+	if !passwordNeedsRehash(current) {
+		t.Error("hash with outdated params does not need rehash")
+	}
+}
+
+func TestCheckUserPasswordUnknownUser(t *testing.T) {
+	// 用户不存在时对按当前参数生成的哈希做一次完整计算
+	params, _, _, err := decodeArgon2Hash(dummyPasswordHash())
+	if err != nil || params != passwordParams {
+		t.Fatalf("dummy hash params = %+v, %v", params, err)
+	}
+	hashed, _ := hashPassword("pw")
+	if checkUserPassword(userRecord{}, false, "pw") {
+		t.Error("unknown user accepted")
+	}
+	if checkUserPassword(userRecord{Username: "sso"}, true, "") {
+		t.Error("user without password accepted an empty password")
+	}
+	if !checkUserPassword(userRecord{PasswordHash: hashed}, true, "pw") {
+		t.Error("correct password rejected")
+	}
+}
+
+// login 用服务端保存的验证码答案调用登录接口
+func login(t *testing.T, username, password string) int {
+	t.Helper()
+	captchaID, challenge, err := generateCaptcha()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"username":"` + username + `","password":"` + password + `","captchaId":"` + captchaID + `","captchaCode":"` + challenge.Answer + `"}`
+	return serve(http.MethodPost, "/api/auth/login", body, nil).Code
+}
+
+func TestLoginMigratesLegacyHash(t *testing.T) {
+	err := dataStore.Put(store.BucketUsers, "legacy-user", userRecord{Username: "legacy-user", PasswordHash: legacyHash("old-password"), Role: RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := login(t, "legacy-user", "wrong-password"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password status = %d", code)
+	}
+	if record, _ := getUser("legacy-user"); !isLegacyHash(record.PasswordHash) {
+		t.Fatal("failed login rehashed the password")
+	}
+
+	if code := login(t, "legacy-user", "old-password"); code != http.StatusOK {
+		t.Fatalf("login status = %d", code)
+	}
+	record, _ := getUser("legacy-user")
+	if !phcPattern.MatchString(record.PasswordHash) || !verifyPassword("old-password", record.PasswordHash) {
+		t.Fatalf("legacy hash was not migrated: %s", record.PasswordHash)
+	}
+	if code := login(t, "legacy-user", "old-password"); code != http.StatusOK {
+		t.Fatalf("login after migration status = %d", code)
+	}
+}
+
+func TestLoginUnknownUser(t *testing.T) {
+	if code := login(t, "no-such-user", "whatever"); code != http.StatusUnauthorized {
+		t.Errorf("unknown user status = %d, want 401", code)
+	}
+}
+
+func TestRehashKeepsConcurrentPasswordChange(t *testing.T) {
+	verified := legacyHash("old-password")
+	err := dataStore.Put(store.BucketUsers, "rehash-race", userRecord{Username: "rehash-race", PasswordHash: verified, Role: RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 登录校验通过后、重新计算哈希前，密码被修改
+	changed, _ := hashPassword("new-password")
+	record, _ := getUser("rehash-race")
+	record.PasswordHash = changed
+	if err := dataStore.Put(store.BucketUsers, "rehash-race", record); err != nil {
+		t.Fatal(err)
+	}
+	rehashUserPassword("rehash-race", "old-password", verified)
+	if record, _ := getUser("rehash-race"); record.PasswordHash != changed {
+		t.Error("rehash reverted a concurrent password change")
+	}
+
+	rehashUserPassword("rehash-race", "new-password", changed)
+	record, _ = getUser("rehash-race")
+	if record.PasswordHash == changed || !verifyPassword("new-password", record.PasswordHash) {
+		t.Error("rehash with the current hash was not applied")
+	}
+}
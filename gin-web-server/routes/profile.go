@@ -0,0 +1,329 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/store"
+	"github.com/lf-web-tools/gin-web-server/workspace"
+)
+
+// phonePattern 手机号/电话：可选的+前缀，数字间允许空格和短横线
+var phonePattern = regexp.MustCompile(`^\+?[0-9][0-9 -]{4,18}[0-9]$`)
+
+// profileResponse 当前用户的资料，保留username和role字段兼容旧客户端
+func profileResponse(record userRecord) gin.H {
+	return gin.H{
+		"success":  true,
+		"username": record.Username,
+		"role":     record.roleName(),
+		"user":     record.view(),
+	}
+}
+
+// reauthMaxAge 没有本地密码的用户在此时间内通过单点登录新建的会话，可以直接执行需要确认身份的操作
+const reauthMaxAge = 5 * time.Minute
+
+// confirmIdentity 修改邮箱、注销账号、关闭两步验证前代替密码确认身份：有本地密码的用户校验密码；
+// 没有本地密码的用户（单点登录创建）只能通过IdP登录，当前会话须是reauthMaxAge内新登录的，
+// 已启用两步验证时改为校验动态码，由调用方用verifySecondFactor完成
+func confirmIdentity(c *gin.Context, record userRecord, password string) bool {
+	if record.PasswordHash != "" {
+		return verifyPassword(password, record.PasswordHash)
+	}
+	if record.TOTPEnabled {
+		return true
+	}
+	var sess session
+	exists, err := dataStore.Get(bucketSessions, currentSessionID(c), &sess)
+	return err == nil && exists && time.Since(sess.CreatedAt) < reauthMaxAge
+}
+
+// identityRequiredMessage 身份确认失败时的提示
+func identityRequiredMessage(record userRecord, action string) string {
+	switch {
+	case record.PasswordHash != "":
+		return action + "需要输入正确的当前密码"
+	case record.TOTPEnabled:
+		return action + "需要输入正确的动态码"
+	default:
+		return action + "需要重新通过单点登录确认身份"
+	}
+}
+
+// deleteUserData 删除用户记录及其全部令牌、会话、API密钥、邮件链接、登录失败记录和CURL测试数据，调用方需持有userMu
+func deleteUserData(username string) error {
+	if err := dataStore.Delete(store.BucketUsers, username); err != nil {
+		return err
+	}
+	if err := deleteUserTokens(username); err != nil {
+		log.Printf("[AUTH] 吊销用户 %s 的令牌失败: %v", username, err)
+	}
+
+	userTokenMu.Lock()
+	_ = dataStore.ForEach(bucketUserTokens, func(key string, raw []byte) error {
+		var item userToken
+		if json.Unmarshal(raw, &item) == nil && item.Username == username {
+			return dataStore.Delete(bucketUserTokens, key)
+		}
+		return nil
+	})
+	userTokenMu.Unlock()
+
+	if err := deleteUserAPIKeys(username); err != nil {
+		log.Printf("[AUTH] 删除用户 %s 的API密钥失败: %v", username, err)
+	}
+	if err := workspace.DeleteUser(username); err != nil {
+		log.Printf("[AUTH] 删除用户 %s 的请求历史、集合和环境失败: %v", username, err)
+	}
+	clearUserLockout(username)
+	return nil
+}
+
+// setupProfileRoutes 当前用户的资料查看、修改、导出和注销接口
+func setupProfileRoutes(authed *gin.RouterGroup) {
+	authed.GET("/profile", func(c *gin.Context) {
+		record, exists := getUser(CurrentUser(c))
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusOK, profileResponse(record))
+	})
+
+	// 修改邮箱需要确认身份（见confirmIdentity），新邮箱需重新验证
+	authed.PATCH("/profile", func(c *gin.Context) {
+		var req struct {
+			Email           *string `json:"email"`
+			Phone           *string `json:"phone"`
+			CurrentPassword string  `json:"currentPassword"`
+			Code            string  `json:"code"` // 没有本地密码且启用了两步验证的用户修改邮箱时使用
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		userMu.Lock()
+		record, exists := getUser(CurrentUser(c))
+		if !exists {
+			userMu.Unlock()
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+
+		emailChanged := false
+		if req.Email != nil {
+			email := strings.TrimSpace(*req.Email)
+			if !validEmail(email) {
+				userMu.Unlock()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱格式不正确"})
+				return
+			}
+			if !strings.EqualFold(email, record.Email) {
+				if wait := checkLoginLockout(record.Username, c.ClientIP()); wait > 0 {
+					userMu.Unlock()
+					abortLockedOut(c, wait)
+					return
+				}
+				if !confirmIdentity(c, record, req.CurrentPassword) ||
+					(record.PasswordHash == "" && record.TOTPEnabled && !verifySecondFactor(&record, req.Code, "")) {
+					userMu.Unlock()
+					recordLoginFailure(record.Username, c.ClientIP())
+					audit.Log(c, auditProfileUpdate, record.Username, audit.ResultFailure, "修改邮箱时身份确认失败")
+					c.JSON(http.StatusUnauthorized, gin.H{"error": identityRequiredMessage(record, "修改邮箱")})
+					return
+				}
+				emailChanged = true
+			}
+			record.Email = email
+		}
+		if req.Phone != nil {
+			phone := strings.TrimSpace(*req.Phone)
+			if !phonePattern.MatchString(phone) {
+				userMu.Unlock()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "手机号格式不正确"})
+				return
+			}
+			record.Phone = phone
+		}
+
+		if emailChanged {
+			record.EmailVerified = false
+		}
+		record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+		err := dataStore.Put(store.BucketUsers, record.Username, record)
+		userMu.Unlock()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+			return
+		}
+
+		detail := ""
+		if emailChanged {
+			detail = "修改了邮箱"
+			sendMailAsync("邮箱验证", record, sendVerificationEmail)
+		}
+		audit.Log(c, auditProfileUpdate, record.Username, audit.ResultSuccess, detail)
+		c.JSON(http.StatusOK, profileResponse(record))
+	})
+
+	// 导出服务端保存的与当前用户有关的全部数据，密码哈希、两步验证密钥等凭据只导出是否存在
+	authed.GET("/profile/export", func(c *gin.Context) {
+		username := CurrentUser(c)
+		record, exists := getUser(username)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+
+		sessions, err := listSessions(username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取会话失败"})
+			return
+		}
+		sessionItems := make([]gin.H, 0, len(sessions))
+		for _, sess := range sessions {
+			sessionItems = append(sessionItems, gin.H{
+				"id":         sess.ID,
+				"ip":         sess.IP,
+				"userAgent":  sess.UserAgent,
+				"createdAt":  sess.CreatedAt.Format(time.RFC3339),
+				"lastUsedAt": sess.LastUsedAt.Format(time.RFC3339),
+				"expiresAt":  sess.ExpiresAt.Format(time.RFC3339),
+			})
+		}
+
+		apiKeys, err := apiKeyViews(username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取API密钥失败"})
+			return
+		}
+
+		pendingLinks := make([]gin.H, 0)
+		_ = dataStore.ForEach(bucketUserTokens, func(key string, raw []byte) error {
+			var item userToken
+			if json.Unmarshal(raw, &item) == nil && item.Username == username {
+				pendingLinks = append(pendingLinks, gin.H{
+					"purpose":   item.Purpose,
+					"email":     item.Email,
+					"expiresAt": item.ExpiresAt.Format(time.RFC3339),
+				})
+			}
+			return nil
+		})
+
+		collections, err := workspace.ListCollections(username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取集合失败"})
+			return
+		}
+		environments, err := workspace.ListEnvironments(username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取环境失败"})
+			return
+		}
+		history, _, err := workspace.ListHistory(username, workspace.HistoryFilter{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取请求历史失败"})
+			return
+		}
+
+		export := gin.H{
+			"exportedAt": time.Now().Format(time.RFC3339),
+			"user":       record.view(),
+			"credentials": gin.H{
+				"passwordSet":            record.PasswordHash != "",
+				"twoFactorEnabled":       record.TOTPEnabled,
+				"recoveryCodesRemaining": len(record.RecoveryCodes),
+			},
+			"sessions":     sessionItems,
+			"apiKeys":      apiKeys,
+			"pendingLinks": pendingLinks,
+			"curl": gin.H{
+				"collections":  collections,
+				"environments": environments,
+				"history":      history,
+			},
+		}
+		if attempt := loadLoginAttempt(lockoutKindUser, username, time.Now()); attempt.Failures > 0 {
+			export["loginFailures"] = attempt
+		}
+
+		audit.Log(c, auditProfileExport, username, audit.ResultSuccess, "")
+		filename := fmt.Sprintf("lf-web-tools-%s-%s.json", username, time.Now().Format("20060102"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.IndentedJSON(http.StatusOK, export)
+	})
+
+	// 注销账号：需要确认身份，启用两步验证时还需要动态码；最后一个管理员不能注销
+	authed.DELETE("/account", func(c *gin.Context) {
+		var req struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		username := CurrentUser(c)
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		// 令牌泄露时密码仍是最后一道防线，错误次数计入登录锁定
+		if wait := checkLoginLockout(username, c.ClientIP()); wait > 0 {
+			abortLockedOut(c, wait)
+			return
+		}
+		if !confirmIdentity(c, record, req.Password) {
+			recordLoginFailure(username, c.ClientIP())
+			audit.Log(c, auditAccountDelete, username, audit.ResultFailure, "身份确认失败")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": identityRequiredMessage(record, "注销账号")})
+			return
+		}
+		if record.TOTPEnabled && !verifySecondFactor(&record, req.Code, "") {
+			recordLoginFailure(username, c.ClientIP())
+			audit.Log(c, auditAccountDelete, username, audit.ResultFailure, "动态码错误")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "动态码错误"})
+			return
+		}
+		if record.roleName() == RoleAdmin && countAdmins() <= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能注销唯一的管理员账号"})
+			return
+		}
+
+		if err := deleteUserData(username); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注销账号失败"})
+			return
+		}
+		log.Printf("[AUTH] 用户 %s 注销了账号", username)
+		audit.Log(c, auditAccountDelete, username, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "账号已注销"})
+	})
+}
+
+// countAdmins 统计未禁用的管理员数量
+func countAdmins() int {
+	count := 0
+	_ = dataStore.ForEach(store.BucketUsers, func(key string, raw []byte) error {
+		var record userRecord
+		if json.Unmarshal(raw, &record) == nil && record.roleName() == RoleAdmin && !record.Disabled {
+			count++
+		}
+		return nil
+	})
+	return count
+}
@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// ageSession 把令牌对应会话的创建时间提前，模拟很久以前登录的会话
+func ageSession(t *testing.T, token string, age time.Duration) {
+	t.Helper()
+	item, ok := lookupToken(token)
+	if !ok {
+		t.Fatal("token not found")
+	}
+	var sess session
+	if exists, err := dataStore.Get(bucketSessions, item.SessionID, &sess); err != nil || !exists {
+		t.Fatalf("session %s: %v", item.SessionID, err)
+	}
+	sess.CreatedAt = time.Now().Add(-age)
+	if err := dataStore.Put(bucketSessions, sess.ID, sess); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// enableTOTPFor 为用户启用两步验证，返回当前时间步的动态码
+func enableTOTPFor(t *testing.T, username string) string {
+	t.Helper()
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, _ := getUser(username)
+	record.TOTPSecret, record.TOTPEnabled = secret, true
+	if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+	return totpCode(key, time.Now().Unix()/totpPeriod)
+}
+
+// TestConfirmIdentityWithoutPassword 单点登录创建的用户没有本地密码，凭刚通过IdP登录的会话或动态码确认身份
+func TestConfirmIdentityWithoutPassword(t *testing.T) {
+	resetLockouts(t)
+	t.Run("fresh session deletes account", func(t *testing.T) {
+		token := loginAs(t, "sso-fresh", RoleUser)
+		if w := serve(http.MethodDelete, "/api/auth/account", `{}`, bearer(token)); w.Code != http.StatusOK {
+			t.Fatalf("delete status = %d: %s", w.Code, w.Body.String())
+		}
+		if _, exists := getUser("sso-fresh"); exists {
+			t.Error("user still exists")
+		}
+	})
+
+	t.Run("stale session must log in again", func(t *testing.T) {
+		token := loginAs(t, "sso-stale", RoleUser)
+		ageSession(t, token, reauthMaxAge+time.Minute)
+		for _, req := range []struct{ method, path, body string }{
+			{http.MethodDelete, "/api/auth/account", `{"password":""}`},
+			{http.MethodPatch, "/api/auth/profile", `{"email":"new@example.com"}`},
+		} {
+			w := serve(req.method, req.path, req.body, bearer(token))
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s status = %d, want 401", req.method, req.path, w.Code)
+			}
+		}
+		if _, exists := getUser("sso-stale"); !exists {
+			t.Error("user was deleted")
+		}
+
+		// 重新通过IdP登录后可以修改邮箱
+		token = loginAs(t, "sso-stale", RoleUser)
+		if w := serve(http.MethodPatch, "/api/auth/profile", `{"email":"new@example.com"}`, bearer(token)); w.Code != http.StatusOK {
+			t.Errorf("email change after login status = %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("totp code replaces fresh login", func(t *testing.T) {
+		token := loginAs(t, "sso-totp", RoleUser)
+		code := enableTOTPFor(t, "sso-totp")
+		ageSession(t, token, time.Hour)
+
+		if w := serve(http.MethodPost, "/api/auth/2fa/disable", `{"code":"000000"}`, bearer(token)); w.Code != http.StatusUnauthorized {
+			t.Errorf("disable with wrong code status = %d, want 401", w.Code)
+		}
+		if w := serve(http.MethodPost, "/api/auth/2fa/disable", `{"code":"`+code+`"}`, bearer(token)); w.Code != http.StatusOK {
+			t.Fatalf("disable status = %d: %s", w.Code, w.Body.String())
+		}
+		if record, _ := getUser("sso-totp"); record.TOTPEnabled {
+			t.Error("2FA still enabled")
+		}
+	})
+
+	t.Run("local password is still required", func(t *testing.T) {
+		token := loginAs(t, "local-user", RoleUser)
+		hash, err := hashPassword("Secret-pass-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		record, _ := getUser("local-user")
+		record.PasswordHash = hash
+		if err := dataStore.Put(store.BucketUsers, record.Username, record); err != nil {
+			t.Fatal(err)
+		}
+		if w := serve(http.MethodDelete, "/api/auth/account", `{}`, bearer(token)); w.Code != http.StatusUnauthorized {
+			t.Errorf("delete without password status = %d, want 401", w.Code)
+		}
+		if w := serve(http.MethodDelete, "/api/auth/account", `{"password":"Secret-pass-1"}`, bearer(token)); w.Code != http.StatusOK {
+			t.Errorf("delete with password status = %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestProfileExportAPIKeys(t *testing.T) {
+	token := loginAs(t, "export-user", RoleUser)
+	secret := createAPIKey(t, token, PermQRCode)
+	// 使用一次，导出中应有最近使用时间
+	if w := serve(http.MethodPost, "/api/generate-qrcode", `{"text":"x"}`, http.Header{"X-Api-Key": {secret}}); w.Code == http.StatusUnauthorized {
+		t.Fatalf("API key rejected: %s", w.Body.String())
+	}
+
+	w := serve(http.MethodGet, "/api/auth/profile/export", "", bearer(token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export status = %d: %s", w.Code, w.Body.String())
+	}
+	var export struct {
+		APIKeys []map[string]interface{} `json:"apiKeys"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if len(export.APIKeys) != 1 {
+		t.Fatalf("apiKeys = %v", export.APIKeys)
+	}
+	key := export.APIKeys[0]
+	for _, field := range []string{"id", "name", "prefix", "scopes", "createdAt", "lastUsedAt", "expiresAt"} {
+		if _, ok := key[field]; !ok {
+			t.Errorf("apiKeys[0] has no %s", field)
+		}
+	}
+	if key["lastUsedAt"] == nil {
+		t.Error("lastUsedAt is empty after the key was used")
+	}
+	// 导出内容不含密钥和存储用的哈希
+	if body := w.Body.String(); strings.Contains(body, secret) || strings.Contains(body, tokenKey(secret)) {
+		t.Error("export contains the API key or its hash")
+	}
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+)
+
+// 内置角色
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// 权限名称，与配置中的roles、routePermissions对应
+const (
+	PermAll         = "*"
+	PermProxy       = "proxy"
+	PermPortScan    = "portscan"
+	PermWebSocket   = "websocket"
+	PermQRCode      = "qrcode"
+	PermAuditRead   = "audit:read"
+	PermManageUsers = "users:manage"
+)
+
+// contextRoleKey 登录用户角色在gin.Context中的键
+const contextRoleKey = "authRole"
+
+// rolePermissions 角色到权限列表的映射，由配置覆盖
+var rolePermissions = map[string][]string{
+	RoleAdmin: {PermAll},
+	RoleUser:  {PermProxy, PermPortScan, PermWebSocket, PermQRCode},
+}
+
+// roleName 返回用户的有效角色，未设置角色的旧记录视为普通用户
+func (u userRecord) roleName() string {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
+// roleExists 判断角色是否已在配置中定义
+func roleExists(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// hasPermission 判断角色是否具备指定权限
+func hasPermission(role, perm string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == PermAll || p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// permitted 判断当前请求是否具备指定权限，使用API密钥时还需密钥包含该作用域
+func permitted(c *gin.Context, perm string) bool {
+	return hasPermission(CurrentRole(c), perm) && apiKeyScopeAllowed(c, perm)
+}
+
+// RequirePermission 返回权限校验中间件，需挂在AuthRequired之后
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !permitted(c, perm) {
+			abortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentRole 获取AuthRequired写入的登录用户角色
+func CurrentRole(c *gin.Context) string {
+	return c.GetString(contextRoleKey)
+}
+
+// abortForbidden 统一的无权限响应
+func abortForbidden(c *gin.Context) {
+	audit.Log(c, auditAccessDenied, c.Request.Method+" "+c.Request.URL.Path, audit.ResultDenied, "")
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "没有访问权限"})
+}
@@ -0,0 +1,318 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	mailer "github.com/lf-web-tools/gin-web-server/mail"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// 找回密码和邮箱验证：通过邮件发送一次性的链接令牌，令牌以SHA-256摘要保存，使用后立即删除。
+// 令牌绑定签发时的邮箱，用户更换邮箱后发往旧邮箱的链接随之失效。
+const (
+	bucketUserTokens = "user_tokens"
+
+	purposeResetPassword = "reset-password"
+	purposeVerifyEmail   = "verify-email"
+)
+
+var (
+	mailSender  mailer.Sender
+	mailBaseURL = "http://localhost:8080"
+
+	resetTokenTTL            = 30 * time.Minute
+	verifyTokenTTL           = 24 * time.Hour
+	requireEmailVerification bool
+
+	userTokenMu sync.Mutex
+)
+
+// userToken 找回密码或邮箱验证的一次性令牌
+type userToken struct {
+	Purpose   string    `json:"purpose"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// issueUserToken 签发一次性令牌，同一用户同一用途之前签发的令牌作废
+func issueUserToken(purpose string, record userRecord, ttl time.Duration) (string, error) {
+	userTokenMu.Lock()
+	defer userTokenMu.Unlock()
+
+	err := dataStore.ForEach(bucketUserTokens, func(key string, raw []byte) error {
+		var item userToken
+		if json.Unmarshal(raw, &item) == nil && item.Purpose == purpose && item.Username == record.Username {
+			return dataStore.Delete(bucketUserTokens, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 令牌可以重置密码，随机数不可用时直接失败，不能发出可猜测的令牌
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	err = dataStore.Put(bucketUserTokens, tokenKey(token), userToken{
+		Purpose:   purpose,
+		Username:  record.Username,
+		Email:     record.Email,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	return token, err
+}
+
+// consumeUserToken 校验并删除一次性令牌
+func consumeUserToken(purpose, token string) (userToken, bool) {
+	if token == "" {
+		return userToken{}, false
+	}
+
+	userTokenMu.Lock()
+	defer userTokenMu.Unlock()
+
+	var item userToken
+	exists, err := dataStore.Get(bucketUserTokens, tokenKey(token), &item)
+	if err != nil || !exists || item.Purpose != purpose {
+		return userToken{}, false
+	}
+	if err := dataStore.Delete(bucketUserTokens, tokenKey(token)); err != nil {
+		return userToken{}, false
+	}
+	return item, time.Now().Before(item.ExpiresAt)
+}
+
+// sweepUserTokens 清理过期的一次性令牌
+func sweepUserTokens() {
+	userTokenMu.Lock()
+	defer userTokenMu.Unlock()
+
+	now := time.Now()
+	err := dataStore.ForEach(bucketUserTokens, func(key string, raw []byte) error {
+		var item userToken
+		if json.Unmarshal(raw, &item) == nil && now.Before(item.ExpiresAt) {
+			return nil
+		}
+		return dataStore.Delete(bucketUserTokens, key)
+	})
+	if err != nil {
+		log.Printf("[AUTH] 清理过期数据失败(%s): %v", bucketUserTokens, err)
+	}
+}
+
+// validEmail 只接受不带显示名的纯邮箱地址
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// findUserByAccount 按用户名或邮箱查找用户
+func findUserByAccount(account string) (userRecord, bool) {
+	account = strings.TrimSpace(account)
+	if account == "" {
+		return userRecord{}, false
+	}
+	if record, exists := getUser(account); exists {
+		return record, true
+	}
+
+	var found userRecord
+	var ok bool
+	_ = dataStore.ForEach(store.BucketUsers, func(key string, raw []byte) error {
+		var record userRecord
+		if !ok && json.Unmarshal(raw, &record) == nil && record.Email != "" && strings.EqualFold(record.Email, account) {
+			found, ok = record, true
+		}
+		return nil
+	})
+	return found, ok
+}
+
+// mailLink 邮件中的链接，页面从查询参数中读取令牌
+func mailLink(param, token string) string {
+	return strings.TrimSuffix(mailBaseURL, "/") + "/static/index.html?" + param + "=" + url.QueryEscape(token)
+}
+
+// sendVerificationEmail 签发邮箱验证令牌并发送邮件
+func sendVerificationEmail(record userRecord) error {
+	token, err := issueUserToken(purposeVerifyEmail, record, verifyTokenTTL)
+	if err != nil {
+		return err
+	}
+	return mailSender.Send(mailer.Message{
+		To:      record.Email,
+		Subject: "LF Web Tools 邮箱验证",
+		Body: fmt.Sprintf("%s 您好：\n\n请在%s内打开以下链接完成邮箱验证：\n%s\n\n如果这不是您本人的操作，请忽略本邮件。\n",
+			record.Username, formatTTL(verifyTokenTTL), mailLink("verifyToken", token)),
+	})
+}
+
+// sendPasswordResetEmail 签发找回密码令牌并发送邮件
+func sendPasswordResetEmail(record userRecord) error {
+	token, err := issueUserToken(purposeResetPassword, record, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+	return mailSender.Send(mailer.Message{
+		To:      record.Email,
+		Subject: "LF Web Tools 重置密码",
+		Body: fmt.Sprintf("%s 您好：\n\n请在%s内打开以下链接重置密码，链接只能使用一次：\n%s\n\n如果这不是您本人的操作，请忽略本邮件，您的密码不会被修改。\n",
+			record.Username, formatTTL(resetTokenTTL), mailLink("resetToken", token)),
+	})
+}
+
+// sendMailAsync 后台发送邮件，接口响应时间不因账号是否存在而不同
+func sendMailAsync(kind string, record userRecord, send func(userRecord) error) {
+	go func() {
+		if err := send(record); err != nil {
+			log.Printf("[MAIL] 发送%s邮件给用户 %s 失败: %v", kind, record.Username, err)
+		}
+	}()
+}
+
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d小时", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d分钟", int(d/time.Minute))
+}
+
+// setupRecoveryRoutes 找回密码和邮箱验证接口
+func setupRecoveryRoutes(auth *gin.RouterGroup) {
+	// 无论账号是否存在都返回成功，避免被用来探测注册邮箱
+	accountMailHandler := func(kind, action string, eligible func(userRecord) bool, send func(userRecord) error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var req struct {
+				Account     string `json:"account"`
+				CaptchaID   string `json:"captchaId"`
+				CaptchaCode string `json:"captchaCode"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+				return
+			}
+
+			clientIP := c.ClientIP()
+			if wait := checkLoginLockout("", clientIP); wait > 0 {
+				abortLockedOut(c, wait)
+				return
+			}
+			if !validateCaptcha(req.CaptchaID, req.CaptchaCode) {
+				recordLoginFailure("", clientIP)
+				c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误或已过期"})
+				return
+			}
+
+			// 审计日志只记录申请的账号，不记录账号是否存在
+			audit.LogAs(c, "", action, req.Account, audit.ResultSuccess, "")
+			if record, exists := findUserByAccount(req.Account); exists && !record.Disabled && record.Email != "" && eligible(record) {
+				sendMailAsync(kind, record, send)
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true, "message": "如果账号存在，邮件已发送到注册邮箱"})
+		}
+	}
+
+	auth.POST("/forgot-password", requirePasswordLogin(), accountMailHandler("重置密码", auditPasswordForgot,
+		func(userRecord) bool { return true }, sendPasswordResetEmail))
+
+	auth.POST("/resend-verification", accountMailHandler("邮箱验证", auditEmailResend,
+		func(record userRecord) bool { return !record.EmailVerified }, sendVerificationEmail))
+
+	auth.POST("/reset-password", requirePasswordLogin(), func(c *gin.Context) {
+		var req struct {
+			Token       string `json:"token"`
+			NewPassword string `json:"newPassword"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+		if len(req.NewPassword) < 6 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "新密码长度不能少于6位"})
+			return
+		}
+
+		item, ok := consumeUserToken(purposeResetPassword, req.Token)
+		if !ok {
+			audit.LogAs(c, "", auditPasswordReset, "", audit.ResultFailure, "链接无效或已过期")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "链接无效或已过期，请重新找回密码"})
+			return
+		}
+
+		passwordHash, err := hashPassword(req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存新密码失败"})
+			return
+		}
+
+		userMu.Lock()
+		record, exists := getUser(item.Username)
+		if !exists || record.Disabled || record.Email != item.Email {
+			userMu.Unlock()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "链接无效或已过期，请重新找回密码"})
+			return
+		}
+		record.PasswordHash = passwordHash
+		// 能收到重置邮件说明邮箱属于该用户
+		record.EmailVerified = true
+		record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+		err = dataStore.Put(store.BucketUsers, record.Username, record)
+		userMu.Unlock()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存新密码失败"})
+			return
+		}
+
+		_ = deleteUserTokens(record.Username)
+		clearUserLockout(record.Username)
+		log.Printf("[AUTH] 用户 %s 通过邮件重置了密码", record.Username)
+		audit.LogAs(c, record.Username, auditPasswordReset, record.Username, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "密码已重置，请重新登录"})
+	})
+
+	auth.POST("/verify-email", func(c *gin.Context) {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		item, ok := consumeUserToken(purposeVerifyEmail, req.Token)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "验证链接无效或已过期"})
+			return
+		}
+
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(item.Username)
+		if !exists || record.Email != item.Email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "验证链接无效或已过期"})
+			return
+		}
+		record.EmailVerified = true
+		record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+		if err := dataStore.Put(store.BucketUsers, record.Username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+			return
+		}
+		audit.LogAs(c, record.Username, auditEmailVerify, record.Email, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "邮箱验证成功"})
+	})
+}
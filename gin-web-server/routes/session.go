@@ -0,0 +1,553 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/jwt"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// 登录后创建一个会话，会话持有一个短期访问令牌和一个可轮换的刷新令牌：
+// 访问令牌过期后用刷新令牌换取新的令牌对，旧刷新令牌随即作废；
+// 已作废的刷新令牌再次出现说明令牌可能被盗用，整个会话会被吊销。
+const (
+	bucketSessions      = "sessions"
+	bucketRefreshTokens = "refresh_tokens"
+	// bucketRevokedSessions JWT模式下已吊销的会话，JWT访问令牌不落存储，只能按会话ID拒绝
+	bucketRevokedSessions = "revoked_sessions"
+
+	// sessionTouchInterval 会话最近使用时间的最小更新间隔，避免每个请求都写存储
+	sessionTouchInterval = time.Minute
+	// refreshReuseGrace 多个标签页同时刷新时，上一个刷新令牌在此时间内重复使用不视为盗用
+	refreshReuseGrace = 10 * time.Second
+)
+
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+
+	// sessionMu 串行化会话的读-改-写，保证刷新令牌只能成功轮换一次
+	sessionMu sync.Mutex
+
+	errRefreshInvalid = errors.New("刷新令牌无效或已过期")
+	errRefreshReused  = errors.New("刷新令牌已被使用，会话已吊销")
+	errRefreshRotated = errors.New("刷新令牌已轮换，请使用最新的令牌")
+)
+
+type authToken struct {
+	Username  string    `json:"username"`
+	SessionID string    `json:"sessionId,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// API密钥的作用域，登录会话为nil
+	scopes []string
+	// role JWT访问令牌载荷中的角色，其他令牌为空，角色取自用户记录
+	role string
+}
+
+// revokedSession 吊销列表中的会话，ExpiresAt之后该会话签发的JWT访问令牌都已过期，记录可以清理
+type revokedSession struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// session 一次登录产生的会话
+type session struct {
+	ID                  string    `json:"id"`
+	Username            string    `json:"username"`
+	IP                  string    `json:"ip"`
+	UserAgent           string    `json:"userAgent"`
+	CreatedAt           time.Time `json:"createdAt"`
+	LastUsedAt          time.Time `json:"lastUsedAt"`
+	ExpiresAt           time.Time `json:"expiresAt"`
+	RefreshHash         string    `json:"refreshHash"`
+	PreviousRefreshHash string    `json:"previousRefreshHash,omitempty"`
+	RotatedAt           time.Time `json:"rotatedAt"`
+}
+
+// refreshRecord 已签发的刷新令牌，轮换后仍保留到过期，用于识别重复使用
+type refreshRecord struct {
+	SessionID string    `json:"sessionId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// tokenPair 返回给客户端的令牌对
+type tokenPair struct {
+	AccessToken    string
+	RefreshToken   string
+	AccessExpires  time.Time
+	RefreshExpires time.Time
+}
+
+func (p tokenPair) response() gin.H {
+	return gin.H{
+		"token":          p.AccessToken,
+		"expires":        p.AccessExpires.Format(time.RFC3339),
+		"refreshToken":   p.RefreshToken,
+		"refreshExpires": p.RefreshExpires.Format(time.RFC3339),
+	}
+}
+
+// tokenKey 令牌只以SHA-256摘要作为键保存，存储文件泄露时无法直接冒用会话
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createSession 为登录成功的用户创建会话并签发令牌对
+func createSession(c *gin.Context, username string) (tokenPair, error) {
+	id, err := randomString(16)
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	now := time.Now()
+	sess := session{
+		ID:         id,
+		Username:   username,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return issueTokensLocked(&sess)
+}
+
+// finishLogin 身份校验通过后完成登录，action为写入审计日志的登录方式：启用了两步验证或管理员要求两步验证时先返回登录挑战，
+// 由第二步换取令牌；否则直接创建会话并返回令牌
+func finishLogin(c *gin.Context, record userRecord, action string) {
+	setup := !record.TOTPEnabled && loadSecuritySettings().Require2FA
+	if record.TOTPEnabled || setup {
+		challengeToken, err := createLoginChallenge(record.Username, setup)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败，请稍后重试"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":                false,
+			"twoFactorRequired":      record.TOTPEnabled,
+			"twoFactorSetupRequired": setup,
+			"challengeToken":         challengeToken,
+			"expiresIn":              int(loginChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	clearUserLockout(record.Username)
+	pair, err := createSession(c, record.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败，请稍后重试"})
+		return
+	}
+
+	audit.LogAs(c, record.Username, action, record.Username, audit.ResultSuccess, "")
+	response := pair.response()
+	response["success"] = true
+	response["user"] = record.Username
+	c.JSON(http.StatusOK, response)
+}
+
+// issueTokensLocked 为会话签发新的访问令牌和刷新令牌，调用方需持有sessionMu
+func issueTokensLocked(sess *session) (tokenPair, error) {
+	accessToken, err := generateToken()
+	if err != nil {
+		return tokenPair{}, err
+	}
+	refreshToken, err := generateToken()
+	if err != nil {
+		return tokenPair{}, err
+	}
+	now := time.Now()
+	pair := tokenPair{
+		AccessToken:    accessToken,
+		RefreshToken:   refreshToken,
+		AccessExpires:  now.Add(accessTokenTTL),
+		RefreshExpires: now.Add(refreshTokenTTL),
+	}
+	if jwtKeys != nil {
+		user, _ := getUser(sess.Username)
+		token, err := signAccessJWT(sess, user.roleName(), pair.AccessExpires)
+		if err != nil {
+			return tokenPair{}, err
+		}
+		pair.AccessToken = token
+	}
+
+	refreshHash := tokenKey(pair.RefreshToken)
+	if sess.RefreshHash != "" {
+		sess.PreviousRefreshHash = sess.RefreshHash
+		sess.RotatedAt = now
+	}
+	sess.RefreshHash = refreshHash
+	sess.ExpiresAt = pair.RefreshExpires
+	sess.LastUsedAt = now
+
+	err = dataStore.Put(bucketRefreshTokens, refreshHash, refreshRecord{
+		SessionID: sess.ID,
+		ExpiresAt: pair.RefreshExpires,
+	})
+	if err != nil {
+		return tokenPair{}, err
+	}
+	if err := dataStore.Put(bucketSessions, sess.ID, sess); err != nil {
+		return tokenPair{}, err
+	}
+	if jwtKeys != nil {
+		return pair, nil
+	}
+	err = dataStore.Put(store.BucketTokens, tokenKey(pair.AccessToken), authToken{
+		Username:  sess.Username,
+		SessionID: sess.ID,
+		ExpiresAt: pair.AccessExpires,
+	})
+	if err != nil {
+		return tokenPair{}, err
+	}
+	return pair, nil
+}
+
+// refreshSession 用刷新令牌换取新的令牌对
+func refreshSession(refreshToken string) (tokenPair, error) {
+	if refreshToken == "" {
+		return tokenPair{}, errRefreshInvalid
+	}
+	refreshHash := tokenKey(refreshToken)
+
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	var record refreshRecord
+	exists, err := dataStore.Get(bucketRefreshTokens, refreshHash, &record)
+	if err != nil {
+		return tokenPair{}, err
+	}
+	if !exists || time.Now().After(record.ExpiresAt) {
+		return tokenPair{}, errRefreshInvalid
+	}
+
+	var sess session
+	exists, err = dataStore.Get(bucketSessions, record.SessionID, &sess)
+	if err != nil {
+		return tokenPair{}, err
+	}
+	if !exists || time.Now().After(sess.ExpiresAt) {
+		return tokenPair{}, errRefreshInvalid
+	}
+
+	if sess.RefreshHash != refreshHash {
+		if refreshHash == sess.PreviousRefreshHash && time.Since(sess.RotatedAt) < refreshReuseGrace {
+			return tokenPair{}, errRefreshRotated
+		}
+		log.Printf("[AUTH] 检测到刷新令牌重复使用，吊销用户 %s 的会话 %s", sess.Username, sess.ID)
+		if err := revokeSessionLocked(sess.ID); err != nil {
+			log.Printf("[AUTH] 吊销会话 %s 失败: %v", sess.ID, err)
+		}
+		return tokenPair{}, errRefreshReused
+	}
+
+	// 用户已被删除或禁用时不再续期；管理员要求两步验证后，未绑定的用户需重新登录完成绑定
+	user, exists := getUser(sess.Username)
+	if !exists || user.Disabled || (!user.TOTPEnabled && loadSecuritySettings().Require2FA) {
+		_ = revokeSessionLocked(sess.ID)
+		return tokenPair{}, errRefreshInvalid
+	}
+
+	return issueTokensLocked(&sess)
+}
+
+// revokeSession 吊销会话及其下的全部访问令牌和刷新令牌
+func revokeSession(id string) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return revokeSessionLocked(id)
+}
+
+func revokeSessionLocked(id string) error {
+	err := dataStore.ForEach(store.BucketTokens, func(key string, raw []byte) error {
+		var item authToken
+		if err := json.Unmarshal(raw, &item); err != nil || item.SessionID != id {
+			return nil
+		}
+		return dataStore.Delete(store.BucketTokens, key)
+	})
+	if err != nil {
+		return err
+	}
+	err = dataStore.ForEach(bucketRefreshTokens, func(key string, raw []byte) error {
+		var record refreshRecord
+		if err := json.Unmarshal(raw, &record); err != nil || record.SessionID != id {
+			return nil
+		}
+		return dataStore.Delete(bucketRefreshTokens, key)
+	})
+	if err != nil {
+		return err
+	}
+	if jwtKeys != nil {
+		revoked := revokedSession{ExpiresAt: time.Now().Add(accessTokenTTL)}
+		if err := dataStore.Put(bucketRevokedSessions, id, revoked); err != nil {
+			return err
+		}
+	}
+	return dataStore.Delete(bucketSessions, id)
+}
+
+// listSessions 返回用户的全部会话，按最近使用时间倒序
+func listSessions(username string) ([]session, error) {
+	sessions := make([]session, 0)
+	err := dataStore.ForEach(bucketSessions, func(key string, raw []byte) error {
+		var sess session
+		if err := json.Unmarshal(raw, &sess); err != nil {
+			return nil
+		}
+		if sess.Username == username && time.Now().Before(sess.ExpiresAt) {
+			sessions = append(sessions, sess)
+		}
+		return nil
+	})
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, err
+}
+
+// deleteUserTokens 吊销用户的全部会话和登录令牌
+func deleteUserTokens(username string) error {
+	sessions, err := listSessions(username)
+	if err != nil {
+		return err
+	}
+	for _, sess := range sessions {
+		if err := revokeSession(sess.ID); err != nil {
+			return err
+		}
+	}
+	// 清理不属于任何会话的令牌
+	return dataStore.ForEach(store.BucketTokens, func(key string, raw []byte) error {
+		var item authToken
+		if err := json.Unmarshal(raw, &item); err != nil || item.Username != username {
+			return nil
+		}
+		return dataStore.Delete(store.BucketTokens, key)
+	})
+}
+
+// lookupToken 校验访问令牌并返回令牌信息
+func lookupToken(token string) (authToken, bool) {
+	if token == "" {
+		return authToken{}, false
+	}
+	if jwtKeys != nil && jwt.LooksLikeJWT(token) {
+		// JWT访问令牌自包含，不要求本实例存有会话；退出、吊销会话、修改密码、禁用和删除用户都会把会话记入吊销列表
+		item, ok := verifyAccessJWT(token)
+		if !ok || sessionRevoked(item.SessionID) {
+			return authToken{}, false
+		}
+		touchSession(item.SessionID)
+		return item, true
+	}
+	if isAPIKey(token) {
+		return lookupAPIKey(token)
+	}
+
+	var item authToken
+	exists, err := dataStore.Get(store.BucketTokens, tokenKey(token), &item)
+	if err != nil || !exists {
+		return authToken{}, false
+	}
+	if time.Now().After(item.ExpiresAt) {
+		_ = dataStore.Delete(store.BucketTokens, tokenKey(token))
+		return authToken{}, false
+	}
+	if item.SessionID != "" {
+		touchSession(item.SessionID)
+	}
+	return item, true
+}
+
+// sessionRevoked 会话是否在吊销列表中，读取失败时按已吊销处理
+func sessionRevoked(id string) bool {
+	var revoked revokedSession
+	exists, err := dataStore.Get(bucketRevokedSessions, id, &revoked)
+	return err != nil || exists
+}
+
+// touchSession 更新会话的最近使用时间
+func touchSession(id string) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	var sess session
+	exists, err := dataStore.Get(bucketSessions, id, &sess)
+	if err != nil || !exists || time.Since(sess.LastUsedAt) < sessionTouchInterval {
+		return
+	}
+	sess.LastUsedAt = time.Now()
+	if err := dataStore.Put(bucketSessions, id, sess); err != nil {
+		log.Printf("[AUTH] 更新会话 %s 失败: %v", id, err)
+	}
+}
+
+// sweepExpiredTokens 清理过期的访问令牌、刷新令牌和会话
+func sweepExpiredTokens() {
+	now := time.Now()
+	expired := func(bucket string, expiresAt func(raw []byte) (time.Time, bool)) {
+		err := dataStore.ForEach(bucket, func(key string, raw []byte) error {
+			if at, ok := expiresAt(raw); ok && now.After(at) {
+				return dataStore.Delete(bucket, key)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("[AUTH] 清理过期数据失败(%s): %v", bucket, err)
+		}
+	}
+
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	expired(store.BucketTokens, func(raw []byte) (time.Time, bool) {
+		var item authToken
+		return item.ExpiresAt, json.Unmarshal(raw, &item) == nil
+	})
+	expired(bucketRefreshTokens, func(raw []byte) (time.Time, bool) {
+		var record refreshRecord
+		return record.ExpiresAt, json.Unmarshal(raw, &record) == nil
+	})
+	expired(bucketSessions, func(raw []byte) (time.Time, bool) {
+		var sess session
+		return sess.ExpiresAt, json.Unmarshal(raw, &sess) == nil
+	})
+	expired(bucketRevokedSessions, func(raw []byte) (time.Time, bool) {
+		var revoked revokedSession
+		return revoked.ExpiresAt, json.Unmarshal(raw, &revoked) == nil
+	})
+}
+
+// startTokenSweeper 启动后台清理任务
+func startTokenSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sweepExpiredTokens()
+			sweepLoginAttempts()
+			sweepUserTokens()
+			sweepOIDCStates()
+		}
+	}()
+}
+
+// setupSessionRoutes 刷新令牌和会话管理接口
+func setupSessionRoutes(auth *gin.RouterGroup, authed *gin.RouterGroup) {
+	auth.POST("/refresh", func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refreshToken"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		pair, err := refreshSession(req.RefreshToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, errRefreshRotated):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, errRefreshInvalid), errors.Is(err, errRefreshReused):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+			}
+			return
+		}
+
+		response := pair.response()
+		response["success"] = true
+		c.JSON(http.StatusOK, response)
+	})
+
+	authed.GET("/sessions", func(c *gin.Context) {
+		sessions, err := listSessions(CurrentUser(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取会话失败"})
+			return
+		}
+
+		current := currentSessionID(c)
+		items := make([]gin.H, 0, len(sessions))
+		for _, sess := range sessions {
+			items = append(items, gin.H{
+				"id":         sess.ID,
+				"ip":         sess.IP,
+				"userAgent":  sess.UserAgent,
+				"createdAt":  sess.CreatedAt.Format(time.RFC3339),
+				"lastUsedAt": sess.LastUsedAt.Format(time.RFC3339),
+				"expiresAt":  sess.ExpiresAt.Format(time.RFC3339),
+				"current":    sess.ID == current,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "sessions": items})
+	})
+
+	authed.DELETE("/sessions/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var sess session
+		exists, err := dataStore.Get(bucketSessions, id, &sess)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取会话失败"})
+			return
+		}
+		if !exists || sess.Username != CurrentUser(c) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+			return
+		}
+		if err := revokeSession(id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销会话失败"})
+			return
+		}
+		audit.Log(c, auditSessionRevoke, sess.Username, audit.ResultSuccess, "会话 "+id)
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	// 默认吊销除当前会话外的全部会话，includeCurrent=true时连同当前会话一起吊销
+	authed.DELETE("/sessions", func(c *gin.Context) {
+		sessions, err := listSessions(CurrentUser(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取会话失败"})
+			return
+		}
+
+		current := currentSessionID(c)
+		includeCurrent := c.Query("includeCurrent") == "true"
+		revoked := 0
+		for _, sess := range sessions {
+			if sess.ID == current && !includeCurrent {
+				continue
+			}
+			if err := revokeSession(sess.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销会话失败"})
+				return
+			}
+			revoked++
+		}
+		audit.Log(c, auditSessionRevoke, CurrentUser(c), audit.ResultSuccess, fmt.Sprintf("吊销了%d个会话", revoked))
+		c.JSON(http.StatusOK, gin.H{"success": true, "revoked": revoked})
+	})
+}
+
+// currentSessionID 当前请求令牌所属的会话
+func currentSessionID(c *gin.Context) string {
+	return c.GetString(contextSessionKey)
+}
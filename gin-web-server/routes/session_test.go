@@ -0,0 +1,211 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// newSession 为用户创建会话（用户不存在时创建），返回完整的令牌对
+func newSession(t *testing.T, username string) tokenPair {
+	t.Helper()
+	loginAs(t, username, RoleUser)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	pair, err := createSession(c, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+// refresh 调用刷新接口，成功时返回新的令牌对
+func refresh(t *testing.T, refreshToken string) (int, tokenPair) {
+	t.Helper()
+	body, _ := json.Marshal(gin.H{"refreshToken": refreshToken})
+	w := serve(http.MethodPost, "/api/auth/refresh", string(body), nil)
+	var resp struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, tokenPair{AccessToken: resp.Token, RefreshToken: resp.RefreshToken}
+}
+
+// tokenValid 访问令牌能否通过鉴权
+func tokenValid(token string) bool {
+	return serve(http.MethodGet, "/api/auth/profile", "", bearer(token)).Code == http.StatusOK
+}
+
+// sessionOf 读取令牌对所属的会话
+func sessionOf(t *testing.T, pair tokenPair) session {
+	t.Helper()
+	var record refreshRecord
+	if exists, err := dataStore.Get(bucketRefreshTokens, tokenKey(pair.RefreshToken), &record); err != nil || !exists {
+		t.Fatalf("refresh token not found: %v", err)
+	}
+	var sess session
+	if exists, err := dataStore.Get(bucketSessions, record.SessionID, &sess); err != nil || !exists {
+		t.Fatalf("session %s not found: %v", record.SessionID, err)
+	}
+	return sess
+}
+
+func TestRefreshRotatesOnce(t *testing.T) {
+	first := newSession(t, "refresh-once")
+	code, second := refresh(t, first.RefreshToken)
+	if code != http.StatusOK || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh = %d, rotated %v", code, second.RefreshToken != first.RefreshToken)
+	}
+	if !tokenValid(second.AccessToken) {
+		t.Error("new access token rejected")
+	}
+
+	// 同时刷新的另一个标签页在宽限期内拿到409，会话不受影响
+	if code, _ := refresh(t, first.RefreshToken); code != http.StatusConflict {
+		t.Errorf("reuse within grace = %d, want 409", code)
+	}
+	code, third := refresh(t, second.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh with latest token = %d", code)
+	}
+
+	// 更早的刷新令牌既不是当前的也不是上一个，视为盗用并吊销会话
+	if code, _ := refresh(t, first.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("reuse of an older token = %d, want 401", code)
+	}
+	if tokenValid(third.AccessToken) {
+		t.Error("access token still valid after the session was revoked")
+	}
+	if code, _ := refresh(t, third.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh after revocation = %d, want 401", code)
+	}
+}
+
+func TestRefreshReuseAfterGraceRevokesSession(t *testing.T) {
+	first := newSession(t, "refresh-grace")
+	code, second := refresh(t, first.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh = %d", code)
+	}
+	sess := sessionOf(t, second)
+	sess.RotatedAt = time.Now().Add(-refreshReuseGrace - time.Second)
+	if err := dataStore.Put(bucketSessions, sess.ID, sess); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := refresh(t, first.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("reuse after grace = %d, want 401", code)
+	}
+	if exists, _ := dataStore.Get(bucketSessions, sess.ID, &session{}); exists {
+		t.Error("session was not revoked")
+	}
+	if tokenValid(second.AccessToken) {
+		t.Error("access token still valid after reuse was detected")
+	}
+	if code, _ := refresh(t, second.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh with the latest token after revocation = %d, want 401", code)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	defer func(access, refresh time.Duration) { accessTokenTTL, refreshTokenTTL = access, refresh }(accessTokenTTL, refreshTokenTTL)
+
+	t.Run("expired access token", func(t *testing.T) {
+		accessTokenTTL = -time.Second
+		pair := newSession(t, "expiry-access")
+		if tokenValid(pair.AccessToken) {
+			t.Error("expired access token accepted")
+		}
+		if exists, _ := dataStore.Get(store.BucketTokens, tokenKey(pair.AccessToken), &authToken{}); exists {
+			t.Error("expired access token was not deleted")
+		}
+
+		// 刷新令牌仍有效，换取的新访问令牌可以使用
+		accessTokenTTL = time.Minute
+		code, next := refresh(t, pair.RefreshToken)
+		if code != http.StatusOK || !tokenValid(next.AccessToken) {
+			t.Errorf("refresh after access expiry = %d", code)
+		}
+	})
+
+	t.Run("expired refresh token", func(t *testing.T) {
+		accessTokenTTL, refreshTokenTTL = time.Minute, -time.Second
+		pair := newSession(t, "expiry-refresh")
+		if code, _ := refresh(t, pair.RefreshToken); code != http.StatusUnauthorized {
+			t.Errorf("refresh with expired token = %d, want 401", code)
+		}
+		if sessions, _ := listSessions("expiry-refresh"); len(sessions) != 0 {
+			t.Errorf("expired session listed: %+v", sessions)
+		}
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	pair := newSession(t, "revoke-user")
+	_, rotated := refresh(t, pair.RefreshToken)
+	other := newSession(t, "revoke-user")
+	sess := sessionOf(t, rotated)
+
+	if err := revokeSession(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{pair.AccessToken, rotated.AccessToken} {
+		if exists, _ := dataStore.Get(store.BucketTokens, tokenKey(token), &authToken{}); exists {
+			t.Error("access token of the revoked session still stored")
+		}
+	}
+	// 轮换前后的刷新令牌都被删除
+	for _, token := range []string{pair.RefreshToken, rotated.RefreshToken} {
+		if exists, _ := dataStore.Get(bucketRefreshTokens, tokenKey(token), &refreshRecord{}); exists {
+			t.Error("refresh token of the revoked session still stored")
+		}
+	}
+	if exists, _ := dataStore.Get(bucketSessions, sess.ID, &session{}); exists {
+		t.Error("session still stored")
+	}
+	// 同一用户的其他会话不受影响
+	if !tokenValid(other.AccessToken) {
+		t.Error("other session was revoked")
+	}
+}
+
+func TestSweepExpiredTokens(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	entries := []struct {
+		bucket string
+		key    string
+		value  interface{}
+		keep   bool
+	}{
+		{store.BucketTokens, "sweep-token-old", authToken{Username: "sweep", ExpiresAt: past}, false},
+		{store.BucketTokens, "sweep-token-new", authToken{Username: "sweep", ExpiresAt: future}, true},
+		{bucketRefreshTokens, "sweep-refresh-old", refreshRecord{SessionID: "sweep-old", ExpiresAt: past}, false},
+		{bucketRefreshTokens, "sweep-refresh-new", refreshRecord{SessionID: "sweep-new", ExpiresAt: future}, true},
+		{bucketSessions, "sweep-old", session{ID: "sweep-old", Username: "sweep", ExpiresAt: past}, false},
+		{bucketSessions, "sweep-new", session{ID: "sweep-new", Username: "sweep", ExpiresAt: future}, true},
+	}
+	for _, e := range entries {
+		if err := dataStore.Put(e.bucket, e.key, e.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sweepExpiredTokens()
+
+	for _, e := range entries {
+		var raw json.RawMessage
+		if exists, _ := dataStore.Get(e.bucket, e.key, &raw); exists != e.keep {
+			t.Errorf("%s/%s exists = %v, want %v", e.bucket, e.key, exists, e.keep)
+		}
+	}
+}
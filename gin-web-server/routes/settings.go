@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+)
+
+// 运行时可由管理员修改的安全设置，保存在存储中，多个实例共享同一存储时同时生效
+const (
+	bucketSettings     = "settings"
+	securitySettingKey = "security"
+)
+
+type securitySettings struct {
+	// Require2FA 所有用户必须启用两步验证，未启用的用户登录时需先完成绑定
+	Require2FA bool `json:"require2FA"`
+}
+
+func loadSecuritySettings() securitySettings {
+	var settings securitySettings
+	if _, err := dataStore.Get(bucketSettings, securitySettingKey, &settings); err != nil {
+		log.Printf("读取安全设置失败: %v", err)
+	}
+	return settings
+}
+
+// setupAdminSettingsRoutes 管理员安全设置接口
+func setupAdminSettingsRoutes(admin *gin.RouterGroup) {
+	admin.GET("/settings", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "settings": loadSecuritySettings()})
+	})
+
+	admin.PATCH("/settings", func(c *gin.Context) {
+		var req struct {
+			Require2FA *bool `json:"require2FA"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		settings := loadSecuritySettings()
+		if req.Require2FA != nil {
+			settings.Require2FA = *req.Require2FA
+		}
+		if err := dataStore.Put(bucketSettings, securitySettingKey, settings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存设置失败"})
+			return
+		}
+		audit.Log(c, auditSettingsUpdate, securitySettingKey, audit.ResultSuccess, fmt.Sprintf("require2FA=%v", settings.Require2FA))
+		c.JSON(http.StatusOK, gin.H{"success": true, "settings": settings})
+	})
+}
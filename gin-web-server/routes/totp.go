@@ -0,0 +1,527 @@
+package routes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lf-web-tools/gin-web-server/audit"
+	"github.com/lf-web-tools/gin-web-server/store"
+	"github.com/skip2/go-qrcode"
+)
+
+// RFC 6238 TOTP两步验证：HMAC-SHA1、30秒步长、6位数字，允许前后各一个步长的时钟误差。
+// 启用两步验证的用户登录分两步：密码校验通过后拿到一次性的challengeToken，
+// 再用challengeToken加动态码（或恢复码）换取会话令牌。
+const (
+	totpIssuer = "LF Web Tools"
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1
+
+	recoveryCodeCount = 10
+
+	bucketLoginChallenges     = "login_challenges"
+	loginChallengeTTL         = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
+)
+
+var (
+	// challengeStore 登录第二步的挑战，生命周期短，保存在内存中
+	challengeStore store.Store = store.NewMemoryStore()
+
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// loginChallenge 密码校验通过后等待第二步验证的登录
+type loginChallenge struct {
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Attempts  int       `json:"attempts"`
+	// Setup 为true表示管理员要求两步验证而用户尚未绑定，需先完成绑定
+	Setup         bool   `json:"setup"`
+	PendingSecret string `json:"pendingSecret,omitempty"`
+}
+
+func generateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+func totpCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// validateTOTP 校验动态码，返回匹配的时间步；不接受不晚于lastStep的时间步，防止动态码被重放
+func validateTOTP(secret, code string, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpURI(username, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + username)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// totpEnrollment 返回给客户端的绑定信息，二维码供验证器App扫描
+func totpEnrollment(username, secret string) (gin.H, error) {
+	uri := totpURI(username, secret)
+	pngBytes, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"secret":     secret,
+		"otpauthUrl": uri,
+		"qrCode":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngBytes),
+	}, nil
+}
+
+// generateRecoveryCodes 生成一次性恢复码，返回明文（只展示一次）和用于保存的摘要
+func generateRecoveryCodes() ([]string, []string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	hashed := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := randomString(10)
+		if err != nil {
+			return nil, nil, err
+		}
+		code = code[:5] + "-" + code[5:]
+		plain = append(plain, code)
+		hashed = append(hashed, tokenKey(code))
+	}
+	return plain, hashed, nil
+}
+
+// verifySecondFactor 校验动态码或恢复码，成功时更新记录中的时间步或消耗恢复码，由调用方保存
+func verifySecondFactor(record *userRecord, code, recoveryCode string) bool {
+	if code != "" {
+		step, ok := validateTOTP(record.TOTPSecret, strings.TrimSpace(code), record.TOTPLastStep)
+		if ok {
+			record.TOTPLastStep = step
+		}
+		return ok
+	}
+
+	if recoveryCode != "" {
+		hashed := tokenKey(strings.ToUpper(strings.TrimSpace(recoveryCode)))
+		for i, candidate := range record.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(candidate), []byte(hashed)) == 1 {
+				record.RecoveryCodes = append(record.RecoveryCodes[:i:i], record.RecoveryCodes[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enableTOTP 启用两步验证并生成新的恢复码
+func enableTOTP(record *userRecord, secret string, step int64) ([]string, error) {
+	plain, hashed, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	record.TOTPSecret = secret
+	record.TOTPEnabled = true
+	record.TOTPPendingSecret = ""
+	record.TOTPLastStep = step
+	record.RecoveryCodes = hashed
+	record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	return plain, nil
+}
+
+// createLoginChallenge 创建登录第二步的挑战
+func createLoginChallenge(username string, setup bool) (string, error) {
+	// 挑战令牌可以换取登录会话，随机数不可用时直接失败
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	err = challengeStore.Put(bucketLoginChallenges, tokenKey(token), loginChallenge{
+		Username:  username,
+		ExpiresAt: time.Now().Add(loginChallengeTTL),
+		Setup:     setup,
+	})
+	return token, err
+}
+
+// loadLoginChallenge 读取有效的登录挑战
+func loadLoginChallenge(token string) (loginChallenge, bool) {
+	var challenge loginChallenge
+	exists, err := challengeStore.Get(bucketLoginChallenges, tokenKey(token), &challenge)
+	if err != nil || !exists {
+		return loginChallenge{}, false
+	}
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= loginChallengeMaxAttempts {
+		_ = challengeStore.Delete(bucketLoginChallenges, tokenKey(token))
+		return loginChallenge{}, false
+	}
+	return challenge, true
+}
+
+// setupTwoFactorRoutes 两步验证相关接口
+func setupTwoFactorRoutes(auth *gin.RouterGroup, authed *gin.RouterGroup) {
+	// 管理员要求两步验证而用户尚未绑定时，用登录挑战获取绑定二维码
+	auth.POST("/login/2fa/setup", func(c *gin.Context) {
+		var req struct {
+			ChallengeToken string `json:"challengeToken"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		challengeMu.Lock()
+		defer challengeMu.Unlock()
+
+		challenge, ok := loadLoginChallenge(req.ChallengeToken)
+		if !ok || !challenge.Setup {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新登录"})
+			return
+		}
+		if challenge.PendingSecret == "" {
+			secret, err := generateTOTPSecret()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+				return
+			}
+			challenge.PendingSecret = secret
+			if err := challengeStore.Put(bucketLoginChallenges, tokenKey(req.ChallengeToken), challenge); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+				return
+			}
+		}
+
+		enrollment, err := totpEnrollment(challenge.Username, challenge.PendingSecret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成二维码失败"})
+			return
+		}
+		enrollment["success"] = true
+		c.JSON(http.StatusOK, enrollment)
+	})
+
+	// 登录第二步：提交动态码或恢复码换取会话令牌
+	auth.POST("/login/2fa", func(c *gin.Context) {
+		var req struct {
+			ChallengeToken string `json:"challengeToken"`
+			Code           string `json:"code"`
+			RecoveryCode   string `json:"recoveryCode"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		challengeMu.Lock()
+		challenge, ok := loadLoginChallenge(req.ChallengeToken)
+		if ok {
+			if wait := checkLoginLockout(challenge.Username, c.ClientIP()); wait > 0 {
+				challengeMu.Unlock()
+				audit.LogAs(c, challenge.Username, auditLogin2FA, challenge.Username, audit.ResultDenied, "登录已锁定")
+				abortLockedOut(c, wait)
+				return
+			}
+			challenge.Attempts++
+			_ = challengeStore.Put(bucketLoginChallenges, tokenKey(req.ChallengeToken), challenge)
+		}
+		challengeMu.Unlock()
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新登录"})
+			return
+		}
+
+		userMu.Lock()
+		record, exists := getUser(challenge.Username)
+		if !exists || record.Disabled {
+			userMu.Unlock()
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "账号不可用"})
+			return
+		}
+
+		var recoveryCodes []string
+		if challenge.Setup {
+			step, valid := validateTOTP(challenge.PendingSecret, strings.TrimSpace(req.Code), 0)
+			if challenge.PendingSecret == "" || !valid {
+				userMu.Unlock()
+				recordLoginFailure(challenge.Username, c.ClientIP())
+				audit.LogAs(c, challenge.Username, auditLogin2FA, challenge.Username, audit.ResultFailure, "动态码错误")
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "动态码错误"})
+				return
+			}
+			codes, err := enableTOTP(&record, challenge.PendingSecret, step)
+			if err != nil {
+				userMu.Unlock()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+				return
+			}
+			recoveryCodes = codes
+		} else if !verifySecondFactor(&record, req.Code, req.RecoveryCode) {
+			userMu.Unlock()
+			recordLoginFailure(challenge.Username, c.ClientIP())
+			audit.LogAs(c, challenge.Username, auditLogin2FA, challenge.Username, audit.ResultFailure, "动态码或恢复码错误")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "动态码或恢复码错误"})
+			return
+		}
+
+		err := dataStore.Put(store.BucketUsers, record.Username, record)
+		userMu.Unlock()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败，请稍后重试"})
+			return
+		}
+		_ = challengeStore.Delete(bucketLoginChallenges, tokenKey(req.ChallengeToken))
+		clearUserLockout(record.Username)
+
+		pair, err := createSession(c, record.Username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败，请稍后重试"})
+			return
+		}
+		detail := ""
+		if challenge.Setup {
+			detail = "登录时完成两步验证绑定"
+		} else if req.RecoveryCode != "" {
+			detail = "使用恢复码"
+		}
+		audit.LogAs(c, record.Username, auditLogin2FA, record.Username, audit.ResultSuccess, detail)
+		response := pair.response()
+		response["success"] = true
+		response["user"] = record.Username
+		if recoveryCodes != nil {
+			response["recoveryCodes"] = recoveryCodes
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	// 已登录用户开始绑定：生成待确认的密钥
+	authed.POST("/2fa/setup", func(c *gin.Context) {
+		secret, err := generateTOTPSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+			return
+		}
+
+		username := CurrentUser(c)
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		if record.TOTPEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "已启用两步验证"})
+			return
+		}
+		record.TOTPPendingSecret = secret
+		if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+			return
+		}
+
+		enrollment, err := totpEnrollment(username, secret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成二维码失败"})
+			return
+		}
+		enrollment["success"] = true
+		c.JSON(http.StatusOK, enrollment)
+	})
+
+	// 用验证器App上的动态码确认绑定，返回恢复码
+	authed.POST("/2fa/confirm", func(c *gin.Context) {
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		username := CurrentUser(c)
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists || record.TOTPPendingSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请先获取绑定二维码"})
+			return
+		}
+		step, ok := validateTOTP(record.TOTPPendingSecret, strings.TrimSpace(req.Code), 0)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "动态码错误"})
+			return
+		}
+		recoveryCodes, err := enableTOTP(&record, record.TOTPPendingSecret, step)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+			return
+		}
+		if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+			return
+		}
+		audit.Log(c, auditTwoFactorEnable, username, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true, "recoveryCodes": recoveryCodes})
+	})
+
+	// 重新生成恢复码，旧恢复码全部作废
+	authed.POST("/2fa/recovery-codes", func(c *gin.Context) {
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+
+		username := CurrentUser(c)
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists || !record.TOTPEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未启用两步验证"})
+			return
+		}
+		// 令牌泄露时动态码是最后一道防线，错误次数计入登录锁定，防止暴力尝试6位动态码
+		if wait := checkLoginLockout(username, c.ClientIP()); wait > 0 {
+			abortLockedOut(c, wait)
+			return
+		}
+		if !verifySecondFactor(&record, req.Code, "") {
+			recordLoginFailure(username, c.ClientIP())
+			c.JSON(http.StatusBadRequest, gin.H{"error": "动态码错误"})
+			return
+		}
+		plain, hashed, err := generateRecoveryCodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成恢复码失败"})
+			return
+		}
+		record.RecoveryCodes = hashed
+		if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+			return
+		}
+		audit.Log(c, auditRecoveryCodes, username, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true, "recoveryCodes": plain})
+	})
+
+	authed.POST("/2fa/disable", func(c *gin.Context) {
+		var req struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效"})
+			return
+		}
+		if loadSecuritySettings().Require2FA {
+			c.JSON(http.StatusForbidden, gin.H{"error": "管理员要求所有账号启用两步验证"})
+			return
+		}
+
+		username := CurrentUser(c)
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists || !record.TOTPEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未启用两步验证"})
+			return
+		}
+		if wait := checkLoginLockout(username, c.ClientIP()); wait > 0 {
+			abortLockedOut(c, wait)
+			return
+		}
+		if !confirmIdentity(c, record, req.Password) || !verifySecondFactor(&record, req.Code, "") {
+			recordLoginFailure(username, c.ClientIP())
+			audit.Log(c, auditTwoFactorDisable, username, audit.ResultFailure, "密码或动态码错误")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "密码或动态码错误"})
+			return
+		}
+		clearTOTP(&record)
+		if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+			return
+		}
+		audit.Log(c, auditTwoFactorDisable, username, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+}
+
+// setupAdminTwoFactorRoutes 用户丢失验证器时由管理员解除绑定
+func setupAdminTwoFactorRoutes(admin *gin.RouterGroup) {
+	admin.DELETE("/users/:username/2fa", func(c *gin.Context) {
+		username := c.Param("username")
+		userMu.Lock()
+		defer userMu.Unlock()
+
+		record, exists := getUser(username)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		clearTOTP(&record)
+		if err := dataStore.Put(store.BucketUsers, username, record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+			return
+		}
+		_ = deleteUserTokens(username)
+		audit.Log(c, auditUserReset2FA, username, audit.ResultSuccess, "")
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+}
+
+func clearTOTP(record *userRecord) {
+	record.TOTPSecret = ""
+	record.TOTPEnabled = false
+	record.TOTPPendingSecret = ""
+	record.TOTPLastStep = 0
+	record.RecoveryCodes = nil
+	record.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+}
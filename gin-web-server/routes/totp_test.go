@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// TestTOTPCodeRFC6238 RFC 6238附录B的SHA-1测试向量，取8位结果的后6位
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(secret, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+	current := time.Now().Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		want     bool
+	}{
+		{"current step", secret, totpCode(key, current), 0, true},
+		{"previous step within skew", secret, totpCode(key, current-totpSkew), 0, true},
+		{"next step within skew", secret, totpCode(key, current+totpSkew), 0, true},
+		{"outside skew", secret, totpCode(key, current-totpSkew-1), 0, false},
+		{"lowercase secret", strings.ToLower(secret), totpCode(key, current), 0, true},
+		{"replayed step", secret, totpCode(key, current), current, false},
+		{"earlier than last step", secret, totpCode(key, current-1), current - 1, false},
+		{"wrong length", secret, totpCode(key, current)[:5], 0, false},
+		{"invalid secret", "!!!", totpCode(key, current), 0, false},
+	}
+	for _, tt := range tests {
+		step, ok := validateTOTP(tt.secret, tt.code, tt.lastStep)
+		if ok != tt.want {
+			t.Errorf("%s: validateTOTP = %v, want %v", tt.name, ok, tt.want)
+		}
+		if ok && (step <= tt.lastStep || step < current-totpSkew || step > current+totpSkew) {
+			t.Errorf("%s: step = %d", tt.name, step)
+		}
+	}
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	secret, _ := generateTOTPSecret()
+	key, _ := totpEncoding.DecodeString(secret)
+	record := userRecord{Username: "second-factor"}
+	codes, err := enableTOTP(&record, secret, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || len(record.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("recovery codes = %d, stored %d", len(codes), len(record.RecoveryCodes))
+	}
+
+	// 动态码使用后记录时间步，同一动态码不能再次使用
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+	if !verifySecondFactor(&record, " "+code+" ", "") {
+		t.Fatal("valid code rejected")
+	}
+	if record.TOTPLastStep == 0 {
+		t.Error("TOTPLastStep not updated")
+	}
+	if verifySecondFactor(&record, code, "") {
+		t.Error("replayed code accepted")
+	}
+
+	// 恢复码不区分大小写，只能使用一次
+	if !verifySecondFactor(&record, "", strings.ToLower(codes[3])) {
+		t.Fatal("valid recovery code rejected")
+	}
+	if len(record.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Errorf("recovery codes left = %d", len(record.RecoveryCodes))
+	}
+	if verifySecondFactor(&record, "", codes[3]) {
+		t.Error("recovery code accepted twice")
+	}
+	if !verifySecondFactor(&record, "", codes[4]) {
+		t.Error("other recovery codes were consumed")
+	}
+	if verifySecondFactor(&record, "", "") || verifySecondFactor(&record, "", "XXXXX-XXXXX") {
+		t.Error("empty or unknown recovery code accepted")
+	}
+}
+
+func TestLoginChallengeAttemptLimit(t *testing.T) {
+	resetLockouts(t)
+	// 提高账号锁定阈值，只验证挑战自身的次数限制
+	defer func(threshold int) { lockoutConfig.UserThreshold = threshold }(lockoutConfig.UserThreshold)
+	lockoutConfig.UserThreshold = 100
+
+	secret, _ := generateTOTPSecret()
+	key, _ := totpEncoding.DecodeString(secret)
+	record := userRecord{Username: "challenge-user", Role: RoleUser}
+	if _, err := enableTOTP(&record, secret, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := dataStore.Put(store.BucketUsers, record.Username, record); err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(challenge, code string) int {
+		body, _ := json.Marshal(map[string]string{"challengeToken": challenge, "code": code})
+		return serve(http.MethodPost, "/api/auth/login/2fa", string(body), nil).Code
+	}
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+
+	challenge, err := createLoginChallenge(record.Username, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < loginChallengeMaxAttempts; i++ {
+		if got := submit(challenge, "000000"); got != http.StatusUnauthorized {
+			t.Fatalf("attempt %d = %d, want 401", i+1, got)
+		}
+	}
+	// 次数用完后挑战作废，正确的动态码也不能登录
+	if got := submit(challenge, code); got != http.StatusUnauthorized {
+		t.Errorf("correct code after %d failures = %d, want 401", loginChallengeMaxAttempts, got)
+	}
+	if _, ok := loadLoginChallenge(challenge); ok {
+		t.Error("challenge still usable")
+	}
+
+	// 新的挑战可以正常登录，挑战只能使用一次
+	challenge, _ = createLoginChallenge(record.Username, false)
+	if got := submit(challenge, code); got != http.StatusOK {
+		t.Fatalf("login with a new challenge = %d", got)
+	}
+	if got := submit(challenge, code); got != http.StatusUnauthorized {
+		t.Errorf("reused challenge = %d, want 401", got)
+	}
+}
@@ -3,25 +3,69 @@ package routes
 import (
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+// 浏览器的WebSocket无法自定义请求头，令牌放在子协议中传递：new WebSocket(url, ["lf-web-tools", "token." + token])。
+// 服务端只回应wsProtocol，令牌不会出现在地址栏、访问日志和响应中
+const (
+	wsProtocol            = "lf-web-tools"
+	wsTokenProtocolPrefix = "token."
+)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// 允许所有CORS请求
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	Subprotocols:    []string{wsProtocol},
+	CheckOrigin:     checkWebSocketOrigin,
 }
 
-// SetupWebSocketRoutes 设置WebSocket相关的路由
-func SetupWebSocketRoutes(r *gin.Engine) {
+// wsAllowedOrigins 允许发起WebSocket连接的其他站点，由SetupWebSocketRoutes按配置设置
+var wsAllowedOrigins []string
+
+// SetupWebSocketRoutes 设置WebSocket相关的路由，allowedOrigins为同源之外允许连接的站点
+func SetupWebSocketRoutes(r *gin.Engine, allowedOrigins []string) {
+	wsAllowedOrigins = allowedOrigins
 	r.GET("/ws", handleWebSocket)
 }
 
+// checkWebSocketOrigin 只允许同源页面、配置中的站点和不发送Origin的非浏览器客户端，
+// 防止其他网站在用户浏览器中冒用其令牌建立连接
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range wsAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	log.Printf("[WS] 拒绝来自 %s 的WebSocket连接", origin)
+	return false
+}
+
+// websocketToken 从握手请求的子协议中读取令牌
+func websocketToken(r *http.Request) string {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if strings.HasPrefix(protocol, wsTokenProtocolPrefix) {
+			return strings.TrimPrefix(protocol, wsTokenProtocolPrefix)
+		}
+	}
+	return ""
+}
+
 func handleWebSocket(c *gin.Context) {
 	// 将HTTP连接升级为WebSocket连接
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
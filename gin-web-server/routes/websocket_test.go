@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketAuthAndOrigin(t *testing.T) {
+	server := httptest.NewServer(testRouter)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	token := loginAs(t, "ws-user", RoleUser)
+	tokenProtocols := []string{wsProtocol, wsTokenProtocolPrefix + token}
+
+	tests := []struct {
+		name      string
+		query     string
+		protocols []string
+		origin    string
+		want      int
+	}{
+		{"token in subprotocol", "", tokenProtocols, "", http.StatusSwitchingProtocols},
+		{"same origin", "", tokenProtocols, server.URL, http.StatusSwitchingProtocols},
+		{"configured origin", "", tokenProtocols, "https://allowed.example.com", http.StatusSwitchingProtocols},
+		{"other origin", "", tokenProtocols, "https://evil.example.com", http.StatusForbidden},
+		{"no token", "", []string{wsProtocol}, "", http.StatusUnauthorized},
+		{"token in query", "?token=" + token, nil, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			dialer := websocket.Dialer{Subprotocols: tt.protocols}
+			conn, resp, err := dialer.Dial(wsURL+tt.query, header)
+			if resp == nil {
+				t.Fatalf("dial: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d (%v)", resp.StatusCode, tt.want, err)
+			}
+			if conn == nil {
+				return
+			}
+			defer conn.Close()
+
+			// 服务端只回应固定的子协议，不回显令牌
+			if conn.Subprotocol() != wsProtocol {
+				t.Errorf("subprotocol = %q, want %q", conn.Subprotocol(), wsProtocol)
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			if _, message, err := conn.ReadMessage(); err != nil || string(message) != "ping" {
+				t.Errorf("echo = %q, %v", message, err)
+			}
+		})
+	}
+}
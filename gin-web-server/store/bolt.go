@@ -0,0 +1,122 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltFileName = "lf-web-tools.db"
+
+// BoltStore 基于bbolt的嵌入式KV存储，按记录增量写入，适合用户和令牌较多的场景
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore 打开或创建bbolt数据库文件
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Get 读取记录
+func (s *BoltStore) Get(bucket, key string, v interface{}) (bool, error) {
+	var raw []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if value := b.Get([]byte(key)); value != nil {
+			// bbolt返回的切片只在事务内有效
+			raw = append([]byte(nil), value...)
+		}
+		return nil
+	})
+	if err != nil || raw == nil {
+		return false, err
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Put 写入或覆盖记录
+func (s *BoltStore) Put(bucket, key string, v interface{}) error {
+	raw, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), raw)
+	})
+}
+
+// Create 仅在记录不存在时写入
+func (s *BoltStore) Create(bucket, key string, v interface{}) error {
+	raw, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(key)) != nil {
+			return ErrExists
+		}
+		return b.Put([]byte(key), raw)
+	})
+}
+
+// Delete 删除记录
+func (s *BoltStore) Delete(bucket, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// ForEach 遍历记录，先复制快照再回调，回调中可以安全地读写存储
+func (s *BoltStore) ForEach(bucket string, fn func(key string, raw []byte) error) error {
+	type record struct {
+		key string
+		raw []byte
+	}
+	var records []record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			records = append(records, record{key: string(k), raw: append([]byte(nil), v...)})
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if err := fn(r.key, r.raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 关闭数据库文件
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
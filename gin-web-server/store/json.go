@@ -0,0 +1,72 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// NewJSONStore 创建JSON文件存储，每个bucket对应目录下的一个<bucket>.json文件，
+// 每次写入都会重写整个文件，适合数据量较小的单机部署
+func NewJSONStore(dir string) *MemoryStore {
+	s := NewMemoryStore()
+	s.load = func(bucket string) (map[string][]byte, error) {
+		return loadJSONFile(bucketFile(dir, bucket))
+	}
+	s.persist = func(bucket string, data map[string][]byte) error {
+		return writeJSONFile(bucketFile(dir, bucket), data)
+	}
+	return s
+}
+
+func bucketFile(dir, bucket string) string {
+	return filepath.Join(dir, bucket+".json")
+}
+
+func loadJSONFile(path string) (map[string][]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records map[string]json.RawMessage
+	if err := json.Unmarshal(content, &records); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(records))
+	for k, v := range records {
+		data[k] = []byte(v)
+	}
+	return data, nil
+}
+
+func writeJSONFile(path string, data map[string][]byte) error {
+	records := make(map[string]json.RawMessage, len(data))
+	for k, v := range data {
+		records[k] = json.RawMessage(v)
+	}
+
+	content, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// 文件中有密码哈希和令牌，只允许服务进程的用户读写
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, content, 0600); err != nil {
+		return err
+	}
+	// WriteFile不修改已存在文件的权限，残留的临时文件可能是旧版本以0644创建的
+	if err := os.Chmod(tmpFile, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
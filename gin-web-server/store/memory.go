@@ -0,0 +1,165 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// MemoryStore 内存存储，进程重启后数据丢失，适合验证码等短期数据
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+
+	// load/persist 供JSONStore复用内存结构，分别在首次访问bucket和写入后调用
+	load    func(bucket string) (map[string][]byte, error)
+	persist func(bucket string, data map[string][]byte) error
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]map[string][]byte)}
+}
+
+// bucketLocked 获取bucket数据，调用方需持有锁
+func (s *MemoryStore) bucketLocked(bucket string) (map[string][]byte, error) {
+	data, ok := s.buckets[bucket]
+	if ok {
+		return data, nil
+	}
+
+	data = make(map[string][]byte)
+	if s.load != nil {
+		loaded, err := s.load(bucket)
+		if err != nil {
+			return nil, err
+		}
+		if loaded != nil {
+			data = loaded
+		}
+	}
+	s.buckets[bucket] = data
+	return data, nil
+}
+
+func (s *MemoryStore) persistLocked(bucket string, data map[string][]byte) error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist(bucket, data)
+}
+
+// Get 读取记录
+func (s *MemoryStore) Get(bucket, key string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.bucketLocked(bucket)
+	if err != nil {
+		return false, err
+	}
+	raw, ok := data[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Put 写入或覆盖记录，持久化失败时回滚
+func (s *MemoryStore) Put(bucket, key string, v interface{}) error {
+	raw, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.bucketLocked(bucket)
+	if err != nil {
+		return err
+	}
+	previous, existed := data[key]
+	data[key] = raw
+	if err := s.persistLocked(bucket, data); err != nil {
+		if existed {
+			data[key] = previous
+		} else {
+			delete(data, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// Create 仅在记录不存在时写入
+func (s *MemoryStore) Create(bucket, key string, v interface{}) error {
+	raw, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.bucketLocked(bucket)
+	if err != nil {
+		return err
+	}
+	if _, exists := data[key]; exists {
+		return ErrExists
+	}
+	data[key] = raw
+	if err := s.persistLocked(bucket, data); err != nil {
+		delete(data, key)
+		return err
+	}
+	return nil
+}
+
+// Delete 删除记录
+func (s *MemoryStore) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.bucketLocked(bucket)
+	if err != nil {
+		return err
+	}
+	previous, exists := data[key]
+	if !exists {
+		return nil
+	}
+	delete(data, key)
+	if err := s.persistLocked(bucket, data); err != nil {
+		data[key] = previous
+		return err
+	}
+	return nil
+}
+
+// ForEach 遍历记录，遍历的是快照，回调中可以安全地读写存储
+func (s *MemoryStore) ForEach(bucket string, fn func(key string, raw []byte) error) error {
+	s.mu.Lock()
+	data, err := s.bucketLocked(bucket)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	snapshot := make(map[string][]byte, len(data))
+	for k, v := range data {
+		snapshot[k] = v
+	}
+	s.mu.Unlock()
+
+	for k, v := range snapshot {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 内存存储无需释放资源
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+// 内置的数据分组
+const (
+	BucketUsers  = "users"
+	BucketTokens = "tokens"
+)
+
+var (
+	// ErrExists Create写入的键已存在
+	ErrExists = errors.New("记录已存在")
+)
+
+// Store 键值存储接口，数据按bucket分组，值以JSON编码保存
+type Store interface {
+	// Get 读取记录并解码到v，记录不存在时返回false
+	Get(bucket, key string, v interface{}) (bool, error)
+	// Put 写入或覆盖记录
+	Put(bucket, key string, v interface{}) error
+	// Create 仅在记录不存在时写入，已存在返回ErrExists
+	Create(bucket, key string, v interface{}) error
+	// Delete 删除记录，记录不存在时不报错
+	Delete(bucket, key string) error
+	// ForEach 遍历bucket中的所有记录，raw为JSON编码的值
+	ForEach(bucket string, fn func(key string, raw []byte) error) error
+	// Close 释放底层资源
+	Close() error
+}
+
+// Open 按配置打开存储后端，非JSON后端首次启动时会导入已有的JSON文件数据
+func Open(cfg config.StorageConfig) (Store, error) {
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Backend {
+	case "", config.StorageJSON:
+		return NewJSONStore(cfg.Dir), nil
+	case config.StorageBolt:
+		s, err := NewBoltStore(filepath.Join(cfg.Dir, boltFileName))
+		if err != nil {
+			return nil, err
+		}
+		if err := migrateFromJSON(cfg.Dir, s); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("迁移JSON数据失败: %v", err)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("不支持的存储后端: %s", cfg.Backend)
+	}
+}
+
+// migrateFromJSON 当目标存储中没有用户时，把JSON文件中的用户和令牌导入进来
+func migrateFromJSON(dir string, dst Store) error {
+	empty := true
+	err := dst.ForEach(BucketUsers, func(key string, raw []byte) error {
+		empty = false
+		return errStopIteration
+	})
+	if err != nil && err != errStopIteration {
+		return err
+	}
+	if !empty {
+		return nil
+	}
+
+	src := NewJSONStore(dir)
+	for _, bucket := range []string{BucketUsers, BucketTokens} {
+		err := src.ForEach(bucket, func(key string, raw []byte) error {
+			return dst.Put(bucket, key, rawValue(raw))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errStopIteration 用于提前结束ForEach
+var errStopIteration = errors.New("stop iteration")
+
+// rawValue 已编码的JSON值，写入时不再重复编码
+type rawValue []byte
+
+func encodeValue(v interface{}) ([]byte, error) {
+	if raw, ok := v.(rawValue); ok {
+		return []byte(raw), nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+)
+
+type testRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// backends 每种存储后端的构造方法，reopen为true时检查关闭后重新打开仍能读到数据
+var backends = []struct {
+	name   string
+	open   func(t *testing.T, dir string) Store
+	reopen bool
+}{
+	{"memory", func(t *testing.T, dir string) Store { return NewMemoryStore() }, false},
+	{"json", func(t *testing.T, dir string) Store { return NewJSONStore(dir) }, true},
+	{"bolt", func(t *testing.T, dir string) Store {
+		s, err := NewBoltStore(filepath.Join(dir, boltFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}, true},
+}
+
+func TestStoreConformance(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := backend.open(t, dir)
+			defer func() { s.Close() }()
+
+			var got testRecord
+			if exists, err := s.Get("items", "missing", &got); exists || err != nil {
+				t.Fatalf("Get(missing) = %v, %v", exists, err)
+			}
+
+			if err := s.Put("items", "a", testRecord{Name: "a", Count: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if exists, err := s.Get("items", "a", &got); !exists || err != nil || got != (testRecord{Name: "a", Count: 1}) {
+				t.Fatalf("Get(a) = %v, %+v, %v", exists, got, err)
+			}
+			if err := s.Put("items", "a", testRecord{Name: "a", Count: 2}); err != nil {
+				t.Fatal(err)
+			}
+			if s.Get("items", "a", &got); got.Count != 2 {
+				t.Errorf("Put did not overwrite: %+v", got)
+			}
+
+			if err := s.Create("items", "a", testRecord{Name: "dup"}); !errors.Is(err, ErrExists) {
+				t.Errorf("Create(existing) = %v, want ErrExists", err)
+			}
+			if err := s.Create("items", "b", testRecord{Name: "b"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Put("other", "a", testRecord{Name: "other"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Put("items", "raw", rawValue(`{"name":"raw","count":7}`)); err != nil {
+				t.Fatal(err)
+			}
+			if s.Get("items", "raw", &got); got != (testRecord{Name: "raw", Count: 7}) {
+				t.Errorf("rawValue was encoded twice: %+v", got)
+			}
+
+			if keys := forEachKeys(t, s, "items"); !reflect.DeepEqual(keys, []string{"a", "b", "raw"}) {
+				t.Errorf("ForEach(items) keys = %v", keys)
+			}
+			if keys := forEachKeys(t, s, "empty"); len(keys) != 0 {
+				t.Errorf("ForEach(empty) keys = %v", keys)
+			}
+			stop := errors.New("stop")
+			if err := s.ForEach("items", func(key string, raw []byte) error { return stop }); err != stop {
+				t.Errorf("ForEach did not return the callback error: %v", err)
+			}
+			// 回调中可以写入同一个存储
+			err := s.ForEach("items", func(key string, raw []byte) error {
+				return s.Put("copy", key, rawValue(raw))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys := forEachKeys(t, s, "copy"); len(keys) != 3 {
+				t.Errorf("ForEach(copy) keys = %v", keys)
+			}
+
+			if err := s.Delete("items", "b"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Delete("items", "missing"); err != nil {
+				t.Errorf("Delete(missing) = %v", err)
+			}
+			if err := s.Delete("no-bucket", "a"); err != nil {
+				t.Errorf("Delete in missing bucket = %v", err)
+			}
+			if exists, _ := s.Get("items", "b", &got); exists {
+				t.Error("deleted record still exists")
+			}
+
+			if !backend.reopen {
+				return
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			s = backend.open(t, dir)
+			if keys := forEachKeys(t, s, "items"); !reflect.DeepEqual(keys, []string{"a", "raw"}) {
+				t.Errorf("keys after reopen = %v", keys)
+			}
+			if s.Get("other", "a", &got); got.Name != "other" {
+				t.Errorf("other bucket after reopen = %+v", got)
+			}
+		})
+	}
+}
+
+func TestJSONStorePermissions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	s, err := Open(config.StorageConfig{Backend: config.StorageJSON, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// 旧版本留下的临时文件权限为0644
+	path := bucketFile(dir, BucketUsers)
+	if err := os.WriteFile(path+".tmp", []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(BucketUsers, "admin", testRecord{Name: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]os.FileMode{dir: 0700, path: 0600} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %o, want %o", name, got, want)
+		}
+	}
+}
+
+func TestMigrateJSONToBolt(t *testing.T) {
+	dir := t.TempDir()
+	src := NewJSONStore(dir)
+	src.Put(BucketUsers, "admin", testRecord{Name: "admin", Count: 1})
+	src.Put(BucketUsers, "alice", testRecord{Name: "alice", Count: 2})
+	src.Put(BucketTokens, "t1", testRecord{Name: "token"})
+	src.Put("captchas", "c1", testRecord{Name: "not migrated"})
+
+	dst, err := Open(config.StorageConfig{Backend: config.StorageBolt, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := forEachKeys(t, dst, BucketUsers); !reflect.DeepEqual(keys, []string{"admin", "alice"}) {
+		t.Errorf("migrated users = %v", keys)
+	}
+	var got testRecord
+	if dst.Get(BucketUsers, "alice", &got); got != (testRecord{Name: "alice", Count: 2}) {
+		t.Errorf("migrated alice = %+v", got)
+	}
+	if keys := forEachKeys(t, dst, BucketTokens); len(keys) != 1 {
+		t.Errorf("migrated tokens = %v", keys)
+	}
+	if keys := forEachKeys(t, dst, "captchas"); len(keys) != 0 {
+		t.Errorf("unexpected bucket migrated: %v", keys)
+	}
+	dst.Put(BucketUsers, "alice", testRecord{Name: "alice", Count: 3})
+	dst.Close()
+
+	// 库中已有用户时不再导入，JSON文件中的旧数据不会覆盖
+	src.Put(BucketUsers, "bob", testRecord{Name: "bob"})
+	dst, err = Open(config.StorageConfig{Backend: config.StorageBolt, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if keys := forEachKeys(t, dst, BucketUsers); !reflect.DeepEqual(keys, []string{"admin", "alice"}) {
+		t.Errorf("users after second open = %v", keys)
+	}
+	if dst.Get(BucketUsers, "alice", &got); got.Count != 3 {
+		t.Errorf("alice was overwritten by the JSON file: %+v", got)
+	}
+}
+
+func forEachKeys(t *testing.T, s Store, bucket string) []string {
+	t.Helper()
+	keys := []string{}
+	if err := s.ForEach(bucket, func(key string, raw []byte) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	return keys
+}
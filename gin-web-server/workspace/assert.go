@@ -0,0 +1,329 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
+)
+
+const (
+	maxAssertionsPerRequest  = 20
+	maxExtractionsPerRequest = 20
+	maxReportValueLength     = 200
+)
+
+// 断言和变量提取的类型
+const (
+	CheckStatus   = "status"
+	CheckHeader   = "header"
+	CheckJSONPath = "jsonPath"
+	CheckBody     = "body"
+	CheckLatency  = "latency"
+)
+
+// Assertion 对响应的断言，按Type使用不同的字段
+type Assertion struct {
+	Type    string          `json:"type"`
+	Name    string          `json:"name,omitempty"`    // header：响应头名称，不区分大小写
+	Path    string          `json:"path,omitempty"`    // jsonPath：取值路径，如 $.data.items[0].id
+	Pattern string          `json:"pattern,omitempty"` // body：响应体须匹配的正则表达式
+	Value   json.RawMessage `json:"value,omitempty"`   // status：200或"2xx"；header、jsonPath：期望的值，省略时只检查是否存在
+	MaxMs   int             `json:"maxMs,omitempty"`   // latency：最大耗时（毫秒）
+}
+
+// Extraction 从响应中提取变量，集合中后面的请求可以用{{variable}}引用
+type Extraction struct {
+	Variable string `json:"variable"`
+	Type     string `json:"type"`              // header、jsonPath或body
+	Name     string `json:"name,omitempty"`    // header：响应头名称
+	Path     string `json:"path,omitempty"`    // jsonPath：取值路径，取到的字符串原样使用，其他值使用JSON文本
+	Pattern  string `json:"pattern,omitempty"` // body：正则表达式，有捕获组时取第一个捕获组，否则取整个匹配
+}
+
+// AssertionResult 一条断言的检查结果
+type AssertionResult struct {
+	Type    string `json:"type"`
+	Target  string `json:"target,omitempty"` // 响应头名称、JSONPath或正则表达式
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// Exchange 一次请求的结果，用于检查断言和提取变量
+type Exchange struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       []byte
+	Latency    time.Duration
+
+	parsed  bool
+	doc     interface{}
+	docErr  error
+	headers map[string]string
+}
+
+// header 按名称取响应头，不区分大小写
+func (ex *Exchange) header(name string) (string, bool) {
+	if ex.headers == nil {
+		ex.headers = make(map[string]string, len(ex.Headers))
+		for key, value := range ex.Headers {
+			ex.headers[strings.ToLower(key)] = value
+		}
+	}
+	value, ok := ex.headers[strings.ToLower(name)]
+	return value, ok
+}
+
+// jsonValue 按路径从JSON响应体中取值，响应体只解析一次
+func (ex *Exchange) jsonValue(path string) (interface{}, error) {
+	if !ex.parsed {
+		ex.parsed = true
+		if ex.docErr = json.Unmarshal(ex.Body, &ex.doc); ex.docErr != nil {
+			ex.docErr = fmt.Errorf("响应体不是有效的JSON")
+		}
+	}
+	if ex.docErr != nil {
+		return nil, ex.docErr
+	}
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := p.lookup(ex.doc)
+	if !ok {
+		return nil, fmt.Errorf("%s不存在", path)
+	}
+	return value, nil
+}
+
+// validate 校验断言的字段
+func (a *Assertion) validate() error {
+	a.Name, a.Path = strings.TrimSpace(a.Name), strings.TrimSpace(a.Path)
+	switch a.Type {
+	case CheckStatus:
+		if _, err := expectedStatus(a.Value); err != nil {
+			return err
+		}
+	case CheckHeader:
+		if a.Name == "" {
+			return fmt.Errorf("缺少响应头名称name")
+		}
+		if len(a.Value) > 0 {
+			var s string
+			if json.Unmarshal(a.Value, &s) != nil {
+				return fmt.Errorf("响应头的期望值须为字符串")
+			}
+		}
+	case CheckJSONPath:
+		if _, err := parseJSONPath(a.Path); err != nil || a.Path == "" {
+			return fmt.Errorf("JSONPath无效: %s", a.Path)
+		}
+	case CheckBody:
+		if _, err := regexp.Compile(a.Pattern); err != nil || a.Pattern == "" {
+			return fmt.Errorf("正则表达式无效: %s", a.Pattern)
+		}
+	case CheckLatency:
+		if a.MaxMs <= 0 {
+			return fmt.Errorf("maxMs须大于0")
+		}
+	default:
+		return fmt.Errorf("类型无效: %s，须为status、header、jsonPath、body或latency", a.Type)
+	}
+	return nil
+}
+
+// validate 校验变量提取的字段
+func (e *Extraction) validate() error {
+	e.Variable = strings.TrimSpace(e.Variable)
+	e.Name, e.Path = strings.TrimSpace(e.Name), strings.TrimSpace(e.Path)
+	if !curlcmd.ValidVariableName(e.Variable) {
+		return fmt.Errorf("变量名无效: %s", e.Variable)
+	}
+	switch e.Type {
+	case CheckHeader:
+		if e.Name == "" {
+			return fmt.Errorf("缺少响应头名称name")
+		}
+	case CheckJSONPath:
+		if _, err := parseJSONPath(e.Path); err != nil || e.Path == "" {
+			return fmt.Errorf("JSONPath无效: %s", e.Path)
+		}
+	case CheckBody:
+		if _, err := regexp.Compile(e.Pattern); err != nil || e.Pattern == "" {
+			return fmt.Errorf("正则表达式无效: %s", e.Pattern)
+		}
+	default:
+		return fmt.Errorf("类型无效: %s，须为header、jsonPath或body", e.Type)
+	}
+	return nil
+}
+
+// Check 检查响应是否满足断言
+func (a Assertion) Check(ex *Exchange) AssertionResult {
+	result := AssertionResult{Type: a.Type}
+	switch a.Type {
+	case CheckStatus:
+		expected, _ := expectedStatus(a.Value)
+		result.Passed = statusMatches(expected, ex.StatusCode)
+		if result.Passed {
+			result.Message = fmt.Sprintf("状态码为%d", ex.StatusCode)
+		} else {
+			result.Message = fmt.Sprintf("期望状态码%s，实际为%d", expected, ex.StatusCode)
+		}
+
+	case CheckHeader:
+		result.Target = a.Name
+		actual, ok := ex.header(a.Name)
+		var expected string
+		switch {
+		case !ok:
+			result.Message = fmt.Sprintf("响应头%s不存在", a.Name)
+		case len(a.Value) == 0:
+			result.Passed, result.Message = true, fmt.Sprintf("响应头%s存在", a.Name)
+		case json.Unmarshal(a.Value, &expected) == nil && actual == expected:
+			result.Passed, result.Message = true, fmt.Sprintf("响应头%s为%s", a.Name, reportValue(actual))
+		default:
+			result.Message = fmt.Sprintf("期望响应头%s为%s，实际为%s", a.Name, reportValue(expected), reportValue(actual))
+		}
+
+	case CheckJSONPath:
+		result.Target = a.Path
+		actual, err := ex.jsonValue(a.Path)
+		if err != nil {
+			result.Message = err.Error()
+			break
+		}
+		actualText, _ := json.Marshal(actual)
+		if len(a.Value) == 0 {
+			result.Passed, result.Message = true, fmt.Sprintf("%s存在", a.Path)
+			break
+		}
+		var expected interface{}
+		if err := json.Unmarshal(a.Value, &expected); err != nil {
+			result.Message = "期望值不是有效的JSON"
+			break
+		}
+		if reflect.DeepEqual(actual, expected) {
+			result.Passed, result.Message = true, fmt.Sprintf("%s为%s", a.Path, reportValue(string(actualText)))
+		} else {
+			result.Message = fmt.Sprintf("期望%s为%s，实际为%s", a.Path, reportValue(string(a.Value)), reportValue(string(actualText)))
+		}
+
+	case CheckBody:
+		result.Target = a.Pattern
+		re, err := regexp.Compile(a.Pattern)
+		if err != nil {
+			result.Message = "正则表达式无效"
+			break
+		}
+		result.Passed = re.Match(ex.Body)
+		if result.Passed {
+			result.Message = "响应体匹配正则表达式"
+		} else {
+			result.Message = "响应体不匹配正则表达式"
+		}
+
+	case CheckLatency:
+		elapsed := ex.Latency.Milliseconds()
+		result.Passed = elapsed <= int64(a.MaxMs)
+		if result.Passed {
+			result.Message = fmt.Sprintf("耗时%dms，不超过%dms", elapsed, a.MaxMs)
+		} else {
+			result.Message = fmt.Sprintf("耗时%dms，超过%dms", elapsed, a.MaxMs)
+		}
+
+	default:
+		result.Message = "断言类型无效: " + a.Type
+	}
+	return result
+}
+
+// Extract 从响应中取出变量的值
+func (e Extraction) Extract(ex *Exchange) (string, error) {
+	switch e.Type {
+	case CheckHeader:
+		value, ok := ex.header(e.Name)
+		if !ok {
+			return "", fmt.Errorf("响应头%s不存在", e.Name)
+		}
+		return value, nil
+	case CheckJSONPath:
+		value, err := ex.jsonValue(e.Path)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		data, _ := json.Marshal(value)
+		return string(data), nil
+	case CheckBody:
+		re, err := regexp.Compile(e.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("正则表达式无效")
+		}
+		match := re.FindSubmatch(ex.Body)
+		if match == nil {
+			return "", fmt.Errorf("响应体不匹配%s", e.Pattern)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	}
+	return "", fmt.Errorf("提取类型无效: %s", e.Type)
+}
+
+// expectedStatus 解析状态码断言的期望值：数字200、字符串"200"或"2xx"（x匹配任意数字）
+func expectedStatus(raw json.RawMessage) (string, error) {
+	var code int
+	if json.Unmarshal(raw, &code) == nil {
+		raw, _ = json.Marshal(strconv.Itoa(code))
+	}
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return "", fmt.Errorf("状态码的期望值须为200或\"2xx\"形式")
+	}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) != 3 || s[0] < '1' || s[0] > '5' {
+		return "", fmt.Errorf("状态码的期望值须为200或\"2xx\"形式")
+	}
+	for i := 1; i < 3; i++ {
+		if s[i] != 'x' && (s[i] < '0' || s[i] > '9') {
+			return "", fmt.Errorf("状态码的期望值须为200或\"2xx\"形式")
+		}
+	}
+	return s, nil
+}
+
+// statusMatches 状态码是否符合expectedStatus解析出的期望值
+func statusMatches(expected string, code int) bool {
+	actual := strconv.Itoa(code)
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] != 'x' && expected[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reportValue 报告中展示的值，过长时截断
+func reportValue(s string) string {
+	if len(s) <= maxReportValueLength {
+		return s
+	}
+	s = s[:maxReportValueLength]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
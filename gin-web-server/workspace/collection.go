@@ -0,0 +1,294 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lf-web-tools/gin-cors-proxy/curlcmd"
+)
+
+const (
+	maxCollectionsPerUser    = 50
+	maxRequestsPerCollection = 100
+	maxEnvironmentsPerUser   = 20
+	maxVariablesPerEnv       = 100
+	maxCommandLength         = 64 << 10
+)
+
+// Collection 命名的请求集合，可以切换环境后按顺序重放
+type Collection struct {
+	ID          string           `json:"id"`
+	Username    string           `json:"username,omitempty"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Requests    []CollectionItem `json:"requests"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+}
+
+// CollectionItem 集合中的一个请求，命令中可以使用{{name}}引用环境变量和前面的请求提取的变量
+type CollectionItem struct {
+	Name       string       `json:"name"`
+	CurlParam  string       `json:"curlParam"`
+	Assertions []Assertion  `json:"assertions,omitempty"` // 运行集合时检查的断言
+	Extract    []Extraction `json:"extract,omitempty"`    // 运行集合时从响应中提取的变量
+}
+
+// Environment 一组变量，执行请求前替换命令中的{{name}}，如baseUrl、token
+type Environment struct {
+	ID        string            `json:"id"`
+	Username  string            `json:"username,omitempty"`
+	Name      string            `json:"name"`
+	Variables map[string]string `json:"variables"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// normalize 校验并整理客户端提交的集合
+func (c *Collection) normalize() error {
+	name, err := validName(c.Name)
+	if err != nil {
+		return err
+	}
+	c.Name = name
+	if c.Requests == nil {
+		c.Requests = []CollectionItem{}
+	}
+	return NormalizeRequests(c.Requests)
+}
+
+// NormalizeRequests 校验并整理一组请求，用于保存集合和直接运行客户端提交的请求
+func NormalizeRequests(items []CollectionItem) error {
+	if len(items) > maxRequestsPerCollection {
+		return invalid("每个集合最多%d个请求", maxRequestsPerCollection)
+	}
+	for i := range items {
+		item := &items[i]
+		item.Name = strings.TrimSpace(item.Name)
+		item.CurlParam = strings.TrimSpace(item.CurlParam)
+		if item.CurlParam == "" || len(item.CurlParam) > maxCommandLength {
+			return invalid("第%d个请求的命令不能为空且不超过%dKB", i+1, maxCommandLength>>10)
+		}
+		if item.Name == "" {
+			item.Name = fmt.Sprintf("请求%d", i+1)
+		}
+		if len(item.Assertions) > maxAssertionsPerRequest || len(item.Extract) > maxExtractionsPerRequest {
+			return invalid("第%d个请求最多%d条断言、%d个提取", i+1, maxAssertionsPerRequest, maxExtractionsPerRequest)
+		}
+		for j := range item.Assertions {
+			if err := item.Assertions[j].validate(); err != nil {
+				return invalid("第%d个请求的第%d条断言: %v", i+1, j+1, err)
+			}
+		}
+		for j := range item.Extract {
+			if err := item.Extract[j].validate(); err != nil {
+				return invalid("第%d个请求的第%d个提取: %v", i+1, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// normalize 校验并整理客户端提交的环境
+func (e *Environment) normalize() error {
+	name, err := validName(e.Name)
+	if err != nil {
+		return err
+	}
+	e.Name = name
+	if len(e.Variables) > maxVariablesPerEnv {
+		return invalid("每个环境最多%d个变量", maxVariablesPerEnv)
+	}
+	if e.Variables == nil {
+		e.Variables = map[string]string{}
+	}
+	for key := range e.Variables {
+		if !curlcmd.ValidVariableName(key) {
+			return invalid("变量名无效: %s，只能包含字母、数字、下划线、点和短横线，且不能以数字开头", key)
+		}
+	}
+	return nil
+}
+
+// ListCollections 列出用户的集合，按名称排序
+func ListCollections(username string) ([]Collection, error) {
+	collections := []Collection{}
+	err := dataStore.ForEach(bucketCollections, func(key string, raw []byte) error {
+		var c Collection
+		if json.Unmarshal(raw, &c) == nil && c.Username == username {
+			collections = append(collections, c)
+		}
+		return nil
+	})
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, err
+}
+
+// GetCollection 读取用户的集合
+func GetCollection(username, id string) (*Collection, error) {
+	var c Collection
+	exists, err := dataStore.Get(bucketCollections, id, &c)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || c.Username != username {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+// FindCollection 按ID或名称查找用户的集合，CI中运行集合时可以直接使用名称
+func FindCollection(username, ref string) (*Collection, error) {
+	ref = strings.TrimSpace(ref)
+	if c, err := GetCollection(username, ref); err == nil || !errors.Is(err, ErrNotFound) {
+		return c, err
+	}
+	collections, err := ListCollections(username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range collections {
+		if collections[i].Name == ref {
+			return &collections[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SaveCollection 新建（ID为空）或更新用户的集合，同一用户的集合不能重名
+func SaveCollection(username string, c *Collection) error {
+	if err := c.normalize(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	existing, err := ListCollections(username)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if c.ID == "" {
+		if len(existing) >= maxCollectionsPerUser {
+			return ErrLimit
+		}
+		c.ID, c.CreatedAt = newID(now), now
+	} else {
+		old, err := GetCollection(username, c.ID)
+		if err != nil {
+			return err
+		}
+		c.CreatedAt = old.CreatedAt
+	}
+	for _, other := range existing {
+		if other.ID != c.ID && other.Name == c.Name {
+			return ErrNameTaken
+		}
+	}
+	c.Username, c.UpdatedAt = username, now
+	return dataStore.Put(bucketCollections, c.ID, c)
+}
+
+// DeleteCollection 删除用户的集合
+func DeleteCollection(username, id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := GetCollection(username, id); err != nil {
+		return err
+	}
+	return dataStore.Delete(bucketCollections, id)
+}
+
+// ListEnvironments 列出用户的环境，按名称排序
+func ListEnvironments(username string) ([]Environment, error) {
+	envs := []Environment{}
+	err := dataStore.ForEach(bucketEnvironments, func(key string, raw []byte) error {
+		var e Environment
+		if json.Unmarshal(raw, &e) == nil && e.Username == username {
+			envs = append(envs, e)
+		}
+		return nil
+	})
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+	return envs, err
+}
+
+// GetEnvironment 读取用户的环境
+func GetEnvironment(username, id string) (*Environment, error) {
+	var e Environment
+	exists, err := dataStore.Get(bucketEnvironments, id, &e)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || e.Username != username {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+// FindEnvironment 按名称查找用户的环境，执行请求时用名称引用环境
+func FindEnvironment(username, name string) (*Environment, error) {
+	envs, err := ListEnvironments(username)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	for i := range envs {
+		if envs[i].Name == name {
+			return &envs[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SaveEnvironment 新建（ID为空）或更新用户的环境，同一用户的环境不能重名
+func SaveEnvironment(username string, e *Environment) error {
+	if err := e.normalize(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	existing, err := ListEnvironments(username)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if e.ID == "" {
+		if len(existing) >= maxEnvironmentsPerUser {
+			return ErrLimit
+		}
+		e.ID, e.CreatedAt = newID(now), now
+	} else {
+		old, err := GetEnvironment(username, e.ID)
+		if err != nil {
+			return err
+		}
+		e.CreatedAt = old.CreatedAt
+	}
+	for _, other := range existing {
+		if other.ID != e.ID && other.Name == e.Name {
+			return ErrNameTaken
+		}
+	}
+	e.Username, e.UpdatedAt = username, now
+	return dataStore.Put(bucketEnvironments, e.ID, e)
+}
+
+// DeleteEnvironment 删除用户的环境
+func DeleteEnvironment(username, id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := GetEnvironment(username, id); err != nil {
+		return err
+	}
+	return dataStore.Delete(bucketEnvironments, id)
+}
@@ -0,0 +1,192 @@
+package workspace
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryEntry 一次执行过的代理请求及其响应
+type HistoryEntry struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	// CurlParam 替换变量之前的命令，环境中的令牌等变量值不会保存到历史记录
+	CurlParam   string `json:"curlParam"`
+	Environment string `json:"environment,omitempty"`
+	// Target 请求方法和去掉查询参数的地址，用于列表展示和搜索
+	Target     string          `json:"target,omitempty"`
+	StatusCode int             `json:"statusCode"`
+	Error      string          `json:"error,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"` // 执行时返回的CurlResponse，响应体超过上限时截断
+	Pinned     bool            `json:"pinned"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// Summary 列表中展示的摘要，不含响应
+func (e HistoryEntry) Summary() HistoryEntry {
+	e.Username, e.Response = "", nil
+	return e
+}
+
+// HistoryFilter 历史记录查询条件，Query按子串匹配命令和目标地址（不区分大小写），Pinned为nil表示不限制
+type HistoryFilter struct {
+	Query  string
+	Pinned *bool
+	Offset int
+	Limit  int
+}
+
+// match 判断记录是否满足查询条件
+func (f HistoryFilter) match(e *HistoryEntry) bool {
+	if f.Pinned != nil && e.Pinned != *f.Pinned {
+		return false
+	}
+	if f.Query == "" {
+		return true
+	}
+	query := strings.ToLower(f.Query)
+	return strings.Contains(strings.ToLower(e.CurlParam), query) || strings.Contains(strings.ToLower(e.Target), query)
+}
+
+// AddHistory 保存一条历史记录，超出保留数量时删除最旧的未置顶记录
+func AddHistory(entry *HistoryEntry) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entry.CreatedAt = time.Now()
+	entry.ID = newID(entry.CreatedAt)
+	bucket := historyBucket(entry.Username)
+	if err := dataStore.Put(bucket, entry.ID, entry); err != nil {
+		return err
+	}
+
+	entries, err := listHistory(entry.Username)
+	if err != nil {
+		return err
+	}
+	var unpinned []string
+	for _, e := range entries {
+		if !e.Pinned {
+			unpinned = append(unpinned, e.ID)
+		}
+	}
+	// listHistory按时间倒序，超出部分在末尾
+	for i := historyLimit; i < len(unpinned); i++ {
+		if err := dataStore.Delete(bucket, unpinned[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListHistory 按条件分页查询用户的历史记录，置顶的在前，其余按时间倒序；返回摘要和符合条件的总数。Offset为负数时按0处理
+func ListHistory(username string, f HistoryFilter) ([]HistoryEntry, int, error) {
+	entries, err := listHistory(username)
+	if err != nil {
+		return nil, 0, err
+	}
+	matched := make([]HistoryEntry, 0, len(entries))
+	for i := range entries {
+		if f.match(&entries[i]) {
+			matched = append(matched, entries[i].Summary())
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Pinned && !matched[j].Pinned })
+
+	total := len(matched)
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	if f.Offset >= total {
+		return []HistoryEntry{}, total, nil
+	}
+	matched = matched[f.Offset:]
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	return matched, total, nil
+}
+
+// GetHistory 读取用户的一条历史记录
+func GetHistory(username, id string) (*HistoryEntry, error) {
+	var entry HistoryEntry
+	exists, err := dataStore.Get(historyBucket(username), id, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || entry.Username != username {
+		return nil, ErrNotFound
+	}
+	return &entry, nil
+}
+
+// PinHistory 置顶或取消置顶，置顶的记录不会因超出保留数量被删除
+func PinHistory(username, id string, pinned bool) (*HistoryEntry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entry, err := GetHistory(username, id)
+	if err != nil {
+		return nil, err
+	}
+	entry.Pinned = pinned
+	if err := dataStore.Put(historyBucket(username), id, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// DeleteHistory 删除用户的一条历史记录
+func DeleteHistory(username, id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := GetHistory(username, id); err != nil {
+		return err
+	}
+	return dataStore.Delete(historyBucket(username), id)
+}
+
+// ClearHistory 清空用户的历史记录，includePinned为false时保留置顶的记录；返回删除的数量
+func ClearHistory(username string, includePinned bool) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries, err := listHistory(username)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, e := range entries {
+		if e.Pinned && !includePinned {
+			continue
+		}
+		if err := dataStore.Delete(historyBucket(username), e.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// historyBucket 用户的历史记录所在的bucket。历史记录写入频繁且带响应体，每个用户单独一个bucket，
+// JSON存储每次写入只重写该用户的文件，查询时也不必遍历其他用户的记录。用户名按十六进制编码，可安全用作文件名
+func historyBucket(username string) string {
+	return bucketHistory + "_" + hex.EncodeToString([]byte(username))
+}
+
+// listHistory 读取用户的全部历史记录，按时间倒序
+func listHistory(username string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	err := dataStore.ForEach(historyBucket(username), func(key string, raw []byte) error {
+		var e HistoryEntry
+		if json.Unmarshal(raw, &e) == nil && e.Username == username {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	return entries, err
+}
@@ -0,0 +1,115 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+// useJSONStore 测试期间把工作区数据写入临时目录中的JSON文件
+func useJSONStore(t *testing.T, limit int) string {
+	t.Helper()
+	dir := t.TempDir()
+	oldStore, oldLimit, oldBody := dataStore, historyLimit, historyBodyLimit
+	Setup(store.NewJSONStore(dir), config.ProxyConfig{HistoryLimit: limit, HistoryBodyKB: 64})
+	t.Cleanup(func() { dataStore, historyLimit, historyBodyLimit = oldStore, oldLimit, oldBody })
+	return dir
+}
+
+func addHistory(t *testing.T, username, curlParam string) *HistoryEntry {
+	t.Helper()
+	entry := &HistoryEntry{Username: username, CurlParam: curlParam}
+	if err := AddHistory(entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestHistoryStoredPerUser(t *testing.T) {
+	dir := useJSONStore(t, 100)
+	addHistory(t, "alice", "curl https://a.example.com")
+	addHistory(t, "bob", "curl https://b.example.com")
+
+	aliceFile := filepath.Join(dir, historyBucket("alice")+".json")
+	bobFile := filepath.Join(dir, historyBucket("bob")+".json")
+	before, err := os.ReadFile(bobFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(aliceFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入一个用户的历史记录不会重写其他用户的文件
+	addHistory(t, "alice", "curl https://a.example.com/2")
+	after, _ := os.ReadFile(bobFile)
+	if string(before) != string(after) {
+		t.Error("adding alice's history rewrote bob's file")
+	}
+
+	entries, total, err := ListHistory("alice", HistoryFilter{})
+	if err != nil || total != 2 || len(entries) != 2 {
+		t.Fatalf("ListHistory(alice) = %d entries, total %d, %v", len(entries), total, err)
+	}
+	if _, err := GetHistory("bob", entries[0].ID); err != ErrNotFound {
+		t.Errorf("bob read alice's history: %v", err)
+	}
+
+	if err := DeleteUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := ListHistory("alice", HistoryFilter{}); total != 0 {
+		t.Errorf("alice has %d entries after DeleteUser", total)
+	}
+	if _, total, _ := ListHistory("bob", HistoryFilter{}); total != 1 {
+		t.Errorf("bob has %d entries after deleting alice", total)
+	}
+}
+
+func TestHistoryLimitKeepsPinned(t *testing.T) {
+	useJSONStore(t, 2)
+	first := addHistory(t, "carol", "curl first")
+	if _, err := PinHistory("carol", first.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"curl 2", "curl 3", "curl 4"} {
+		addHistory(t, "carol", cmd)
+	}
+
+	entries, total, err := ListHistory("carol", HistoryFilter{})
+	if err != nil || total != 3 {
+		t.Fatalf("total = %d, %v", total, err)
+	}
+	got := []string{entries[0].CurlParam, entries[1].CurlParam, entries[2].CurlParam}
+	want := []string{"curl first", "curl 4", "curl 3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("history = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestListHistoryPaging(t *testing.T) {
+	useJSONStore(t, 100)
+	for _, cmd := range []string{"curl 1", "curl 2", "curl 3"} {
+		addHistory(t, "dave", cmd)
+	}
+	tests := []struct {
+		filter HistoryFilter
+		want   int
+	}{
+		{HistoryFilter{Limit: 2}, 2},
+		{HistoryFilter{Offset: 2, Limit: 2}, 1},
+		{HistoryFilter{Offset: 3, Limit: 2}, 0},
+		{HistoryFilter{Offset: -5, Limit: 2}, 2},
+	}
+	for _, tt := range tests {
+		entries, total, err := ListHistory("dave", tt.filter)
+		if err != nil || total != 3 || len(entries) != tt.want {
+			t.Errorf("ListHistory(%+v) = %d entries, total %d, %v; want %d", tt.filter, len(entries), total, err, tt.want)
+		}
+	}
+}
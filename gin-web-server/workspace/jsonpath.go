@@ -0,0 +1,84 @@
+package workspace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath 解析后的JSONPath，支持 $.a.b、$['a b']、$.items[0]、$.items[-1] 形式的取值路径，不支持通配符和过滤表达式
+type jsonPath []interface{} // 元素为string（对象键）或int（数组下标，负数从末尾计）
+
+// parseJSONPath 解析JSONPath表达式，开头的$可以省略
+func parseJSONPath(expr string) (jsonPath, error) {
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+	var path jsonPath
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSONPath无效: %s", expr)
+			}
+			path = append(path, s[:end])
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath缺少]: %s", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				path = append(path, inner[1:len(inner)-1])
+			} else if index, err := strconv.Atoi(inner); err == nil {
+				path = append(path, index)
+			} else {
+				return nil, fmt.Errorf("JSONPath只支持数组下标和带引号的键: %s", expr)
+			}
+			s = s[end+1:]
+		default:
+			if path == nil && s == strings.TrimSpace(expr) {
+				// 省略了$和开头的点，如 data.token
+				s = "." + s
+				continue
+			}
+			return nil, fmt.Errorf("JSONPath无效: %s", expr)
+		}
+	}
+	return path, nil
+}
+
+// lookup 在json.Unmarshal得到的值中按路径取值，路径不存在时返回false
+func (p jsonPath) lookup(doc interface{}) (interface{}, bool) {
+	current := doc
+	for _, step := range p {
+		switch key := step.(type) {
+		case string:
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = obj[key]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			if key < 0 {
+				key += len(arr)
+			}
+			if key < 0 || key >= len(arr) {
+				return nil, false
+			}
+			current = arr[key]
+		}
+	}
+	return current, true
+}
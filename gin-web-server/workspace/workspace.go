@@ -0,0 +1,102 @@
+// Package workspace CURL测试的个人工作区：执行过的请求历史、保存的请求集合和环境变量，按用户保存在存储后端中。
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-web-tools/gin-web-server/config"
+	"github.com/lf-web-tools/gin-web-server/store"
+)
+
+const (
+	bucketHistory      = "curl_history" // 按用户分为多个bucket，见historyBucket
+	bucketCollections  = "curl_collections"
+	bucketEnvironments = "curl_environments"
+
+	maxNameLength = 64
+)
+
+var (
+	ErrNotFound  = errors.New("记录不存在")
+	ErrNameTaken = errors.New("名称已存在")
+	ErrLimit     = errors.New("数量已达上限，请先删除不用的记录")
+	ErrInvalid   = errors.New("参数无效")
+)
+
+var (
+	mu        sync.Mutex
+	dataStore store.Store = store.NewMemoryStore()
+
+	// historyLimit 每个用户保留的未置顶历史记录数；historyBodyLimit 历史记录中保存的响应体字节数上限
+	historyLimit     = 100
+	historyBodyLimit = 64 << 10
+
+	sequence uint32
+)
+
+// Setup 设置存储后端和历史记录的保留数量，须在注册路由前调用
+func Setup(st store.Store, cfg config.ProxyConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	dataStore = st
+	historyLimit = cfg.HistoryLimit
+	historyBodyLimit = cfg.HistoryBodyKB << 10
+}
+
+// HistoryBodyLimit 历史记录中保存的响应体字节数上限
+func HistoryBodyLimit() int {
+	return historyBodyLimit
+}
+
+// newID 生成按时间排序的记录ID
+func newID(t time.Time) string {
+	return fmt.Sprintf("%016x%08x", t.UnixNano(), atomic.AddUint32(&sequence, 1))
+}
+
+// validName 名称去掉首尾空白后不能为空且不超过maxNameLength个字符
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxNameLength {
+		return "", invalid("名称不能为空且不超过%d个字符", maxNameLength)
+	}
+	return name, nil
+}
+
+// invalid 返回包装了ErrInvalid的参数校验错误
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// DeleteUser 删除用户的全部历史记录、集合和环境，用于注销和删除账号
+func DeleteUser(username string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, bucket := range []string{historyBucket(username), bucketCollections, bucketEnvironments} {
+		var keys []string
+		err := dataStore.ForEach(bucket, func(key string, raw []byte) error {
+			var item struct {
+				Username string `json:"username"`
+			}
+			if json.Unmarshal(raw, &item) == nil && item.Username == username {
+				keys = append(keys, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := dataStore.Delete(bucket, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}